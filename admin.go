@@ -0,0 +1,127 @@
+package main
+
+import (
+	"crypto/subtle"
+	"log"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+type ScoreDiff struct {
+	ID        string `json:"id"`
+	OldPoints int64  `json:"oldPoints"`
+	NewPoints int64  `json:"newPoints"`
+}
+
+type ScoreDiffResponse struct {
+	Changed []ScoreDiff `json:"changed"`
+}
+
+type DeleteResponse struct {
+	Deleted int `json:"deleted"`
+}
+
+// requireAdmin rejects requests that don't carry the admin token as a bearer token.
+func requireAdmin(token string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !isAdmin(c, token) {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, errorBody(c, codeAdminTokenRequired))
+			return
+		}
+		c.Next()
+	}
+}
+
+// isAdmin reports whether the request carries the admin token as a bearer
+// token. No request does if the token is empty.
+func isAdmin(c *gin.Context, token string) bool {
+	provided, _ := strings.CutPrefix(c.GetHeader("Authorization"), "Bearer ")
+	return token != "" && subtle.ConstantTimeCompare([]byte(provided), []byte(token)) == 1
+}
+
+// registerAdmin adds the development only /admin and /debug endpoints.
+func (s *server) registerAdmin(r *gin.Engine) {
+	admin := r.Group("/admin")
+	admin.POST("/score-diff", s.scoreDiff)
+
+	r.GET("/debug/slow", s.getSlowRequests)
+	r.GET("/debug/config", s.getConfig)
+	r.POST("/debug/bench", s.bench)
+}
+
+// getConfig returns the effective server config, with secrets redacted.
+func (s *server) getConfig(c *gin.Context) {
+	c.JSON(http.StatusOK, s.cfg.redacted())
+}
+
+// scoreDiff rescores every receipt with cached points and reports the ones
+// whose points differ from the cached value. The cache is left untouched.
+func (s *server) scoreDiff(c *gin.Context) {
+	receipts, err := s.store.List(c.Request.Context())
+	if err != nil {
+		storeFailed(c, err)
+		return
+	}
+
+	changed := []ScoreDiff{}
+	for _, stored := range receipts {
+		if !stored.Scored {
+			continue
+		}
+		points, _, err := scoreReceipt(stored.Receipt, s.cfg.Rules, s.retailerHistory(c.Request.Context(), s.cfg.Rules, stored))
+		if err != nil {
+			log.Printf("rescoring receipt %s: %v\n", stored.ID, err)
+			continue
+		}
+		if points != stored.Points {
+			changed = append(changed, ScoreDiff{ID: stored.ID, OldPoints: stored.Points, NewPoints: points})
+		}
+	}
+	sort.Slice(changed, func(i, j int) bool { return changed[i].ID < changed[j].ID })
+
+	c.JSON(http.StatusOK, ScoreDiffResponse{Changed: changed})
+}
+
+// deleteReceipts removes all receipts matching the retailer and/or before
+// query filters. Deleting everything requires the explicit all=true flag.
+func (s *server) deleteReceipts(c *gin.Context) {
+	retailer := strings.ToLower(strings.TrimSpace(c.Query("retailer")))
+
+	var before time.Time
+	if value := c.Query("before"); value != "" {
+		var err error
+		before, err = time.Parse("2006-01-02", value)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, errorBody(c, codeInvalidBefore))
+			return
+		}
+	}
+
+	if retailer == "" && before.IsZero() && c.Query("all") != "true" {
+		c.JSON(http.StatusBadRequest, errorBody(c, codeDeleteFilterRequired))
+		return
+	}
+
+	deleted, err := s.store.DeleteWhere(c.Request.Context(), func(stored StoredReceipt) bool {
+		if retailer != "" && strings.ToLower(strings.TrimSpace(stored.Receipt.Retailer)) != retailer {
+			return false
+		}
+		if !before.IsZero() {
+			purchased, err := time.Parse("2006-01-02", stored.Receipt.PurchaseDate)
+			if err != nil || !purchased.Before(before) {
+				return false
+			}
+		}
+		return true
+	})
+	if err != nil {
+		storeFailed(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, DeleteResponse{Deleted: deleted})
+}
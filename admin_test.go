@@ -0,0 +1,149 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+)
+
+// captureStdout returns what f writes to standard output.
+func captureStdout(t *testing.T, f func()) string {
+	t.Helper()
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	stdout := os.Stdout
+	os.Stdout = w
+	defer func() { os.Stdout = stdout }()
+
+	f()
+	w.Close()
+	out, _ := io.ReadAll(r)
+	return string(out)
+}
+
+func TestScoringWithoutProcessingIsSilent(t *testing.T) {
+	receipt := example(t, "target-receipt.json")
+	tests := []struct {
+		name   string
+		method string
+		path   string
+		body   string
+		logged bool
+	}{
+		{"processing", http.MethodPost, "/receipts/process", receipt, true},
+		{"listing with points", http.MethodGet, "/receipts?withPoints=true", "", false},
+		{"score diff", http.MethodPost, "/admin/score-diff", "", false},
+		{"points", http.MethodGet, "/receipts/unscored/points", "", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store := newMemoryStore()
+			unscored, err := decodeReceipt(strings.NewReader(receipt), Config{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := store.Add(context.Background(), "unscored", unscored, nil); err != nil {
+				t.Fatal(err)
+			}
+			r := newServer(testConfig(t, "-dev"), store).router()
+
+			out := captureStdout(t, func() {
+				if w := serve(r, tt.method, tt.path, tt.body); w.Code != http.StatusOK {
+					t.Errorf("status = %d: %s", w.Code, w.Body)
+				}
+			})
+			if (out != "") != tt.logged {
+				t.Errorf("logged %q, want logged %v", out, tt.logged)
+			}
+		})
+	}
+}
+
+func TestDeleteReceipts(t *testing.T) {
+	seed := []Receipt{
+		{Retailer: "Target", PurchaseDate: "2022-01-01"},
+		{Retailer: " target", PurchaseDate: "2022-03-01"},
+		{Retailer: "Walgreens", PurchaseDate: "2022-01-01"},
+		{Retailer: "Walgreens", PurchaseDate: "2022-06-01"},
+	}
+	admin := []string{"Authorization", "Bearer admin"}
+	tests := []struct {
+		name    string
+		query   string
+		headers []string
+		status  int
+		deleted int
+	}{
+		{"by retailer", "?retailer=TARGET", admin, http.StatusOK, 2},
+		{"before a date", "?before=2022-02-01", admin, http.StatusOK, 2},
+		{"by both", "?retailer=walgreens&before=2022-07-01", admin, http.StatusOK, 2},
+		{"no match", "?retailer=Costco", admin, http.StatusOK, 0},
+		{"everything", "?all=true", admin, http.StatusOK, 4},
+		{"no filter", "", admin, http.StatusBadRequest, 0},
+		{"empty filter", "?retailer=%20", admin, http.StatusBadRequest, 0},
+		{"invalid date", "?before=yesterday", admin, http.StatusBadRequest, 0},
+		{"without the token", "?all=true", nil, http.StatusUnauthorized, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for name, store := range testStores(t) {
+				t.Run(name, func(t *testing.T) {
+					ctx := context.Background()
+					for i, receipt := range seed {
+						if err := store.Add(ctx, fmt.Sprint(i), receipt, nil); err != nil {
+							t.Fatal(err)
+						}
+					}
+					r := newServer(testConfig(t, "-admin-token", "admin"), store).router()
+					w := serve(r, http.MethodDelete, "/receipts"+tt.query, "", tt.headers...)
+					if w.Code != tt.status {
+						t.Fatalf("status = %d, want %d: %s", w.Code, tt.status, w.Body)
+					}
+					if w.Code == http.StatusOK && !strings.Contains(w.Body.String(), fmt.Sprintf(`"deleted":%d`, tt.deleted)) {
+						t.Errorf("response %s, want %d deleted", w.Body, tt.deleted)
+					}
+					if left, _ := store.List(ctx); len(left) != len(seed)-tt.deleted {
+						t.Errorf("%d receipts left, want %d", len(left), len(seed)-tt.deleted)
+					}
+				})
+			}
+		})
+	}
+}
+
+func TestDeleteReceiptsConcurrently(t *testing.T) {
+	store := newMemoryStore()
+	r := newServer(testConfig(t, "-admin-token", "admin"), store).router()
+	for i := range 50 {
+		if err := store.Add(context.Background(), fmt.Sprint(i), Receipt{Retailer: "Target"}, nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Every receipt is deleted exactly once, however the deletes interleave.
+	var wg sync.WaitGroup
+	var deleted atomic.Int64
+	for range 8 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			var response DeleteResponse
+			w := serve(r, http.MethodDelete, "/receipts?retailer=target", "", "Authorization", "Bearer admin")
+			json.Unmarshal(w.Body.Bytes(), &response)
+			deleted.Add(int64(response.Deleted))
+		}()
+	}
+	wg.Wait()
+	if deleted.Load() != 50 {
+		t.Errorf("%d receipts deleted, want 50", deleted.Load())
+	}
+}
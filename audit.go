@@ -0,0 +1,57 @@
+package main
+
+import (
+	"encoding/json"
+	"io"
+	"log"
+	"sync"
+	"time"
+)
+
+// AuditEntry is one line of the audit log.
+type AuditEntry struct {
+	ID        string    `json:"id"`
+	Timestamp time.Time `json:"timestamp"`
+	Retailer  string    `json:"retailer"`
+	Points    int64     `json:"points"`
+	ClientIP  string    `json:"clientIp,omitempty"`
+}
+
+// auditLog appends one JSON line per processed receipt, separate from the
+// application log. It is safe for concurrent use.
+type auditLog struct {
+	mu        sync.Mutex
+	w         io.Writer
+	includeIP bool
+}
+
+func newAuditLog(w io.Writer, includeIP bool) *auditLog {
+	return &auditLog{w: w, includeIP: includeIP}
+}
+
+// record writes the audit entry for a processed receipt.
+func (a *auditLog) record(id string, receipt Receipt, points int64, clientIP string) {
+	entry := AuditEntry{
+		ID:        id,
+		Timestamp: time.Now().UTC(),
+		Retailer:  receipt.Retailer,
+		Points:    points,
+	}
+	if a.includeIP {
+		entry.ClientIP = clientIP
+	}
+
+	line, err := json.Marshal(entry)
+	if err != nil {
+		log.Printf("encoding audit entry for receipt %s: %v\n", id, err)
+		return
+	}
+	line = append(line, '\n')
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if _, err := a.w.Write(line); err != nil {
+		log.Printf("writing audit entry for receipt %s: %v\n", id, err)
+	}
+}
@@ -0,0 +1,55 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"testing"
+)
+
+func TestAuditLog(t *testing.T) {
+	tests := []struct {
+		name      string
+		includeIP bool
+		clientIP  string
+	}{
+		{"without client IPs", false, ""},
+		{"with client IPs", true, "192.0.2.1"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var out bytes.Buffer
+			s := newServer(testConfig(t), newMemoryStore())
+			s.audit = newAuditLog(&out, tt.includeIP)
+			r := s.router()
+
+			ids := []string{
+				process(t, r, example(t, "target-receipt.json")),
+				process(t, r, example(t, "M&M-receipt.json")),
+			}
+			// Rejected receipts aren't audited.
+			serve(r, http.MethodPost, "/receipts/process", `{"retailer": "Target"}`)
+			ids = append(ids, process(t, r, example(t, "target-receipt.json")))
+
+			var entries []AuditEntry
+			scanner := bufio.NewScanner(&out)
+			for scanner.Scan() {
+				var entry AuditEntry
+				if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+					t.Fatalf("line %q: %v", scanner.Text(), err)
+				}
+				entries = append(entries, entry)
+			}
+			if len(entries) != len(ids) {
+				t.Fatalf("%d lines, want one per receipt: %s", len(entries), out.String())
+			}
+			wantPoints := []int64{28, 109, 28}
+			for i, entry := range entries {
+				if entry.ID != ids[i] || entry.Points != wantPoints[i] || entry.Timestamp.IsZero() || entry.ClientIP != tt.clientIP {
+					t.Errorf("line %d = %+v, want receipt %s with %d points", i, entry, ids[i], wantPoints[i])
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,226 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"log"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// maxBatchReceipts is the most receipts a single batch may contain.
+const maxBatchReceipts = 100
+
+// Statuses of the receipts of a batch.
+const (
+	batchStored     = "stored"
+	batchRejected   = "rejected"
+	batchFailed     = "failed"
+	batchSkipped    = "skipped"
+	batchRolledBack = "rolledBack"
+)
+
+type BatchResult struct {
+	// ID is set for stored and rolled back receipts.
+	ID     string `json:"id,omitempty"`
+	Status string `json:"status"`
+	// Error is the error response the receipt would have got on its own.
+	Error gin.H `json:"error,omitempty"`
+}
+
+type BatchResponse struct {
+	Results []BatchResult `json:"results"`
+	Stored  int           `json:"stored"`
+}
+
+// processBatch stores a JSON array of receipts, reporting the status of each
+// in the same order. By default every valid receipt is stored, and if the
+// store fails midway the receipts stored so far stay stored while the rest
+// fail. With atomic=true either all receipts are stored or none are: an
+// invalid receipt skips the whole batch, and a store failure removes the
+// receipts already stored.
+func (s *server) processBatch(c *gin.Context) {
+	data, err := io.ReadAll(c.Request.Body)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, errorBody(c, codeBatchInvalid, maxBatchReceipts))
+		return
+	}
+	// This only guards splitting the batch into receipts, which are checked
+	// against all limits when they are decoded. The batch array itself is
+	// bounded by maxBatchReceipts instead of the array limit.
+	maxDepth := s.cfg.MaxJSONDepth
+	if maxDepth > 0 {
+		maxDepth++
+	}
+	if err := checkJSONLimits(data, maxDepth, 0); err != nil {
+		c.JSON(http.StatusBadRequest, errorBody(c, codeJSONTooComplex))
+		return
+	}
+
+	var raw []json.RawMessage
+	if err := json.Unmarshal(data, &raw); err != nil || len(raw) == 0 || len(raw) > maxBatchReceipts {
+		c.JSON(http.StatusBadRequest, errorBody(c, codeBatchInvalid, maxBatchReceipts))
+		return
+	}
+	atomic := c.Query("atomic") == "true"
+
+	results := make([]BatchResult, len(raw))
+	receipts := make([]Receipt, len(raw))
+	rejected := false
+	for i, data := range raw {
+		receipt, err := decodeReceipt(bytes.NewReader(data), s.cfg)
+		if err == nil {
+			err = s.checkReceipt(receipt)
+		}
+		if err == nil {
+			err = s.claimReceipt(&receipt, requestUser(c))
+		}
+		if err != nil {
+			_, body := s.rejection(c, err)
+			results[i] = BatchResult{Status: batchRejected, Error: body}
+			rejected = true
+			continue
+		}
+		receipts[i] = receipt
+	}
+
+	if atomic && rejected {
+		for i := range results {
+			if results[i].Status == "" {
+				results[i].Status = batchSkipped
+			}
+		}
+		c.JSON(http.StatusBadRequest, BatchResponse{Results: results})
+		return
+	}
+
+	ctx := c.Request.Context()
+	stored := 0
+	// created are the IDs of the receipts this batch added, as opposed to
+	// earlier copies found by the dedupe window.
+	created := make(map[string]StoredReceipt)
+	var storeErr error
+	for i, receipt := range receipts {
+		if results[i].Status != "" {
+			continue
+		}
+		if storeErr != nil {
+			_, body := storeFailure(c, storeErr)
+			results[i] = BatchResult{Status: batchFailed, Error: body}
+			continue
+		}
+
+		// Receipts are only processed once the batch stands, so an atomic
+		// batch that is rolled back doesn't count, audit or announce any.
+		inserted, duplicate, err := s.insert(ctx, receipt, c.ClientIP())
+		if err != nil {
+			// A failing store is unlikely to recover within the batch, so the
+			// remaining receipts aren't tried.
+			log.Println(err)
+			storeErr = err
+			_, body := storeFailure(c, err)
+			results[i] = BatchResult{Status: batchFailed, Error: body}
+			continue
+		}
+		if !duplicate {
+			created[inserted.ID] = inserted
+		}
+		results[i] = BatchResult{ID: inserted.ID, Status: batchStored}
+		stored++
+	}
+
+	if storeErr == nil || !atomic {
+		s.processedBatch(ctx, results, created, c.ClientIP())
+	}
+	if storeErr == nil {
+		status := http.StatusOK
+		if stored < len(results) {
+			status = http.StatusMultiStatus
+		}
+		c.JSON(status, BatchResponse{Results: results, Stored: stored})
+		return
+	}
+	if !atomic {
+		c.JSON(http.StatusMultiStatus, BatchResponse{Results: results, Stored: stored})
+		return
+	}
+
+	// If the rollback fails too, the receipts are reported as stored as they
+	// may well still be.
+	status, _ := storeFailure(c, storeErr)
+	_, err = s.store.DeleteWhere(ctx, func(stored StoredReceipt) bool {
+		_, ok := created[stored.ID]
+		return ok
+	})
+	if err != nil {
+		log.Printf("rolling back batch: %v\n", err)
+		s.processedBatch(ctx, results, created, c.ClientIP())
+		c.JSON(status, BatchResponse{Results: results, Stored: stored})
+		return
+	}
+	for i := range results {
+		if _, ok := created[results[i].ID]; ok && results[i].Status == batchStored {
+			results[i].Status = batchRolledBack
+		}
+	}
+	c.JSON(status, BatchResponse{Results: results})
+}
+
+// processedBatch calls processed for the receipts a batch created, in order.
+func (s *server) processedBatch(ctx context.Context, results []BatchResult, created map[string]StoredReceipt, clientIP string) {
+	for _, result := range results {
+		if stored, ok := created[result.ID]; ok && result.Status == batchStored {
+			s.processed(ctx, stored, clientIP)
+		}
+	}
+}
+
+// maxPointsBatchIDs is the most receipts a single points lookup may name.
+const maxPointsBatchIDs = 1000
+
+type PointsBatchRequest struct {
+	IDs []string `json:"ids" binding:"required,min=1,max=1000"`
+}
+
+type PointsBatchResponse struct {
+	// Points maps the ID of every receipt found to its points.
+	Points map[string]int64 `json:"points"`
+	// Errors maps the IDs that couldn't be looked up to the error response
+	// each would have got on its own.
+	Errors map[string]gin.H `json:"errors,omitempty"`
+}
+
+// batchPoints returns the points of many receipts at once, looking them all
+// up in a single store call. Unknown IDs are reported in the errors instead of
+// failing the whole request.
+func (s *server) batchPoints(c *gin.Context) {
+	var req PointsBatchRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, errorBody(c, codePointsBatchInvalid, maxPointsBatchIDs))
+		return
+	}
+
+	ctx := c.Request.Context()
+	found, err := getMany(ctx, s.store, req.IDs)
+	if err != nil {
+		storeFailed(c, err)
+		return
+	}
+
+	response := PointsBatchResponse{Points: make(map[string]int64, len(found))}
+	for _, id := range req.IDs {
+		stored, ok := found[id]
+		if !ok {
+			if response.Errors == nil {
+				response.Errors = make(map[string]gin.H)
+			}
+			response.Errors[id] = errorBody(c, codeReceiptNotFound)
+			continue
+		}
+		response.Points[id] = s.points(ctx, stored)
+	}
+	c.JSON(http.StatusOK, response)
+}
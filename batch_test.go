@@ -0,0 +1,113 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"maps"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+// failingStore fails every Add after the first ok ones.
+type failingStore struct {
+	Store
+	ok int
+}
+
+func (f *failingStore) Add(ctx context.Context, id string, receipt Receipt, breakdown []award) error {
+	if f.ok == 0 {
+		return errors.New("disk full")
+	}
+	f.ok--
+	return f.Store.Add(ctx, id, receipt, breakdown)
+}
+
+func TestProcessBatchProcessedAfterCommit(t *testing.T) {
+	tests := []struct {
+		name      string
+		query     string
+		status    int
+		processed string
+		stored    int
+	}{
+		{"atomic", "?atomic=true", http.StatusInternalServerError, "receipt_processor_receipts_processed_total 0", 0},
+		{"not atomic", "", http.StatusMultiStatus, "receipt_processor_receipts_processed_total 2", 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store := newMemoryStore()
+			s := newServer(testConfig(t), &failingStore{Store: store, ok: 2})
+			r := s.router()
+
+			receipt := example(t, "simple-receipt.json")
+			batch := "[" + strings.Join([]string{receipt, example(t, "target-receipt.json"), example(t, "morning-receipt.json")}, ",") + "]"
+			w := serve(r, http.MethodPost, "/receipts/batch"+tt.query, batch)
+			if w.Code != tt.status {
+				t.Fatalf("status = %d, want %d: %s", w.Code, tt.status, w.Body)
+			}
+			if m := serve(r, http.MethodGet, "/metrics", ""); !strings.Contains(m.Body.String(), tt.processed+"\n") {
+				t.Errorf("metrics don't contain %q", tt.processed)
+			}
+			list, _ := store.List(context.Background())
+			if len(list) != tt.stored {
+				t.Errorf("stored %d receipts, want %d", len(list), tt.stored)
+			}
+		})
+	}
+}
+
+func TestBatchPoints(t *testing.T) {
+	r := newServer(testConfig(t), newMemoryStore()).router()
+	target := process(t, r, example(t, "target-receipt.json"))
+	mms := process(t, r, example(t, "M&M-receipt.json"))
+
+	tests := []struct {
+		name    string
+		ids     []string
+		status  int
+		points  map[string]int64
+		unknown []string
+	}{
+		{"known", []string{target, mms}, http.StatusOK, map[string]int64{target: 28, mms: 109}, nil},
+		{"known and unknown", []string{"missing", target, "gone", mms}, http.StatusOK, map[string]int64{target: 28, mms: 109}, []string{"missing", "gone"}},
+		{"only unknown", []string{"missing"}, http.StatusOK, map[string]int64{}, []string{"missing"}},
+		{"repeated", []string{target, target}, http.StatusOK, map[string]int64{target: 28}, nil},
+		{"none", []string{}, http.StatusBadRequest, nil, nil},
+		{"too many", make([]string, maxPointsBatchIDs+1), http.StatusBadRequest, nil, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			body, err := json.Marshal(PointsBatchRequest{IDs: tt.ids})
+			if err != nil {
+				t.Fatal(err)
+			}
+			w := serve(r, http.MethodPost, "/receipts/points/batch", string(body))
+			if w.Code != tt.status {
+				t.Fatalf("status = %d, want %d: %s", w.Code, tt.status, w.Body)
+			}
+			if w.Code != http.StatusOK {
+				return
+			}
+			var response struct {
+				Points map[string]int64          `json:"points"`
+				Errors map[string]map[string]any `json:"errors"`
+			}
+			if err := json.Unmarshal(w.Body.Bytes(), &response); err != nil {
+				t.Fatal(err)
+			}
+			if !maps.Equal(response.Points, tt.points) {
+				t.Errorf("points = %v, want %v", response.Points, tt.points)
+			}
+			if len(response.Errors) != len(tt.unknown) {
+				t.Errorf("errors = %v, want one for each of %v", response.Errors, tt.unknown)
+			}
+			for _, id := range tt.unknown {
+				if code := response.Errors[id]["code"]; code != codeReceiptNotFound {
+					t.Errorf("error code for %s = %v, want %s", id, code, codeReceiptNotFound)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,86 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"runtime"
+	"slices"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// maxBenchIterations bounds how often a single /debug/bench request scores.
+const maxBenchIterations = 100000
+
+// BenchRequest is a receipt to score N times, decoded like a processed one,
+// see decodeEnvelope.
+type BenchRequest struct {
+	Receipt json.RawMessage `json:"receipt"`
+	N       int             `json:"n"`
+}
+
+type BenchResponse struct {
+	N int `json:"n"`
+	// The latencies are in nanoseconds.
+	Total time.Duration `json:"totalNs"`
+	Mean  time.Duration `json:"meanNs"`
+	P50   time.Duration `json:"p50Ns"`
+	P95   time.Duration `json:"p95Ns"`
+	P99   time.Duration `json:"p99Ns"`
+	// AllocsPerOp is measured process wide, so concurrent requests inflate it.
+	AllocsPerOp float64 `json:"allocsPerOp"`
+	BytesPerOp  float64 `json:"bytesPerOp"`
+}
+
+// bench scores the posted receipt N times under the server's rules and
+// reports the latency distribution and allocations of a single scoring.
+func (s *server) bench(c *gin.Context) {
+	var req BenchRequest
+	if err := decodeEnvelope(c.Request.Body, s.cfg, &req); err != nil {
+		s.receiptRejected(c, err)
+		return
+	}
+	if req.N < 1 || req.N > maxBenchIterations {
+		c.JSON(http.StatusBadRequest, errorBody(c, codeInvalidIterations, maxBenchIterations))
+		return
+	}
+	receipt, err := s.decodeWrapped("receipt", req.Receipt)
+	if err != nil {
+		s.receiptRejected(c, err)
+		return
+	}
+
+	latencies := make([]time.Duration, req.N)
+	var before, after runtime.MemStats
+	runtime.ReadMemStats(&before)
+	for i := range latencies {
+		start := time.Now()
+		if _, _, err := scoreReceipt(receipt, s.cfg.Rules, retailerHistory{}); err != nil {
+			c.JSON(http.StatusBadRequest, errorBody(c, codeReceiptInvalid))
+			return
+		}
+		latencies[i] = time.Since(start)
+	}
+	runtime.ReadMemStats(&after)
+
+	total := time.Duration(0)
+	for _, latency := range latencies {
+		total += latency
+	}
+	slices.Sort(latencies)
+	percentile := func(p int) time.Duration {
+		return latencies[(len(latencies)-1)*p/100]
+	}
+
+	c.JSON(http.StatusOK, BenchResponse{
+		N:           req.N,
+		Total:       total,
+		Mean:        total / time.Duration(req.N),
+		P50:         percentile(50),
+		P95:         percentile(95),
+		P99:         percentile(99),
+		AllocsPerOp: float64(after.Mallocs-before.Mallocs) / float64(req.N),
+		BytesPerOp:  float64(after.TotalAlloc-before.TotalAlloc) / float64(req.N),
+	})
+}
@@ -0,0 +1,53 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+)
+
+func TestBench(t *testing.T) {
+	receipt := example(t, "target-receipt.json")
+	tests := []struct {
+		name   string
+		args   []string
+		body   string
+		status int
+	}{
+		{"not in dev mode", nil, `{"n": 10, "receipt": ` + receipt + `}`, http.StatusNotFound},
+		{"once", []string{"-dev"}, `{"n": 1, "receipt": ` + receipt + `}`, http.StatusOK},
+		{"many times", []string{"-dev"}, `{"n": 200, "receipt": ` + receipt + `}`, http.StatusOK},
+		{"no iterations", []string{"-dev"}, `{"receipt": ` + receipt + `}`, http.StatusBadRequest},
+		{"too many iterations", []string{"-dev"}, `{"n": 100001, "receipt": ` + receipt + `}`, http.StatusBadRequest},
+		{"invalid receipt", []string{"-dev"}, `{"n": 1, "receipt": {"retailer": "Target"}}`, http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := newServer(testConfig(t, tt.args...), newMemoryStore()).router()
+			w := serve(r, http.MethodPost, "/debug/bench", tt.body)
+			if w.Code != tt.status {
+				t.Fatalf("status = %d, want %d: %s", w.Code, tt.status, w.Body)
+			}
+			if w.Code != http.StatusOK {
+				return
+			}
+
+			var fields map[string]any
+			if err := json.Unmarshal(w.Body.Bytes(), &fields); err != nil {
+				t.Fatal(err)
+			}
+			for _, name := range []string{"n", "totalNs", "meanNs", "p50Ns", "p95Ns", "p99Ns", "allocsPerOp", "bytesPerOp"} {
+				if _, ok := fields[name].(float64); !ok {
+					t.Errorf("%s is missing or not a number: %s", name, w.Body)
+				}
+			}
+			var stats BenchResponse
+			if err := json.Unmarshal(w.Body.Bytes(), &stats); err != nil {
+				t.Fatal(err)
+			}
+			if stats.P50 <= 0 || stats.P50 > stats.P95 || stats.P95 > stats.P99 || stats.P99 > stats.Total || stats.Mean > stats.Total {
+				t.Errorf("inconsistent stats: %+v", stats)
+			}
+		})
+	}
+}
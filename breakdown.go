@@ -0,0 +1,108 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// breakdownVersion is the version of the breakdown format: the fields of an
+// award and the rule identifiers listed in the README. New rules don't change
+// it; renaming, removing or changing the meaning of an identifier or field does.
+const breakdownVersion = 1
+
+type BreakdownResponse struct {
+	ID               string  `json:"id"`
+	Points           int64   `json:"points"`
+	Breakdown        []award `json:"breakdown"`
+	BreakdownVersion int     `json:"breakdownVersion"`
+	// Live is set when the breakdown was computed under the current rules
+	// rather than recorded when the receipt was processed.
+	Live bool `json:"live"`
+}
+
+// breakdown scores the receipt under the current rules for storing its points
+// and breakdown along with it, so both come from the same scoring. Scoring
+// failures are only logged, as the points endpoint reports them; the receipt
+// is then stored without a breakdown or points and ok is false.
+func (s *server) breakdown(ctx context.Context, stored StoredReceipt) (points int64, awards []award, ok bool) {
+	_, span := tracer.Start(ctx, "calculatePoints")
+	points, awards, err := scoreReceipt(stored.Receipt, s.cfg.Rules, s.retailerHistory(ctx, s.cfg.Rules, stored))
+	span.End()
+	if err != nil {
+		log.Printf("scoring receipt for its breakdown: %v\n", err)
+		return 0, nil, false
+	}
+	if awards == nil {
+		awards = []award{}
+	}
+	return points, awards, true
+}
+
+// getBreakdown returns the per-rule attribution of a receipt's points as
+// recorded when it was processed, or under the current rules with ?live=true.
+func (s *server) getBreakdown(c *gin.Context) {
+	stored, err := s.store.Get(c.Request.Context(), c.Param("id"))
+	if errors.Is(err, errNotFound) {
+		c.JSON(http.StatusNotFound, errorBody(c, codeReceiptNotFound))
+		return
+	}
+	if err != nil {
+		storeFailed(c, err)
+		return
+	}
+
+	awards := stored.Breakdown
+	live := c.Query("live") == "true" || awards == nil
+	if live {
+		_, awards, _ = s.breakdown(c.Request.Context(), stored)
+	}
+
+	points := int64(0)
+	for _, a := range awards {
+		points += a.Points
+	}
+	c.JSON(http.StatusOK, BreakdownResponse{ID: stored.ID, Points: points, Breakdown: awards, BreakdownVersion: breakdownVersion, Live: live})
+}
+
+// retailerHistory returns the purchase dates of the other stored receipts from
+// the receipt's retailer, for the streak and first of day rules. A receipt
+// without a creation time isn't stored yet, so every stored receipt counts as
+// earlier. The history is empty when neither rule is enabled, so the store is
+// only queried when needed, and then only for the days around the purchase.
+func (s *server) retailerHistory(ctx context.Context, rules RulesConfig, receipt StoredReceipt) retailerHistory {
+	if rules.StreakBonus == 0 && rules.FirstOfDayBonus == 0 {
+		return retailerHistory{}
+	}
+	purchased, err := time.Parse("2006-01-02", receipt.Receipt.PurchaseDate)
+	if err != nil {
+		return retailerHistory{}
+	}
+	dates := []string{
+		purchased.AddDate(0, 0, -1).Format("2006-01-02"),
+		receipt.Receipt.PurchaseDate,
+		purchased.AddDate(0, 0, 1).Format("2006-01-02"),
+	}
+
+	receipts, err := purchases(ctx, s.store, receipt.Receipt.Retailer, dates)
+	if err != nil {
+		log.Printf("looking up the retailer history: %v\n", err)
+		return retailerHistory{}
+	}
+
+	history := retailerHistory{dates: make(map[string]bool), earlier: make(map[string]bool)}
+	for _, stored := range receipts {
+		if stored.ID == receipt.ID {
+			continue
+		}
+		history.dates[stored.Receipt.PurchaseDate] = true
+		if receipt.CreatedAt.IsZero() || storedBefore(stored, receipt) {
+			history.earlier[stored.Receipt.PurchaseDate] = true
+		}
+	}
+	return history
+}
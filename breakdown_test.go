@@ -0,0 +1,155 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestIngestStoresPointsWithBreakdown(t *testing.T) {
+	tests := []struct {
+		name    string
+		receipt string
+		points  int64
+	}{
+		{"target", "target-receipt.json", 28},
+		{"M&M", "M&M-receipt.json", 109},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store := newMemoryStore()
+			r := newServer(testConfig(t), store).router()
+
+			w := serve(r, http.MethodPost, "/receipts/process", example(t, tt.receipt))
+			var created ReceiptResponse
+			if err := json.Unmarshal(w.Body.Bytes(), &created); err != nil || w.Code != http.StatusOK {
+				t.Fatalf("processing: %d %s", w.Code, w.Body)
+			}
+
+			stored, err := store.Get(context.Background(), created.ID)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !stored.Scored || stored.Points != tt.points {
+				t.Errorf("stored points = %d (scored %v), want %d", stored.Points, stored.Scored, tt.points)
+			}
+			sum := int64(0)
+			for _, a := range stored.Breakdown {
+				sum += a.Points
+			}
+			if sum != stored.Points {
+				t.Errorf("breakdown adds up to %d, points are %d", sum, stored.Points)
+			}
+		})
+	}
+}
+
+// documentedRules are the stable rule identifiers of breakdown version 1, as
+// listed in the README.
+var documentedRules = []string{
+	"retailerName", "roundTotal", "quarterTotal", "itemPairs", "itemDescription", "oddDay", "afternoonTime",
+	"retailerBonus", "retailerStreak", "recency", "firstOfDay", "retailerKeyword", "retailerPalindrome", "weekend", "distinctItems", "samePrice", "centsParity", "digitSum", "expression",
+	"minTotal", "fractionRounding", "pointsRounding", "pointsCap",
+}
+
+func TestBreakdownVersion(t *testing.T) {
+	tests := []struct {
+		receipt string
+		args    []string
+		rules   []string
+	}{
+		{"target-receipt.json", nil, []string{"retailerName", "itemPairs", "itemDescription", "oddDay"}},
+		{"M&M-receipt.json", nil, []string{"retailerName", "roundTotal", "quarterTotal", "itemPairs", "afternoonTime"}},
+		{"M&M-receipt.json", []string{"-round-points", "5", "-max-points", "100"}, []string{"retailerName", "pointsRounding", "pointsCap"}},
+	}
+	for _, tt := range tests {
+		t.Run(fmt.Sprint(tt.receipt, tt.args), func(t *testing.T) {
+			r := newServer(testConfig(t, tt.args...), newMemoryStore()).router()
+			id := process(t, r, example(t, tt.receipt))
+			for _, query := range []string{"", "?live=true"} {
+				w := serve(r, http.MethodGet, "/receipts/"+id+"/breakdown"+query, "")
+				var response struct {
+					BreakdownVersion *int `json:"breakdownVersion"`
+					Breakdown        []struct {
+						Rule string `json:"rule"`
+					} `json:"breakdown"`
+				}
+				if err := json.Unmarshal(w.Body.Bytes(), &response); err != nil || w.Code != http.StatusOK {
+					t.Fatalf("%s: %d %s", query, w.Code, w.Body)
+				}
+				if response.BreakdownVersion == nil || *response.BreakdownVersion != 1 {
+					t.Errorf("%s: breakdownVersion = %v, want 1", query, response.BreakdownVersion)
+				}
+				var got []string
+				for _, entry := range response.Breakdown {
+					if !slices.Contains(documentedRules, entry.Rule) {
+						t.Errorf("%s: undocumented rule %q", query, entry.Rule)
+					}
+					got = append(got, entry.Rule)
+				}
+				for _, rule := range tt.rules {
+					if !slices.Contains(got, rule) {
+						t.Errorf("%s: no %s in %v", query, rule, got)
+					}
+				}
+			}
+		})
+	}
+
+	for _, r := range scoringRules {
+		if !slices.Contains(documentedRules, r.name) {
+			t.Errorf("rule %q isn't documented", r.name)
+		}
+	}
+}
+
+func TestBreakdownDuplicateItems(t *testing.T) {
+	receipt := func(descriptions ...string) string {
+		items := []string{}
+		for _, description := range descriptions {
+			items = append(items, `{"shortDescription": "`+description+`", "price": "10.00"}`)
+		}
+		return `{"retailer": "Target", "purchaseDate": "2022-01-02", "purchaseTime": "13:13", "total": "` + fmt.Sprintf("%d.00", 10*len(descriptions)) + `", "items": [` + strings.Join(items, ", ") + `]}`
+	}
+	tests := []struct {
+		name    string
+		receipt string
+		items   []int
+	}{
+		{"identical", receipt("Pepsi 12L", "Pepsi 12L"), []int{0, 1}},
+		{"identical apart", receipt("Pepsi 12L", "Chips", "Pepsi 12L"), []int{0, 2}},
+		{"trimmed alike", receipt("Pepsi 12L", "  Pepsi 12L  ", "Pepsi 12L"), []int{0, 1, 2}},
+		{"not qualifying", receipt("Chips", "Chips"), nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := newServer(testConfig(t), newMemoryStore()).router()
+			id := process(t, r, tt.receipt)
+			var response BreakdownResponse
+			w := serve(r, http.MethodGet, "/receipts/"+id+"/breakdown", "")
+			if err := json.Unmarshal(w.Body.Bytes(), &response); err != nil || w.Code != http.StatusOK {
+				t.Fatalf("%d %s", w.Code, w.Body)
+			}
+			var items []int
+			for _, a := range response.Breakdown {
+				if a.Rule != "itemDescription" {
+					continue
+				}
+				if a.Item == nil {
+					t.Fatalf("itemDescription entry without an item: %+v", a)
+				}
+				if a.Points != 3 {
+					t.Errorf("items[%d]: %d points, want 3", *a.Item, a.Points)
+				}
+				items = append(items, *a.Item)
+			}
+			if !slices.Equal(items, tt.items) {
+				t.Errorf("itemDescription entries for items %v, want %v", items, tt.items)
+			}
+		})
+	}
+}
@@ -0,0 +1,125 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/sony/gobreaker"
+)
+
+// errStoreUnavailable is returned without calling the store while the
+// circuit breaker is open.
+var errStoreUnavailable = errors.New("receipt store unavailable")
+
+// breakerStore wraps a Store with a circuit breaker. After enough
+// consecutive failures the breaker opens and calls fail fast with
+// errStoreUnavailable; once the timeout passes it lets a single call through
+// to probe whether the store has recovered.
+type breakerStore struct {
+	store Store
+	cb    *gobreaker.CircuitBreaker
+}
+
+func newBreakerStore(store Store, failures uint32, timeout time.Duration) *breakerStore {
+	return &breakerStore{
+		store: store,
+		cb: gobreaker.NewCircuitBreaker(gobreaker.Settings{
+			Name:        "store",
+			MaxRequests: 1,
+			Timeout:     timeout,
+			ReadyToTrip: func(counts gobreaker.Counts) bool {
+				return counts.ConsecutiveFailures >= failures
+			},
+			OnStateChange: func(name string, from, to gobreaker.State) {
+				log.Printf("%s circuit breaker changed from %s to %s\n", name, from, to)
+			},
+			IsSuccessful: isStoreHealthy,
+		}),
+	}
+}
+
+// isStoreHealthy reports whether err leaves the store looking healthy. Errors
+// about the request itself, such as an unknown ID or a cancelled or
+// timed out request, don't count against the store.
+func isStoreHealthy(err error) bool {
+	return err == nil ||
+		errors.Is(err, errNotFound) ||
+		errors.Is(err, errIDTaken) ||
+		errors.Is(err, errVersionMismatch) ||
+		errors.Is(err, errStoreFull) ||
+		errors.Is(err, context.Canceled) ||
+		errors.Is(err, context.DeadlineExceeded)
+}
+
+// breakerCall runs fn through the breaker of b.
+func breakerCall[T any](b *breakerStore, fn func() (T, error)) (T, error) {
+	result, err := b.cb.Execute(func() (any, error) { return fn() })
+	if errors.Is(err, gobreaker.ErrOpenState) || errors.Is(err, gobreaker.ErrTooManyRequests) {
+		err = fmt.Errorf("%w: %v", errStoreUnavailable, err)
+	}
+	if err != nil {
+		var zero T
+		return zero, err
+	}
+	return result.(T), nil
+}
+
+func (b *breakerStore) Add(ctx context.Context, id string, receipt Receipt, breakdown []award) error {
+	_, err := breakerCall(b, func() (struct{}, error) {
+		return struct{}{}, b.store.Add(ctx, id, receipt, breakdown)
+	})
+	return err
+}
+
+func (b *breakerStore) Get(ctx context.Context, id string) (StoredReceipt, error) {
+	return breakerCall(b, func() (StoredReceipt, error) { return b.store.Get(ctx, id) })
+}
+
+func (b *breakerStore) GetMany(ctx context.Context, ids []string) (map[string]StoredReceipt, error) {
+	return breakerCall(b, func() (map[string]StoredReceipt, error) { return getMany(ctx, b.store, ids) })
+}
+
+func (b *breakerStore) Update(ctx context.Context, id string, receipt Receipt, breakdown []award, version int) (StoredReceipt, error) {
+	return breakerCall(b, func() (StoredReceipt, error) {
+		return b.store.Update(ctx, id, receipt, breakdown, version)
+	})
+}
+
+func (b *breakerStore) SetPoints(ctx context.Context, id string, points int64) error {
+	_, err := breakerCall(b, func() (struct{}, error) {
+		return struct{}{}, b.store.SetPoints(ctx, id, points)
+	})
+	return err
+}
+
+func (b *breakerStore) List(ctx context.Context) ([]StoredReceipt, error) {
+	return breakerCall(b, func() ([]StoredReceipt, error) { return b.store.List(ctx) })
+}
+
+func (b *breakerStore) Group(ctx context.Context, groupID string) ([]StoredReceipt, error) {
+	return breakerCall(b, func() ([]StoredReceipt, error) { return b.store.Group(ctx, groupID) })
+}
+
+func (b *breakerStore) Tagged(ctx context.Context, tag string) ([]StoredReceipt, error) {
+	return breakerCall(b, func() ([]StoredReceipt, error) { return taggedReceipts(ctx, b.store, tag) })
+}
+
+func (b *breakerStore) Purchases(ctx context.Context, retailer string, dates []string) ([]StoredReceipt, error) {
+	return breakerCall(b, func() ([]StoredReceipt, error) { return purchases(ctx, b.store, retailer, dates) })
+}
+
+func (b *breakerStore) DeleteWhere(ctx context.Context, match func(StoredReceipt) bool) (int, error) {
+	return breakerCall(b, func() (int, error) { return b.store.DeleteWhere(ctx, match) })
+}
+
+// Probe checks the wrapped store directly, so /ready reflects the backend
+// even while the breaker is open.
+func (b *breakerStore) Probe(ctx context.Context) error {
+	if p, ok := b.store.(prober); ok {
+		return p.Probe(ctx)
+	}
+	return nil
+}
@@ -0,0 +1,109 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/sony/gobreaker"
+)
+
+// flakyStore fails every Get while down, and counts the calls that reach it.
+type flakyStore struct {
+	Store
+	down  bool
+	calls int
+}
+
+func (f *flakyStore) Get(ctx context.Context, id string) (StoredReceipt, error) {
+	f.calls++
+	if f.down {
+		return StoredReceipt{}, errors.New("connection refused")
+	}
+	return f.Store.Get(ctx, id)
+}
+
+func TestBreakerStore(t *testing.T) {
+	const timeout = 20 * time.Millisecond
+	type step struct {
+		// wait is how long to wait before the call.
+		wait time.Duration
+		down bool
+		// reached is whether the call gets to the store.
+		reached bool
+		state   gobreaker.State
+	}
+	tests := []struct {
+		name  string
+		steps []step
+	}{
+		{"healthy", []step{
+			{0, false, true, gobreaker.StateClosed},
+			{0, false, true, gobreaker.StateClosed},
+		}},
+		{"opens after consecutive failures", []step{
+			{0, true, true, gobreaker.StateClosed},
+			{0, true, true, gobreaker.StateOpen},
+			{0, true, false, gobreaker.StateOpen},
+			{0, false, false, gobreaker.StateOpen},
+		}},
+		{"successes reset the failures", []step{
+			{0, true, true, gobreaker.StateClosed},
+			{0, false, true, gobreaker.StateClosed},
+			{0, true, true, gobreaker.StateClosed},
+		}},
+		{"unknown IDs don't count", []step{
+			{0, false, true, gobreaker.StateClosed},
+			{0, false, true, gobreaker.StateClosed},
+			{0, false, true, gobreaker.StateClosed},
+		}},
+		{"probe closes it again", []step{
+			{0, true, true, gobreaker.StateClosed},
+			{0, true, true, gobreaker.StateOpen},
+			{2 * timeout, false, true, gobreaker.StateClosed},
+			{0, false, true, gobreaker.StateClosed},
+		}},
+		{"failed probe opens it again", []step{
+			{0, true, true, gobreaker.StateClosed},
+			{0, true, true, gobreaker.StateOpen},
+			{2 * timeout, true, true, gobreaker.StateOpen},
+			{0, false, false, gobreaker.StateOpen},
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			flaky := &flakyStore{Store: newMemoryStore()}
+			b := newBreakerStore(flaky, 2, timeout)
+			for i, s := range tt.steps {
+				time.Sleep(s.wait)
+				flaky.down = s.down
+				calls := flaky.calls
+				_, err := b.Get(context.Background(), "missing")
+				if reached := flaky.calls > calls; reached != s.reached {
+					t.Errorf("step %d reached the store: %v", i, reached)
+				}
+				if !s.reached && !errors.Is(err, errStoreUnavailable) {
+					t.Errorf("step %d failed with %v, want errStoreUnavailable", i, err)
+				}
+				if state := b.cb.State(); state != s.state {
+					t.Errorf("step %d left the breaker %s, want %s", i, state, s.state)
+				}
+			}
+		})
+	}
+}
+
+func TestBreakerStoreUnavailable(t *testing.T) {
+	flaky := &flakyStore{Store: newMemoryStore(), down: true}
+	r := newServer(testConfig(t), newBreakerStore(flaky, 1, time.Minute)).router()
+	for _, status := range []int{http.StatusInternalServerError, http.StatusServiceUnavailable, http.StatusServiceUnavailable} {
+		if w := serve(r, http.MethodGet, "/receipts/missing/points", ""); w.Code != status {
+			t.Errorf("status = %d, want %d: %s", w.Code, status, w.Body)
+		}
+	}
+	if flaky.calls != 1 {
+		t.Errorf("the open breaker let %d calls through", flaky.calls)
+	}
+}
@@ -0,0 +1,119 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"sync"
+)
+
+// errStoreFull is returned by a cappedStore that is full and doesn't evict.
+var errStoreFull = errors.New("receipt store is full")
+
+// cappedStore limits a Store to max receipts. When it is full, Add either
+// fails with errStoreFull or, with evict set, first deletes the oldest receipt.
+type cappedStore struct {
+	Store
+	max   int
+	evict bool
+
+	// mu guards order. It is only held to pick the receipt to evict, so
+	// adding receipts to the wrapped store happens concurrently.
+	mu sync.Mutex
+	// order holds the IDs of the stored receipts, oldest first, including
+	// those being added right now. Its length is the number of receipts.
+	order []string
+}
+
+func newCappedStore(ctx context.Context, store Store, max int, evict bool) (*cappedStore, error) {
+	list, err := store.List(ctx)
+	if err != nil {
+		return nil, err
+	}
+	order := make([]string, len(list))
+	for i, stored := range list {
+		order[i] = stored.ID
+	}
+	return &cappedStore{Store: store, max: max, evict: evict, order: order}, nil
+}
+
+func (s *cappedStore) Add(ctx context.Context, id string, receipt Receipt, breakdown []award) error {
+	s.mu.Lock()
+	oldest := ""
+	if len(s.order) >= s.max {
+		if !s.evict {
+			s.mu.Unlock()
+			return errStoreFull
+		}
+		oldest = s.order[0]
+		s.order = s.order[1:]
+	}
+	s.order = append(s.order, id)
+	s.mu.Unlock()
+
+	if oldest != "" {
+		_, err := s.Store.DeleteWhere(ctx, func(stored StoredReceipt) bool { return stored.ID == oldest })
+		if err != nil {
+			s.mu.Lock()
+			s.order = slices.Insert(s.removeLast(id), 0, oldest)
+			s.mu.Unlock()
+			return err
+		}
+	}
+	if err := s.Store.Add(ctx, id, receipt, breakdown); err != nil {
+		s.mu.Lock()
+		s.order = s.removeLast(id)
+		s.mu.Unlock()
+		return err
+	}
+	return nil
+}
+
+// removeLast returns the order without the last occurrence of id, which an
+// Add that failed put there. s.mu must be held.
+func (s *cappedStore) removeLast(id string) []string {
+	for i := len(s.order) - 1; i >= 0; i-- {
+		if s.order[i] == id {
+			return slices.Delete(s.order, i, i+1)
+		}
+	}
+	return s.order
+}
+
+func (s *cappedStore) DeleteWhere(ctx context.Context, match func(StoredReceipt) bool) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	deletedIDs := make(map[string]bool)
+	deleted, err := s.Store.DeleteWhere(ctx, func(stored StoredReceipt) bool {
+		if match(stored) {
+			deletedIDs[stored.ID] = true
+			return true
+		}
+		return false
+	})
+	if len(deletedIDs) > 0 {
+		s.order = slices.DeleteFunc(s.order, func(id string) bool { return deletedIDs[id] })
+	}
+	return deleted, err
+}
+
+func (s *cappedStore) GetMany(ctx context.Context, ids []string) (map[string]StoredReceipt, error) {
+	return getMany(ctx, s.Store, ids)
+}
+
+func (s *cappedStore) Tagged(ctx context.Context, tag string) ([]StoredReceipt, error) {
+	return taggedReceipts(ctx, s.Store, tag)
+}
+
+func (s *cappedStore) Purchases(ctx context.Context, retailer string, dates []string) ([]StoredReceipt, error) {
+	return purchases(ctx, s.Store, retailer, dates)
+}
+
+// Probe passes the readiness check through to the wrapped store.
+func (s *cappedStore) Probe(ctx context.Context) error {
+	if p, ok := s.Store.(prober); ok {
+		return p.Probe(ctx)
+	}
+	return nil
+}
@@ -0,0 +1,110 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestCappedStore(t *testing.T) {
+	tests := []struct {
+		name  string
+		evict bool
+		ids   []string
+		want  []string
+		errs  []error
+	}{
+		{"below the cap", false, []string{"a", "b"}, []string{"a", "b"}, []error{nil, nil}},
+		{"full", false, []string{"a", "b", "c", "d"}, []string{"a", "b", "c"}, []error{nil, nil, nil, errStoreFull}},
+		{"evicting the oldest", true, []string{"a", "b", "c", "d", "e"}, []string{"c", "d", "e"}, []error{nil, nil, nil, nil, nil}},
+		{"taken IDs keep their slot", true, []string{"a", "b", "b", "c"}, []string{"a", "b", "c"}, []error{nil, nil, errIDTaken, nil}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			store, err := newCappedStore(ctx, newMemoryStore(), 3, tt.evict)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for i, id := range tt.ids {
+				if err := store.Add(ctx, id, Receipt{}, nil); !errors.Is(err, tt.errs[i]) {
+					t.Errorf("adding %s: %v, want %v", id, err, tt.errs[i])
+				}
+			}
+			list, _ := store.List(ctx)
+			if ids := storedIDs(list); !slices.Equal(ids, tt.want) {
+				t.Errorf("stored %v, want %v", ids, tt.want)
+			}
+		})
+	}
+}
+
+func TestCappedStoreConcurrentEviction(t *testing.T) {
+	ctx := context.Background()
+	store, err := newCappedStore(ctx, newShardedStore(4), 10, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var wg sync.WaitGroup
+	for i := range 200 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := store.Add(ctx, fmt.Sprint(i), Receipt{}, nil); err != nil {
+				t.Error(err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	list, _ := store.List(ctx)
+	if len(list) != 10 || len(store.order) != 10 {
+		t.Errorf("stored %d receipts, tracking %d, want 10", len(list), len(store.order))
+	}
+	if _, err := store.DeleteWhere(ctx, func(StoredReceipt) bool { return true }); err != nil || len(store.order) != 0 {
+		t.Errorf("tracking %d receipts after deleting all, %v", len(store.order), err)
+	}
+}
+
+func TestProcessAtCapacity(t *testing.T) {
+	receipts := []string{example(t, "simple-receipt.json"), example(t, "target-receipt.json"), example(t, "M&M-receipt.json")}
+	tests := []struct {
+		name     string
+		evict    bool
+		statuses []int
+	}{
+		{"reject", false, []int{http.StatusOK, http.StatusOK, http.StatusServiceUnavailable}},
+		{"evict", true, []int{http.StatusOK, http.StatusOK, http.StatusOK}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store, err := newCappedStore(context.Background(), newMemoryStore(), 2, tt.evict)
+			if err != nil {
+				t.Fatal(err)
+			}
+			r := newServer(testConfig(t), store).router()
+			for i, receipt := range receipts {
+				w := serve(r, http.MethodPost, "/receipts/process", receipt)
+				if w.Code != tt.statuses[i] {
+					t.Fatalf("receipt %d: status = %d, want %d: %s", i, w.Code, tt.statuses[i], w.Body)
+				}
+				if w.Code == http.StatusServiceUnavailable && !strings.Contains(w.Body.String(), codeStoreFull) {
+					t.Errorf("receipt %d: %s, want %s", i, w.Body, codeStoreFull)
+				}
+			}
+			if list, _ := store.List(context.Background()); len(list) != 2 {
+				t.Errorf("stored %d receipts, want 2", len(list))
+			}
+		})
+	}
+
+	if _, err := parseConfig([]string{"-at-capacity", "drop"}); err == nil {
+		t.Error("-at-capacity drop was accepted")
+	}
+}
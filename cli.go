@@ -0,0 +1,70 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/gin-gonic/gin/binding"
+)
+
+// runScore implements the score command, which prints the points of a
+// receipt file (or stdin for "-") without starting the server. It returns
+// the process exit code.
+func runScore(args []string, stdin io.Reader, stdout, stderr io.Writer) int {
+	var rules RulesConfig
+	var breakdown bool
+
+	fs := flag.NewFlagSet("score", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	fs.Usage = func() {
+		fmt.Fprintln(stderr, "usage: receipt-processor score [flags] <receipt.json | ->")
+		fs.PrintDefaults()
+	}
+	fs.BoolVar(&breakdown, "breakdown", false, "print the breakdown of the points")
+	registerRuleFlags(fs, &rules)
+
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	if fs.NArg() != 1 {
+		fs.Usage()
+		return 2
+	}
+
+	input := stdin
+	if path := fs.Arg(0); path != "-" {
+		file, err := os.Open(path)
+		if err != nil {
+			fmt.Fprintln(stderr, err)
+			return 1
+		}
+		defer file.Close()
+		input = file
+	}
+
+	var receipt Receipt
+	if err := json.NewDecoder(input).Decode(&receipt); err != nil {
+		fmt.Fprintln(stderr, "The receipt is invalid:", err)
+		return 1
+	}
+	if err := binding.Validator.ValidateStruct(&receipt); err != nil {
+		fmt.Fprintln(stderr, "The receipt is invalid:", err)
+		return 1
+	}
+
+	points, awards, err := scoreReceipt(receipt, rules, retailerHistory{})
+	if err != nil {
+		fmt.Fprintln(stderr, err)
+		return 1
+	}
+
+	if breakdown {
+		printBreakdown(stdout, awards, points)
+	} else {
+		fmt.Fprintln(stdout, points)
+	}
+	return 0
+}
@@ -0,0 +1,43 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestRunScore(t *testing.T) {
+	tests := []struct {
+		name   string
+		args   []string
+		stdin  string
+		code   int
+		stdout string
+		stderr string
+	}{
+		{"file", []string{"examples/target-receipt.json"}, "", 0, "28\n", ""},
+		{"stdin", []string{"-"}, example(t, "M&M-receipt.json"), 0, "109\n", ""},
+		{"breakdown", []string{"-breakdown", "examples/target-receipt.json"}, "", 0, "Total Points: 28\n", ""},
+		{"rule flags", []string{"-retailer-bonus", "target=10", "examples/target-receipt.json"}, "", 0, "38\n", ""},
+		{"missing file", []string{"examples/missing.json"}, "", 1, "", "no such file"},
+		{"invalid receipt", []string{"-"}, `{"retailer": "Target"}`, 1, "", "The receipt is invalid"},
+		{"not JSON", []string{"-"}, "retailer,total", 1, "", "The receipt is invalid"},
+		{"no file", nil, "", 2, "", "usage:"},
+		{"unknown flag", []string{"-nope", "-"}, "", 2, "", "flag provided but not defined"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var stdout, stderr bytes.Buffer
+			code := runScore(tt.args, strings.NewReader(tt.stdin), &stdout, &stderr)
+			if code != tt.code {
+				t.Errorf("exit code = %d, want %d: %s", code, tt.code, stderr.String())
+			}
+			if !strings.HasSuffix(stdout.String(), tt.stdout) || (tt.stdout == "" && stdout.Len() > 0) {
+				t.Errorf("stdout = %q, want %q", stdout.String(), tt.stdout)
+			}
+			if !strings.Contains(stderr.String(), tt.stderr) {
+				t.Errorf("stderr = %q, want it to contain %q", stderr.String(), tt.stderr)
+			}
+		})
+	}
+}
@@ -0,0 +1,551 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"maps"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+// Config holds the server settings parsed from the command line.
+type Config struct {
+	Addr string
+
+	// TrustedProxies are the IPs and CIDRs of the proxies whose
+	// X-Forwarded-For and X-Real-IP headers are believed. None are trusted by
+	// default, so the client IP is the address of the connection.
+	TrustedProxies []string
+
+	// DataFile is the JSON file receipts are saved to. Receipts are only kept
+	// in memory when it is empty.
+	DataFile string
+
+	// ReadOnly starts the server in read-only mode, rejecting new, updated
+	// and deleted receipts with a 503 while still serving reads.
+	ReadOnly bool
+
+	// WarmCache scores all stored receipts on startup, so their points are
+	// cached before the first request.
+	WarmCache bool
+
+	// MigrateTo is the data file POST /admin/migrate moves the receipts to.
+	// Migration is disabled when it is empty.
+	MigrateTo string
+
+	// StoreShards splits the in-memory store into this many independently
+	// locked shards. More than one can't be combined with a DataFile.
+	StoreShards int
+
+	// MaxReceipts caps the number of stored receipts, zero for no cap.
+	// AtCapacity is what happens to new receipts once it is reached:
+	// atCapacityReject or atCapacityEvict.
+	MaxReceipts int
+	AtCapacity  string
+
+	// BreakerFailures is how many consecutive store failures open the circuit
+	// breaker, which then fails requests fast for BreakerTimeout before
+	// probing the store again. Zero disables the breaker.
+	BreakerFailures int
+	BreakerTimeout  time.Duration
+
+	// RequestTimeout bounds how long a single request may take. Zero disables it.
+	RequestTimeout time.Duration
+
+	// MaxConcurrent bounds how many requests are handled at once; others are
+	// turned away with a 503. Zero disables the limit.
+	MaxConcurrent int
+
+	// MaxBodySize bounds the size of request bodies in bytes; larger ones are
+	// rejected with a 413. Zero disables the limit.
+	MaxBodySize int64
+
+	// DedupeWindow is how long an identical receipt from the same client IP
+	// returns the ID of the first one instead of being stored again. Zero disables it.
+	DedupeWindow time.Duration
+
+	// SecurityHeaders are set on every response, see defaultSecurityHeaders.
+	SecurityHeaders map[string]string
+
+	// Gzip compresses responses of at least GzipMinSize bytes for clients that accept it.
+	Gzip        bool
+	GzipMinSize int
+
+	// AuditLog is the file a JSON line is appended to for every processed
+	// receipt. AuditClientIP adds the client's IP address to each line.
+	AuditLog      string
+	AuditClientIP bool
+
+	// GRPCAddr is the address the gRPC service of receipts.proto listens on,
+	// next to the HTTP server. Disabled when empty.
+	GRPCAddr string
+
+	// WebhookURL is posted a WebhookEvent for every processed receipt. Each
+	// attempt may take WebhookTimeout; failed ones are retried WebhookRetries
+	// times, starting WebhookBackoff apart and doubling. Events that can't be
+	// delivered are appended to WebhookDeadLetter, if set. Disabled when empty.
+	WebhookURL        string
+	WebhookTimeout    time.Duration
+	WebhookRetries    int
+	WebhookBackoff    time.Duration
+	WebhookDeadLetter string
+
+	// NATSURL is the NATS server receipts are also consumed from, on
+	// QueueSubject. Results are published on QueueResults and messages that
+	// can't be processed on QueueDeadLetter. Disabled when empty.
+	NATSURL         string
+	QueueSubject    string
+	QueueResults    string
+	QueueDeadLetter string
+
+	// OTLPEndpoint is the OTLP/HTTP collector URL traces are exported to.
+	// Tracing is disabled when it is empty.
+	OTLPEndpoint string
+
+	// MetricsRetailers bounds how many retailers get their own label in the
+	// points metric; the rest are counted as "other".
+	MetricsRetailers int
+
+	// Dev enables the development only /admin and /debug endpoints.
+	Dev bool
+
+	// SlowRequests is how many of the slowest requests dev mode keeps for /debug/slow.
+	SlowRequests int
+
+	// AdminToken is the bearer token required by admin endpoints such as
+	// DELETE /receipts. Those endpoints are disabled when it is empty.
+	AdminToken string
+
+	// TokenKey signs the tokens sent with the points of a receipt, which
+	// POST /verify checks. Tokens are disabled when it is empty.
+	TokenKey string
+
+	// UserKey signs the user tokens that authenticate requests as a user, see
+	// identifyUser. GET /users/:userId/receipts is disabled when it is empty.
+	UserKey string
+
+	// Pprof exposes the runtime profiling endpoints under /debug/pprof.
+	Pprof bool
+
+	// Strict rejects receipts containing fields that are not part of the schema.
+	Strict bool
+
+	// JSONKeys is the casing of the keys of JSON responses, keyCaseCamel or
+	// keyCaseSnake.
+	JSONKeys string
+
+	// RoundMoney is how amounts with more decimal places than their currency
+	// are rounded before validation: roundMoneyNearest, roundMoneyHalfEven or
+	// roundMoneyDown. Empty rejects them.
+	RoundMoney string
+
+	// MaxJSONDepth and MaxJSONArray bound how deeply the objects and arrays
+	// of a JSON receipt may be nested and how many elements an array may
+	// have. Zero disables a limit.
+	MaxJSONDepth int
+	MaxJSONArray int
+
+	// LenientTime also accepts 12-hour purchase times like "02:33 PM",
+	// converting them to 24-hour times.
+	LenientTime bool
+
+	// LenientMoney also accepts totals and prices sent as JSON numbers.
+	LenientMoney bool
+
+	// AllowEmptyItems accepts receipts with an empty items list, such as
+	// pure fee charges. By default a receipt needs at least one item.
+	AllowEmptyItems bool
+
+	// ItemOrder is the policy item descriptions must follow: itemOrderSorted,
+	// itemOrderUnique or itemOrderSortedUnique. Empty accepts any order.
+	ItemOrder string
+
+	// NormalizeRetailer is how retailer names are normalized before storage:
+	// normalizeWhitespace, normalizeLowercase or normalizeNone.
+	NormalizeRetailer string
+
+	// MaxItemPrice and MaxTotal reject receipts with a larger item price or
+	// total. Zero disables the check.
+	MaxItemPrice decimal.Decimal
+	MaxTotal     decimal.Decimal
+
+	// MinTotal is the smallest total accepted, zero for any. What happens to
+	// receipts below it depends on BelowMinTotal.
+	MinTotal decimal.Decimal
+	// BelowMinTotal is belowMinTotalReject or belowMinTotalZero.
+	BelowMinTotal string
+
+	// UnprocessableStatus answers receipts that are well-formed but break one
+	// of the configured rules with a 422 instead of a 400.
+	UnprocessableStatus bool
+
+	// CheckItemSum rejects receipts whose item prices don't add up to the
+	// total, give or take ItemSumTolerance minor units of the currency.
+	CheckItemSum     bool
+	ItemSumTolerance int64
+
+	// DeniedRetailers holds the lowercased names of retailers whose receipts
+	// are rejected. Empty by default, accepting every retailer.
+	DeniedRetailers map[string]bool
+
+	// Defaults maps receipt fields to values used when a receipt omits them.
+	// Empty by default, so every required field has to be sent.
+	Defaults map[string]string
+
+	Rules RulesConfig
+}
+
+// redactedSecret replaces secrets in Config.redacted.
+const redactedSecret = "REDACTED"
+
+// redacted returns a copy of the config that is safe to show: the admin
+// token, the token and user keys and the credentials in URLs are replaced.
+func (cfg Config) redacted() Config {
+	if cfg.AdminToken != "" {
+		cfg.AdminToken = redactedSecret
+	}
+	if cfg.TokenKey != "" {
+		cfg.TokenKey = redactedSecret
+	}
+	if cfg.UserKey != "" {
+		cfg.UserKey = redactedSecret
+	}
+	cfg.NATSURL = redactURL(cfg.NATSURL)
+	cfg.WebhookURL = redactURL(cfg.WebhookURL)
+	cfg.OTLPEndpoint = redactURL(cfg.OTLPEndpoint)
+	return cfg
+}
+
+// redactURL replaces the user info of a URL, which may be a password or a
+// token, or the whole URL if it doesn't parse.
+func redactURL(value string) string {
+	if value == "" {
+		return ""
+	}
+	parsed, err := url.Parse(value)
+	if err != nil {
+		return redactedSecret
+	}
+	if parsed.User != nil {
+		parsed.User = url.User(redactedSecret)
+	}
+	return parsed.String()
+}
+
+// parseConfig reads the server settings from the given command line arguments.
+func parseConfig(args []string) (Config, error) {
+	cfg := Config{
+		BelowMinTotal:   belowMinTotalReject,
+		AtCapacity:      atCapacityReject,
+		JSONKeys:        keyCaseCamel,
+		SecurityHeaders: maps.Clone(defaultSecurityHeaders),
+		DeniedRetailers: make(map[string]bool),
+		Defaults:        make(map[string]string),
+	}
+
+	fs := flag.NewFlagSet("receipt-processor", flag.ContinueOnError)
+	fs.StringVar(&cfg.Addr, "addr", ":8080", "address the HTTP server listens on")
+	fs.Func("trusted-proxy", "trust the X-Forwarded-For header of a proxy at this `IP or CIDR`, e.g. 10.0.0.0/8 (repeatable, none trusted by default)", func(value string) error {
+		value = strings.TrimSpace(value)
+		if _, _, err := net.ParseCIDR(value); err != nil && net.ParseIP(value) == nil {
+			return fmt.Errorf("invalid proxy %q, expected an IP address or CIDR", value)
+		}
+		cfg.TrustedProxies = append(cfg.TrustedProxies, value)
+		return nil
+	})
+	fs.StringVar(&cfg.DataFile, "data-file", "", "save receipts to this JSON file so they survive a restart (in memory only if empty)")
+	fs.BoolVar(&cfg.ReadOnly, "read-only", false, "start in read-only mode: reject new, updated and deleted receipts with a 503 but keep serving reads")
+	fs.StringVar(&cfg.MigrateTo, "migrate-to", "", "JSON `file` POST /admin/migrate moves the receipts to, switching to it as the data file (disabled if empty)")
+	fs.BoolVar(&cfg.WarmCache, "warm-cache", false, "score the receipts loaded on startup in parallel, so their points are cached before the first request")
+	fs.IntVar(&cfg.StoreShards, "store-shards", 1, "number of independently locked shards of the in-memory store")
+	fs.IntVar(&cfg.MaxReceipts, "max-receipts", 0, "maximum number of stored receipts, see -at-capacity (unlimited if 0)")
+	fs.Func("at-capacity", "what to do with new receipts once -max-receipts is reached: `reject` them with a 503 (default) or evict the oldest receipt", func(value string) error {
+		switch value {
+		case atCapacityReject, atCapacityEvict:
+			cfg.AtCapacity = value
+			return nil
+		}
+		return fmt.Errorf("unknown -at-capacity action %q, expected reject or evict", value)
+	})
+	fs.IntVar(&cfg.BreakerFailures, "breaker-failures", 0, "consecutive store failures that open the circuit breaker (disabled if 0)")
+	fs.DurationVar(&cfg.BreakerTimeout, "breaker-timeout", 30*time.Second, "how long the open circuit breaker fails requests before probing the store again")
+	fs.DurationVar(&cfg.RequestTimeout, "request-timeout", 0, "maximum duration of a single request, e.g. 2s (disabled if 0)")
+	fs.IntVar(&cfg.MaxConcurrent, "max-concurrent", 0, "maximum `number` of requests handled at once, others get a 503 (disabled if 0)")
+	fs.Int64Var(&cfg.MaxBodySize, "max-body-size", 8<<20, "reject request bodies larger than this many `bytes` with a 413 (disabled if 0)")
+	fs.DurationVar(&cfg.DedupeWindow, "dedupe-window", 0, "return the earlier ID for an identical receipt resent by the same client within this long, e.g. 5s (disabled if 0)")
+	fs.Func("security-header", "set a response header as `Name=value`, or omit one of the default security headers with Name= (repeatable)", func(value string) error {
+		name, headerValue, ok := strings.Cut(value, "=")
+		name = http.CanonicalHeaderKey(strings.TrimSpace(name))
+		if !ok || name == "" {
+			return fmt.Errorf("expected Name=value, got %q", value)
+		}
+		if headerValue == "" {
+			delete(cfg.SecurityHeaders, name)
+		} else {
+			cfg.SecurityHeaders[name] = headerValue
+		}
+		return nil
+	})
+	fs.BoolFunc("no-security-headers", "don't set any security headers", func(string) error {
+		clear(cfg.SecurityHeaders)
+		return nil
+	})
+	fs.BoolVar(&cfg.Gzip, "gzip", false, "gzip compress responses for clients that accept it")
+	fs.IntVar(&cfg.GzipMinSize, "gzip-min-size", 1024, "smallest response in bytes that -gzip compresses")
+	fs.StringVar(&cfg.AuditLog, "audit-log", "", "append a JSON line per processed receipt to this file (disabled if empty)")
+	fs.BoolVar(&cfg.AuditClientIP, "audit-client-ip", false, "include the client IP address in the audit log")
+	fs.StringVar(&cfg.GRPCAddr, "grpc-addr", "", "also serve the gRPC service of receipts.proto on this address, e.g. :9090 (disabled if empty)")
+	fs.StringVar(&cfg.WebhookURL, "webhook-url", "", "post the ID and points of every processed receipt to this URL (disabled if empty)")
+	fs.DurationVar(&cfg.WebhookTimeout, "webhook-timeout", 5*time.Second, "how long a single webhook delivery attempt may take")
+	fs.IntVar(&cfg.WebhookRetries, "webhook-retries", 3, "how often a failed webhook delivery is retried")
+	fs.DurationVar(&cfg.WebhookBackoff, "webhook-backoff", time.Second, "wait this long before the first webhook retry, doubling for every later one")
+	fs.StringVar(&cfg.WebhookDeadLetter, "webhook-dead-letter", "", "append webhook events that couldn't be delivered to this file as JSON lines (only logged if empty)")
+	fs.StringVar(&cfg.NATSURL, "nats-url", "", "also consume receipts from this NATS server, e.g. nats://localhost:4222 (disabled if empty)")
+	fs.StringVar(&cfg.QueueSubject, "queue-subject", "receipts", "NATS subject receipts are consumed from")
+	fs.StringVar(&cfg.QueueResults, "queue-results", "receipts.results", "NATS subject the ID and points of consumed receipts are published on")
+	fs.StringVar(&cfg.QueueDeadLetter, "queue-dead-letter", "receipts.dead-letter", "NATS subject receipts that can't be processed are published on")
+	fs.StringVar(&cfg.OTLPEndpoint, "otlp-endpoint", "", "OTLP/HTTP endpoint to export traces to, e.g. http://localhost:4318 (disabled if empty)")
+	fs.IntVar(&cfg.MetricsRetailers, "metrics-retailers", 100, "number of retailers labeled individually in the points metric, the rest are labeled \"other\"")
+	fs.BoolVar(&cfg.Dev, "dev", false, "enable the development only /admin and /debug endpoints")
+	fs.IntVar(&cfg.SlowRequests, "slow-requests", 20, "number of slowest requests kept for /debug/slow in dev mode")
+	fs.StringVar(&cfg.AdminToken, "admin-token", "", "bearer token for the admin endpoints, which are disabled if empty")
+	fs.StringVar(&cfg.UserKey, "user-key", "", "secret `key` signing the X-User-Token headers that authenticate users, GET /users/{userId}/receipts is disabled if empty")
+	fs.StringVar(&cfg.TokenKey, "token-key", "", "secret `key` signing the tokens sent with points and checked by POST /verify, which are disabled if empty")
+	fs.BoolVar(&cfg.Pprof, "pprof", false, "expose the pprof profiling endpoints under /debug/pprof")
+	fs.BoolVar(&cfg.Strict, "strict", false, "reject receipts with unknown JSON fields instead of ignoring them")
+	fs.IntVar(&cfg.MaxJSONDepth, "max-json-depth", 10, "reject JSON receipts with objects and arrays nested deeper than this (disabled if 0)")
+	fs.IntVar(&cfg.MaxJSONArray, "max-json-array", 0, "reject JSON receipts with an array of more than this many elements, such as items (disabled if 0)")
+	fs.Func("json-keys", "casing of the keys of JSON responses: `camel`Case (the default) or snake_case", func(value string) error {
+		switch value {
+		case keyCaseCamel, keyCaseSnake:
+			cfg.JSONKeys = value
+			return nil
+		}
+		return fmt.Errorf("unknown key casing %q, expected camel or snake", value)
+	})
+	fs.Func("round-money", "round amounts with too many decimal places, e.g. 35.350000001, to the currency's: `nearest`, half-even or down (rejected by default)", func(value string) error {
+		switch value {
+		case roundMoneyNearest, roundMoneyHalfEven, roundMoneyDown:
+			cfg.RoundMoney = value
+			return nil
+		}
+		return fmt.Errorf("unknown rounding mode %q, expected nearest, half-even or down", value)
+	})
+	fs.BoolVar(&cfg.LenientTime, "lenient-time", false, "also accept 12-hour purchase times like \"02:33 PM\", converting them to 24-hour times")
+	fs.BoolVar(&cfg.LenientMoney, "lenient-money", false, "also accept totals and prices sent as JSON numbers, e.g. 6.49 instead of \"6.49\"")
+	fs.Func("item-order", "require item descriptions to be `sorted`, unique or sorted-unique", func(value string) error {
+		switch value {
+		case itemOrderAny, itemOrderSorted, itemOrderUnique, itemOrderSortedUnique:
+			cfg.ItemOrder = value
+			return nil
+		}
+		return fmt.Errorf("unknown item order policy %q", value)
+	})
+	fs.Func("normalize-retailer", "normalize retailer names before storage: `whitespace` or lowercase", func(value string) error {
+		switch value {
+		case normalizeNone, normalizeWhitespace, normalizeLowercase:
+			cfg.NormalizeRetailer = value
+			return nil
+		}
+		return fmt.Errorf("unknown retailer normalization %q", value)
+	})
+	fs.Func("max-item-price", "reject receipts with an item priced above this `amount` (disabled by default)", func(value string) error {
+		return parseLimit(&cfg.MaxItemPrice, value)
+	})
+	fs.Func("min-total", "reject receipts with a total below this `amount`, see -below-min-total (disabled by default)", func(value string) error {
+		return parseLimit(&cfg.MinTotal, value)
+	})
+	fs.Func("below-min-total", "what to do with receipts below -min-total: `reject` them (default) or accept them with zero points", func(value string) error {
+		switch value {
+		case belowMinTotalReject, belowMinTotalZero:
+			cfg.BelowMinTotal = value
+			return nil
+		}
+		return fmt.Errorf("unknown -below-min-total action %q, expected reject or zero", value)
+	})
+	fs.Func("max-total", "reject receipts with a total above this `amount` (disabled by default)", func(value string) error {
+		return parseLimit(&cfg.MaxTotal, value)
+	})
+	fs.BoolVar(&cfg.AllowEmptyItems, "allow-empty-items", false, "accept receipts with an empty items list")
+	fs.BoolVar(&cfg.UnprocessableStatus, "unprocessable-status", false, "reject well-formed receipts that break a configured rule, like -check-item-sum or -max-total, with a 422 instead of a 400")
+	fs.BoolVar(&cfg.CheckItemSum, "check-item-sum", false, "reject receipts whose item prices don't add up to the total")
+	fs.Func("item-sum-tolerance", "let -check-item-sum accept sums off by up to this many `cents` (or the currency's minor unit), 0 by default", func(value string) error {
+		tolerance, err := strconv.ParseInt(value, 10, 64)
+		if err != nil || tolerance < 0 {
+			return fmt.Errorf("invalid item sum tolerance %q, expected a non-negative number", value)
+		}
+		cfg.ItemSumTolerance = tolerance
+		return nil
+	})
+	fs.Func("deny-retailer", "reject receipts from this `retailer` with a 403 (repeatable, case-insensitive)", func(value string) error {
+		name := strings.ToLower(strings.TrimSpace(value))
+		if name == "" {
+			return fmt.Errorf("retailer name must not be empty")
+		}
+		cfg.DeniedRetailers[name] = true
+		return nil
+	})
+	fs.Func("default", "default `field=value` for receipts missing the field, e.g. purchaseTime=12:00 (repeatable)", func(value string) error {
+		return parseDefault(cfg.Defaults, value)
+	})
+	registerRuleFlags(fs, &cfg.Rules)
+
+	if err := fs.Parse(args); err != nil {
+		return Config{}, err
+	}
+	if cfg.SlowRequests < 0 {
+		return Config{}, fmt.Errorf("-slow-requests must not be negative")
+	}
+	if cfg.StoreShards < 1 {
+		return Config{}, fmt.Errorf("-store-shards must be at least 1")
+	}
+	if cfg.StoreShards > 1 && cfg.DataFile != "" {
+		return Config{}, fmt.Errorf("-store-shards can't be combined with -data-file")
+	}
+	if cfg.WebhookRetries < 0 {
+		return Config{}, fmt.Errorf("-webhook-retries must not be negative")
+	}
+	if cfg.MaxReceipts < 0 {
+		return Config{}, fmt.Errorf("-max-receipts must not be negative")
+	}
+	if cfg.BreakerFailures < 0 {
+		return Config{}, fmt.Errorf("-breaker-failures must not be negative")
+	}
+	if cfg.MaxBodySize < 0 {
+		return Config{}, fmt.Errorf("-max-body-size must not be negative")
+	}
+	if cfg.MetricsRetailers < 0 {
+		return Config{}, fmt.Errorf("-metrics-retailers must not be negative")
+	}
+	if cfg.BelowMinTotal == belowMinTotalZero && !cfg.MinTotal.IsZero() {
+		// Scoring takes care of these receipts instead of validation.
+		cfg.Rules.MinTotal = cfg.MinTotal.String()
+	}
+	return cfg, nil
+}
+
+// retailerDenied reports whether the receipt's retailer is on the denylist.
+// The name as sent is checked too, in case normalization changed it.
+func (cfg Config) retailerDenied(receipt Receipt) bool {
+	for _, name := range []string{receipt.Retailer, receipt.RetailerOriginal} {
+		if cfg.DeniedRetailers[strings.ToLower(strings.TrimSpace(name))] {
+			return true
+		}
+	}
+	return false
+}
+
+// Actions for new receipts once Config.MaxReceipts is reached.
+const (
+	atCapacityReject = "reject"
+	atCapacityEvict  = "evict"
+)
+
+// Actions for receipts below Config.MinTotal.
+const (
+	belowMinTotalReject = "reject"
+	belowMinTotalZero   = "zero"
+)
+
+// parseLimit parses a positive money limit such as "500.00".
+func parseLimit(limit *decimal.Decimal, value string) error {
+	amount, err := parseMoney(value)
+	if err != nil || !amount.IsPositive() {
+		return fmt.Errorf("invalid limit %q, expected a positive amount", value)
+	}
+	*limit = amount
+	return nil
+}
+
+// registerRuleFlags adds the flags configuring the scoring rules, which the
+// server and the score command share.
+func registerRuleFlags(fs *flag.FlagSet, rules *RulesConfig) {
+	rules.RetailerBonuses = make(map[string]int64)
+	rules.RetailerKeywords = make(map[string]int64)
+
+	fs.Func("retailer-bonus", "award extra points to a retailer, as `retailer=points` (repeatable, case-insensitive)", func(value string) error {
+		return parseNamedPoints(rules.RetailerBonuses, value)
+	})
+	fs.Func("max-points", "cap the points of a single receipt at this `number` (no cap by default)", func(value string) error {
+		points, err := strconv.ParseInt(value, 10, 64)
+		if err != nil || points < 1 {
+			return fmt.Errorf("invalid points cap %q, expected a positive number", value)
+		}
+		rules.MaxPoints = points
+		return nil
+	})
+	fs.Func("round-points", "round the points of every receipt to the nearest multiple of this `number`, e.g. 5 (not rounded by default)", func(value string) error {
+		n, err := strconv.ParseInt(value, 10, 64)
+		if err != nil || n < 1 {
+			return fmt.Errorf("invalid points rounding %q, expected a positive number", value)
+		}
+		rules.RoundPoints = n
+		return nil
+	})
+	fs.Func("max-item-pair-points", "cap the points of the every two items rule at this `number` (no cap by default)", func(value string) error {
+		points, err := strconv.ParseInt(value, 10, 64)
+		if err != nil || points < 1 {
+			return fmt.Errorf("invalid item pairs cap %q, expected a positive number", value)
+		}
+		rules.MaxItemPairPoints = points
+		return nil
+	})
+	fs.Func("min-description-length", "only apply the item description rule to descriptions of at least this trimmed `length` (applies to all by default)", func(value string) error {
+		n, err := strconv.Atoi(value)
+		if err != nil || n < 0 {
+			return fmt.Errorf("invalid minimum description length %q, expected a non-negative number", value)
+		}
+		rules.MinDescriptionLength = n
+		return nil
+	})
+	fs.Func("fractional-points", "keep the fractional points of the item description rule and round their sum once, `up`, nearest or down (each item is rounded up by default)", func(value string) error {
+		switch value {
+		case fractionalPointsUp, fractionalPointsNearest, fractionalPointsDown:
+			rules.FractionalPoints = value
+			return nil
+		}
+		return fmt.Errorf("unknown -fractional-points rounding %q, expected up, nearest or down", value)
+	})
+	fs.Int64Var(&rules.StreakBonus, "streak-bonus", 0, "award these points when the retailer has another stored receipt from the day before or after (disabled if 0)")
+	fs.Int64Var(&rules.RecencyBonus, "recency-bonus", 0, "award these points when the receipt is scored within -recency-days of its purchase date (disabled if 0)")
+	fs.Func("recency-days", "how many `days`, today included, a purchase counts as recent for -recency-bonus (default 7)", func(value string) error {
+		days, err := strconv.Atoi(value)
+		if err != nil || days < 1 {
+			return fmt.Errorf("invalid recency days %q, expected a positive number", value)
+		}
+		rules.RecencyDays = days
+		return nil
+	})
+	fs.Int64Var(&rules.FirstOfDayBonus, "first-of-day-bonus", 0, "award these points to the first receipt processed from a retailer for each purchase date (disabled if 0)")
+	fs.Int64Var(&rules.DistinctItemPoints, "distinct-item-points", 0, "award these points for every distinct item description, ignoring case and surrounding spaces (disabled if 0)")
+	fs.Int64Var(&rules.SamePriceBonus, "same-price-bonus", 0, "award these points when a receipt has two or more items, all with the same price (disabled if 0)")
+	fs.Int64Var(&rules.EvenCentsPoints, "even-cents-points", 0, "add these points, which may be negative, to receipts whose total has an even number of cents")
+	fs.Int64Var(&rules.OddCentsPoints, "odd-cents-points", 0, "add these points, which may be negative, to receipts whose total has an odd number of cents")
+	fs.BoolVar(&rules.DigitSum, "digit-sum", false, "award the digits of the total added up as points, e.g. 16 for 35.35")
+	fs.Int64Var(&rules.PalindromeBonus, "palindrome-bonus", 0, "award these points when the retailer name is a palindrome, ignoring case and non-alphanumeric characters (disabled if 0)")
+	fs.Int64Var(&rules.WeekendBonus, "weekend-bonus", 0, "award these points when the purchase date is a Saturday or Sunday (disabled if 0)")
+	fs.Func("retailer-keyword", "award points for each occurrence of a keyword in the retailer name, as `keyword=points` (repeatable, case-insensitive)", func(value string) error {
+		return parseNamedPoints(rules.RetailerKeywords, value)
+	})
+	fs.Func("time-window", "award points to purchases within a time window, as `start-end=points` such as 14:00-16:00=10 (repeatable, replaces the default 2pm to 4pm window)", func(value string) error {
+		window, err := parseTimeWindow(value)
+		if err != nil {
+			return err
+		}
+		rules.TimeWindows = append(rules.TimeWindows, window)
+		return nil
+	})
+	fs.Func("expression-rule", "add the result of an `expression` such as 'total > 50 ? 20 : 0' to the points (variables: total, itemCount, day, hour)", func(value string) error {
+		if err := configureExpression(value); err != nil {
+			return err
+		}
+		rules.ExpressionRule = value
+		return nil
+	})
+}
@@ -0,0 +1,49 @@
+package main
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// csvReceiptColumns are the leading columns of a CSV receipt. They are
+// followed by a description and price column for every item.
+var csvReceiptColumns = []string{"retailer", "purchaseDate", "purchaseTime", "total"}
+
+// decodeCSVReceipt reads a receipt sent as a single CSV record such as
+//
+//	Target,2022-01-01,13:01,6.49,Mountain Dew 12PK,6.49
+//
+// and validates it like decodeReceipt.
+func decodeCSVReceipt(r io.Reader, cfg Config) (Receipt, error) {
+	var receipt Receipt
+
+	reader := csv.NewReader(r)
+	// Receipts have any number of items, so records differ in length.
+	reader.FieldsPerRecord = -1
+	record, err := reader.Read()
+	if err != nil {
+		return receipt, fmt.Errorf("reading CSV receipt: %w", err)
+	}
+	if _, err := reader.Read(); err != io.EOF {
+		return receipt, errors.New("a CSV receipt must be a single record")
+	}
+
+	items := len(record) - len(csvReceiptColumns)
+	if items < 0 || items%2 != 0 {
+		return receipt, &fieldError{Field: "items", Reason: "every item needs a description and a price column"}
+	}
+
+	receipt.Retailer = record[0]
+	receipt.PurchaseDate = record[1]
+	receipt.PurchaseTime = record[2]
+	receipt.Total = record[3]
+	receipt.Items = []Item{}
+	for i := len(csvReceiptColumns); i < len(record); i += 2 {
+		receipt.Items = append(receipt.Items, Item{ShortDescription: record[i], Price: record[i+1]})
+	}
+
+	err = prepareReceipt(&receipt, cfg)
+	return receipt, err
+}
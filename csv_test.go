@@ -0,0 +1,53 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+)
+
+func TestProcessCSVReceipt(t *testing.T) {
+	target := `Target,2022-01-01,13:01,35.35,Mountain Dew 12PK,6.49,Emils Cheese Pizza,12.25,Knorr Creamy Chicken,1.26,Doritos Nacho Cheese,3.35,"   Klarbrunn 12-PK 12 FL OZ  ",12.00` + "\n"
+	tests := []struct {
+		name string
+		body string
+		// points are those of the stored receipt, or -1 if it is rejected.
+		points int64
+		field  string
+	}{
+		{"target", target, 28, ""},
+		{"without a trailing newline", target[:len(target)-1], 28, ""},
+		{"quoted comma", `"Shop, Inc.",2022-01-02,08:00,1.00,ab,1.00`, 82, ""},
+		{"no items", `Target,2022-01-01,13:01,35.35`, -1, "items"},
+		{"price missing", `Target,2022-01-01,13:01,6.49,Mountain Dew 12PK`, -1, "items"},
+		{"too few columns", `Target,2022-01-01`, -1, "items"},
+		{"invalid total", `Target,2022-01-01,13:01,6.4,Mountain Dew 12PK,6.49`, -1, "total"},
+		{"unbalanced quote", `"Target,2022-01-01,13:01,6.49,Mountain Dew 12PK,6.49`, -1, ""},
+		{"two records", target + target, -1, ""},
+		{"empty", "", -1, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := newServer(testConfig(t), newMemoryStore()).router()
+			w := serve(r, http.MethodPost, "/receipts/process", tt.body, "Content-Type", "text/csv")
+			if tt.points < 0 {
+				var body struct {
+					Field string `json:"field"`
+				}
+				json.Unmarshal(w.Body.Bytes(), &body)
+				if w.Code != http.StatusBadRequest || body.Field != tt.field {
+					t.Errorf("status = %d, want 400 naming %q: %s", w.Code, tt.field, w.Body)
+				}
+				return
+			}
+
+			var response ReceiptResponse
+			if err := json.Unmarshal(w.Body.Bytes(), &response); err != nil || w.Code != http.StatusOK {
+				t.Fatalf("status = %d: %s", w.Code, w.Body)
+			}
+			if points := pointsOf(t, r, response.ID); points.Points != tt.points {
+				t.Errorf("points = %d, want %d", points.Points, tt.points)
+			}
+		})
+	}
+}
@@ -0,0 +1,42 @@
+package main
+
+import (
+	"encoding/base64"
+	"errors"
+	"sort"
+	"strings"
+	"time"
+)
+
+var errCursorInvalid = errors.New("invalid cursor")
+
+// encodeCursor returns an opaque cursor pointing just past the receipt in the
+// List order, which is by creation time, then ID.
+func encodeCursor(stored StoredReceipt) string {
+	key := stored.CreatedAt.UTC().Format(time.RFC3339Nano) + " " + stored.ID
+	return base64.RawURLEncoding.EncodeToString([]byte(key))
+}
+
+// decodeCursor returns the creation time and ID a cursor from encodeCursor
+// points past, as a StoredReceipt for comparing with storedBefore.
+func decodeCursor(cursor string) (StoredReceipt, error) {
+	key, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return StoredReceipt{}, errCursorInvalid
+	}
+	created, id, ok := strings.Cut(string(key), " ")
+	if !ok {
+		return StoredReceipt{}, errCursorInvalid
+	}
+	createdAt, err := time.Parse(time.RFC3339Nano, created)
+	if err != nil {
+		return StoredReceipt{}, errCursorInvalid
+	}
+	return StoredReceipt{ID: id, CreatedAt: createdAt}, nil
+}
+
+// afterCursor returns the index of the first receipt past the cursor in a
+// List result. The receipt the cursor was made from needn't exist anymore.
+func afterCursor(receipts []StoredReceipt, cursor StoredReceipt) int {
+	return sort.Search(len(receipts), func(i int) bool { return storedBefore(cursor, receipts[i]) })
+}
@@ -0,0 +1,21 @@
+package main
+
+import (
+	"net/http/pprof"
+
+	"github.com/gin-gonic/gin"
+)
+
+// registerPprof exposes the net/http/pprof handlers under /debug/pprof.
+func registerPprof(r *gin.Engine) {
+	group := r.Group("/debug/pprof")
+	group.GET("/", gin.WrapF(pprof.Index))
+	group.GET("/cmdline", gin.WrapF(pprof.Cmdline))
+	group.GET("/profile", gin.WrapF(pprof.Profile))
+	group.GET("/symbol", gin.WrapF(pprof.Symbol))
+	group.POST("/symbol", gin.WrapF(pprof.Symbol))
+	group.GET("/trace", gin.WrapF(pprof.Trace))
+
+	// Named profiles such as heap or goroutine are served by the index handler.
+	group.GET("/:profile", gin.WrapF(pprof.Index))
+}
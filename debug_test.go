@@ -0,0 +1,28 @@
+package main
+
+import (
+	"net/http"
+	"testing"
+)
+
+func TestPprofEndpoints(t *testing.T) {
+	paths := []string{"/debug/pprof/", "/debug/pprof/cmdline", "/debug/pprof/heap", "/debug/pprof/goroutine"}
+	tests := []struct {
+		name   string
+		args   []string
+		status int
+	}{
+		{"default", nil, http.StatusNotFound},
+		{"enabled", []string{"-pprof"}, http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := newServer(testConfig(t, tt.args...), newMemoryStore()).router()
+			for _, path := range paths {
+				if w := serve(r, http.MethodGet, path, ""); w.Code != tt.status {
+					t.Errorf("GET %s: status = %d, want %d", path, w.Code, tt.status)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,289 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+	"github.com/go-playground/validator/v10"
+	"github.com/shopspring/decimal"
+)
+
+// unknownFieldError reports a JSON field that is not part of the receipt schema.
+type unknownFieldError struct {
+	Field string
+}
+
+func (e *unknownFieldError) Error() string {
+	return "unknown field " + strconv.Quote(e.Field)
+}
+
+// bindReceipt decodes and validates the receipt in the request body. In strict
+// mode fields that are not part of the schema are rejected instead of ignored.
+// Configured defaults are filled in before validation. text/csv bodies are
+// read with decodeCSVReceipt instead, and multipart/form-data uploads from
+// their JSON receipt file part.
+func bindReceipt(c *gin.Context, cfg Config) (Receipt, error) {
+	switch c.ContentType() {
+	case "text/csv":
+		return decodeCSVReceipt(c.Request.Body, cfg)
+	case "multipart/form-data":
+		header, err := c.FormFile("receipt")
+		if err != nil {
+			return Receipt{}, &fieldError{Field: "receipt", Reason: "a receipt file part is required"}
+		}
+		file, err := header.Open()
+		if err != nil {
+			return Receipt{}, err
+		}
+		defer file.Close()
+		return decodeReceipt(file, cfg)
+	}
+	return decodeReceipt(c.Request.Body, cfg)
+}
+
+// decodeReceipt reads a receipt from r, see bindReceipt.
+func decodeReceipt(r io.Reader, cfg Config) (Receipt, error) {
+	var receipt Receipt
+
+	if cfg.LenientMoney || cfg.MaxJSONDepth > 0 || cfg.MaxJSONArray > 0 {
+		data, err := io.ReadAll(r)
+		if err != nil {
+			return receipt, err
+		}
+		if err := checkJSONLimits(data, cfg.MaxJSONDepth, cfg.MaxJSONArray); err != nil {
+			return receipt, err
+		}
+		if cfg.LenientMoney {
+			data = quoteMoneyNumbers(data)
+		}
+		r = bytes.NewReader(data)
+	}
+
+	decoder := json.NewDecoder(r)
+	if cfg.Strict {
+		decoder.DisallowUnknownFields()
+	}
+	if err := decoder.Decode(&receipt); err != nil {
+		return receipt, unknownField(err)
+	}
+
+	err := prepareReceipt(&receipt, cfg)
+	return receipt, err
+}
+
+// unknownField turns the error encoding/json reports for a field rejected by
+// DisallowUnknownFields into an unknownFieldError. Other errors are returned
+// as they are.
+func unknownField(err error) error {
+	// encoding/json has no typed error for unknown fields, only this message.
+	if field, ok := strings.CutPrefix(err.Error(), "json: unknown field "); ok {
+		if unquoted, err := strconv.Unquote(field); err == nil {
+			field = unquoted
+		}
+		return &unknownFieldError{Field: field}
+	}
+	return err
+}
+
+// decodeEnvelope decodes a request wrapping receipts, like a simulation, into
+// v. The receipts are json.RawMessage fields of v, to be read with
+// decodeReceipt so they get the same checks as processed ones. The envelope
+// itself may be nested one level deeper than a receipt, and has its unknown
+// fields rejected in strict mode too.
+func decodeEnvelope(r io.Reader, cfg Config, v any) error {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return err
+	}
+	maxDepth := cfg.MaxJSONDepth
+	if maxDepth > 0 {
+		maxDepth++
+	}
+	if err := checkJSONLimits(data, maxDepth, 0); err != nil {
+		return err
+	}
+
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	if cfg.Strict {
+		decoder.DisallowUnknownFields()
+	}
+	if err := decoder.Decode(v); err != nil {
+		return unknownField(err)
+	}
+	return nil
+}
+
+// prepareReceipt fills in the defaults of a decoded receipt, rounds its
+// amounts if configured, validates it and normalizes the retailer name. An
+// empty, but present, items list is only accepted with AllowEmptyItems.
+func prepareReceipt(receipt *Receipt, cfg Config) error {
+	applyDefaults(receipt, cfg.Defaults)
+
+	if cfg.LenientTime {
+		receipt.PurchaseTime = normalizeTime(receipt.PurchaseTime)
+	}
+
+	if places, ok := minorUnits(receipt.Currency); ok && cfg.RoundMoney != roundMoneyNone {
+		receipt.Total = roundMoney(receipt.Total, places, cfg.RoundMoney)
+		receipt.Discount = roundMoney(receipt.Discount, places, cfg.RoundMoney)
+		for i := range receipt.Items {
+			receipt.Items[i].Price = roundMoney(receipt.Items[i].Price, places, cfg.RoundMoney)
+		}
+	}
+
+	if cfg.AllowEmptyItems && receipt.Items != nil && len(receipt.Items) == 0 {
+		// Skip the min=1 of the items, which then have nothing to validate.
+		v := binding.Validator.Engine().(*validator.Validate)
+		if err := v.StructExcept(receipt, "Items"); err != nil {
+			return err
+		}
+	} else if err := binding.Validator.ValidateStruct(receipt); err != nil {
+		return err
+	}
+
+	normalizeRetailer(receipt, cfg.NormalizeRetailer)
+	return nil
+}
+
+// twelveHourLayouts are the 12-hour purchase times normalizeTime understands.
+var twelveHourLayouts = []string{"3:04 PM", "3:04PM"}
+
+// normalizeTime rewrites a 12-hour purchase time such as "02:33 PM" or
+// "2:33pm" to the 24-hour "14:33". Anything else is returned as is, for
+// validation to accept or reject.
+func normalizeTime(value string) string {
+	upper := strings.ToUpper(strings.TrimSpace(value))
+	for _, layout := range twelveHourLayouts {
+		if t, err := time.Parse(layout, upper); err == nil {
+			return t.Format("15:04")
+		}
+	}
+	return value
+}
+
+// Retailer normalization modes for Config.NormalizeRetailer.
+const (
+	normalizeNone       = ""
+	normalizeWhitespace = "whitespace"
+	normalizeLowercase  = "lowercase"
+)
+
+// normalizeRetailer rewrites the retailer name according to the mode, keeping
+// the name as sent in RetailerOriginal. The original is set by the server only,
+// so a client can't fake it.
+func normalizeRetailer(receipt *Receipt, mode string) {
+	receipt.RetailerOriginal = ""
+	if mode == normalizeNone {
+		return
+	}
+
+	normalized := strings.Join(strings.Fields(receipt.Retailer), " ")
+	if mode == normalizeLowercase {
+		normalized = strings.ToLower(normalized)
+	}
+	if normalized != receipt.Retailer {
+		receipt.RetailerOriginal = receipt.Retailer
+		receipt.Retailer = normalized
+	}
+}
+
+// defaultableFields lists the receipt fields a server-side default can be configured for.
+var defaultableFields = map[string]func(*Receipt) *string{
+	"retailer":     func(r *Receipt) *string { return &r.Retailer },
+	"purchaseDate": func(r *Receipt) *string { return &r.PurchaseDate },
+	"purchaseTime": func(r *Receipt) *string { return &r.PurchaseTime },
+	"currency":     func(r *Receipt) *string { return &r.Currency },
+}
+
+// parseDefault parses a "field=value" flag value into the defaults map.
+func parseDefault(defaults map[string]string, value string) error {
+	field, fieldValue, ok := strings.Cut(value, "=")
+	if !ok {
+		return fmt.Errorf("expected field=value, got %q", value)
+	}
+	if _, ok := defaultableFields[field]; !ok {
+		return fmt.Errorf("no default can be configured for field %q", field)
+	}
+
+	defaults[field] = fieldValue
+	return nil
+}
+
+// applyDefaults fills in the configured defaults for fields missing from the receipt.
+func applyDefaults(receipt *Receipt, defaults map[string]string) {
+	for field, value := range defaults {
+		target := defaultableFields[field](receipt)
+		if *target == "" {
+			log.Printf("receipt has no %s, using the default %q\n", field, value)
+			*target = value
+		}
+	}
+}
+
+// quoteMoneyNumbers rewrites a total, discount or item price sent as a JSON
+// number, such as 6.5, into the canonical money string for the receipt's
+// currency, "6.50". Anything it can't make sense of is left for decoding and
+// validation to reject.
+func quoteMoneyNumbers(data []byte) []byte {
+	var doc map[string]json.RawMessage
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return data
+	}
+
+	var currency string
+	json.Unmarshal(doc["currency"], &currency)
+	places, ok := minorUnits(currency)
+	if !ok {
+		return data
+	}
+
+	for _, field := range []string{"total", "discount"} {
+		if amount, ok := doc[field]; ok {
+			doc[field] = quoteMoney(amount, places)
+		}
+	}
+
+	var items []map[string]json.RawMessage
+	if err := json.Unmarshal(doc["items"], &items); err == nil {
+		for _, item := range items {
+			if price, ok := item["price"]; ok {
+				item["price"] = quoteMoney(price, places)
+			}
+		}
+		if encoded, err := json.Marshal(items); err == nil {
+			doc["items"] = encoded
+		}
+	}
+
+	rewritten, err := json.Marshal(doc)
+	if err != nil {
+		return data
+	}
+	return rewritten
+}
+
+// quoteMoney returns a JSON number as a money string with the given decimal
+// places, if it doesn't have more than that. Other values are returned as is.
+func quoteMoney(raw json.RawMessage, places int32) json.RawMessage {
+	amount, err := decimal.NewFromString(string(bytes.TrimSpace(raw)))
+	if err != nil {
+		return raw
+	}
+	money := amount.String()
+	if amount.Exponent() >= -places {
+		money = amount.StringFixed(places)
+	}
+	quoted, err := json.Marshal(money)
+	if err != nil {
+		return raw
+	}
+	return quoted
+}
@@ -0,0 +1,280 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"mime/multipart"
+	"net/http"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestStrictUnknownFields(t *testing.T) {
+	receipt := example(t, "simple-receipt.json")
+	tests := []struct {
+		name    string
+		receipt string
+		field   string
+	}{
+		{"known fields", receipt, ""},
+		{"unknown field", strings.Replace(receipt, "{", `{"cashier": "Ann",`, 1), "cashier"},
+		{"unknown item field", strings.Replace(receipt, `"shortDescription"`, `"sku": "123", "shortDescription"`, 1), "sku"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for _, strict := range []bool{false, true} {
+				var args []string
+				if strict {
+					args = append(args, "-strict")
+				}
+				r := newServer(testConfig(t, args...), newMemoryStore()).router()
+				w := serve(r, http.MethodPost, "/receipts/process", tt.receipt)
+
+				rejected := strict && tt.field != ""
+				if rejected {
+					if w.Code != http.StatusBadRequest || !strings.Contains(w.Body.String(), tt.field) {
+						t.Errorf("strict: status = %d, want 400 naming %s: %s", w.Code, tt.field, w.Body)
+					}
+				} else if w.Code != http.StatusOK {
+					t.Errorf("strict %v: status = %d, want 200: %s", strict, w.Code, w.Body)
+				}
+			}
+		})
+	}
+}
+
+func TestDefaults(t *testing.T) {
+	// The simple receipt without its purchaseTime.
+	partial := strings.Replace(example(t, "simple-receipt.json"), `"purchaseTime": "13:13",`, "", 1)
+	tests := []struct {
+		name   string
+		args   []string
+		status int
+		time   string
+	}{
+		{"without defaults", nil, http.StatusBadRequest, ""},
+		{"with a default", []string{"-default", "purchaseTime=12:00"}, http.StatusOK, "12:00"},
+		{"with another default", []string{"-default", "currency=USD"}, http.StatusBadRequest, ""},
+		{"with an invalid default", []string{"-default", "purchaseTime=noon"}, http.StatusBadRequest, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var logged bytes.Buffer
+			log.SetOutput(&logged)
+			t.Cleanup(func() { log.SetOutput(os.Stderr) })
+
+			store := newMemoryStore()
+			r := newServer(testConfig(t, tt.args...), store).router()
+			w := serve(r, http.MethodPost, "/receipts/process", partial)
+			if w.Code != tt.status {
+				t.Fatalf("status = %d, want %d: %s", w.Code, tt.status, w.Body)
+			}
+			if w.Code != http.StatusOK {
+				return
+			}
+
+			var response ReceiptResponse
+			json.Unmarshal(w.Body.Bytes(), &response)
+			stored, err := store.Get(context.Background(), response.ID)
+			if err != nil || stored.Receipt.PurchaseTime != tt.time {
+				t.Errorf("stored purchaseTime %q, %v, want %q", stored.Receipt.PurchaseTime, err, tt.time)
+			}
+			if !strings.Contains(logged.String(), `receipt has no purchaseTime, using the default "12:00"`) {
+				t.Errorf("the default wasn't logged: %s", logged.String())
+			}
+		})
+	}
+
+	if _, err := parseConfig([]string{"-default", "total=1.00"}); err == nil {
+		t.Error("a default for total was accepted")
+	}
+}
+
+func TestNormalizeRetailer(t *testing.T) {
+	tests := []struct {
+		mode     string
+		sent     string
+		retailer string
+		original string
+	}{
+		{"", "  M&M   Corner Market ", "  M&M   Corner Market ", ""},
+		{"whitespace", "  M&M   Corner Market ", "M&M Corner Market", "  M&M   Corner Market "},
+		{"whitespace", "M&M Corner Market", "M&M Corner Market", ""},
+		{"lowercase", " M&M\tCorner  Market", "m&m corner market", " M&M\tCorner  Market"},
+		{"lowercase", "m&m corner market", "m&m corner market", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.mode+" "+tt.sent, func(t *testing.T) {
+			sent, _ := json.Marshal(tt.sent)
+			// A client can't set the original name itself.
+			receipt := strings.Replace(example(t, "M&M-receipt.json"), `"retailer": "M&M Corner Market",`, `"retailer": `+string(sent)+`, "retailerOriginal": "fake",`, 1)
+			store := newMemoryStore()
+			r := newServer(testConfig(t, "-normalize-retailer", tt.mode), store).router()
+			process(t, r, receipt)
+			stored, err := store.List(context.Background())
+			if err != nil || len(stored) != 1 {
+				t.Fatalf("stored %d receipts, %v", len(stored), err)
+			}
+			if got := stored[0].Receipt; got.Retailer != tt.retailer || got.RetailerOriginal != tt.original {
+				t.Errorf("stored retailer %q, original %q, want %q and %q", got.Retailer, got.RetailerOriginal, tt.retailer, tt.original)
+			}
+
+			// The name is scored as sent, like the example's 109 points.
+			var breakdown BreakdownResponse
+			json.Unmarshal(serve(r, http.MethodGet, "/receipts/"+stored[0].ID+"/breakdown", "").Body.Bytes(), &breakdown)
+			if breakdown.Points != 109 || len(breakdown.Breakdown) == 0 || !strings.Contains(breakdown.Breakdown[0].Reason, `"`+tt.sent+`"`) {
+				t.Errorf("points %d with %+v, want 109 for the name as sent", breakdown.Points, breakdown.Breakdown)
+			}
+		})
+	}
+}
+
+func TestLenientMoney(t *testing.T) {
+	receipt := func(currency, total, price string) string {
+		return `{"retailer": "Target", "purchaseDate": "2022-01-01", "purchaseTime": "13:01", "currency": "` + currency + `", "total": ` + total + `, "items": [{"shortDescription": "Pepsi", "price": ` + price + `}]}`
+	}
+	tests := []struct {
+		name    string
+		lenient bool
+		body    string
+		// total and price are the decoded amounts, total "" if the receipt is rejected.
+		total, price string
+	}{
+		{"strings", false, receipt("USD", `"6.50"`, `"6.50"`), "6.50", "6.50"},
+		{"strings in lenient mode", true, receipt("USD", `"6.50"`, `"6.50"`), "6.50", "6.50"},
+		{"numbers", false, receipt("USD", `6.50`, `"6.50"`), "", ""},
+		{"number price", false, receipt("USD", `"6.50"`, `6.50`), "", ""},
+		{"numbers in lenient mode", true, receipt("USD", `6.5`, `6.50`), "6.50", "6.50"},
+		{"whole number", true, receipt("USD", `6`, `"6.00"`), "6.00", "6.00"},
+		{"too many decimal places", true, receipt("USD", `6.499`, `"6.49"`), "", ""},
+		{"no minor units", true, receipt("JPY", `1200`, `1200`), "1200", "1200"},
+		{"decimals without minor units", true, receipt("JPY", `1200.5`, `"1200"`), "", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var args []string
+			if tt.lenient {
+				args = append(args, "-lenient-money")
+			}
+			got, err := decodeReceipt(strings.NewReader(tt.body), testConfig(t, args...))
+			if tt.total == "" {
+				if err == nil {
+					t.Errorf("accepted with total %q", got.Total)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got.Total != tt.total || got.Items[0].Price != tt.price {
+				t.Errorf("total %q and price %q, want %q and %q", got.Total, got.Items[0].Price, tt.total, tt.price)
+			}
+		})
+	}
+}
+
+func TestLenientTime(t *testing.T) {
+	tests := []struct {
+		time    string
+		lenient bool
+		// stored is the purchase time decoded, "" if the receipt is rejected.
+		stored    string
+		afternoon int64
+	}{
+		{"14:33", false, "14:33", 10},
+		{"14:33", true, "14:33", 10},
+		{"02:33 PM", false, "", 0},
+		{"02:33 PM", true, "14:33", 10},
+		{"2:33pm", true, "14:33", 10},
+		{"02:33 AM", true, "02:33", 0},
+		{"12:05 AM", true, "00:05", 0},
+		{"12:05 PM", true, "12:05", 0},
+		{"04:00 PM", true, "16:00", 0},
+		{"13:33 PM", true, "", 0},
+	}
+	for _, tt := range tests {
+		t.Run(fmt.Sprintf("%s %t", tt.time, tt.lenient), func(t *testing.T) {
+			var args []string
+			if tt.lenient {
+				args = append(args, "-lenient-time")
+			}
+			body := strings.Replace(receiptWith("1.00", "1.00"), `"13:13"`, `"`+tt.time+`"`, 1)
+			got, err := decodeReceipt(strings.NewReader(body), testConfig(t, args...))
+			if tt.stored == "" {
+				if err == nil {
+					t.Errorf("accepted as %q", got.PurchaseTime)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got.PurchaseTime != tt.stored {
+				t.Errorf("purchase time = %q, want %q", got.PurchaseTime, tt.stored)
+			}
+			if points := awarded(t, got, testConfig(t).Rules)["afternoonTime"]; points != tt.afternoon {
+				t.Errorf("afternoonTime = %d, want %d", points, tt.afternoon)
+			}
+		})
+	}
+}
+
+// multipartBody returns a multipart/form-data body with a file part for each
+// of the given field and content pairs, and its content type.
+func multipartBody(t *testing.T, parts ...string) (string, string) {
+	t.Helper()
+	var body bytes.Buffer
+	w := multipart.NewWriter(&body)
+	for i := 0; i+1 < len(parts); i += 2 {
+		part, err := w.CreateFormFile(parts[i], "receipt.json")
+		if err != nil {
+			t.Fatal(err)
+		}
+		part.Write([]byte(parts[i+1]))
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return body.String(), w.FormDataContentType()
+}
+
+func TestProcessMultipartReceipt(t *testing.T) {
+	tests := []struct {
+		name  string
+		parts []string
+		// points are those of the stored receipt, or -1 if it is rejected.
+		points int64
+	}{
+		{"target", []string{"receipt", example(t, "target-receipt.json")}, 28},
+		{"M&M", []string{"receipt", example(t, "M&M-receipt.json")}, 109},
+		{"other parts", []string{"notes", "hello", "receipt", example(t, "target-receipt.json")}, 28},
+		{"no receipt part", []string{"file", example(t, "target-receipt.json")}, -1},
+		{"no parts", nil, -1},
+		{"invalid receipt", []string{"receipt", `{"retailer": "Target"}`}, -1},
+		{"not JSON", []string{"receipt", "retailer,total"}, -1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := newServer(testConfig(t), newMemoryStore()).router()
+			body, contentType := multipartBody(t, tt.parts...)
+			w := serve(r, http.MethodPost, "/receipts/process", body, "Content-Type", contentType)
+			if tt.points < 0 {
+				if w.Code != http.StatusBadRequest {
+					t.Errorf("status = %d, want 400: %s", w.Code, w.Body)
+				}
+				return
+			}
+			var response ReceiptResponse
+			if err := json.Unmarshal(w.Body.Bytes(), &response); err != nil || w.Code != http.StatusOK {
+				t.Fatalf("status = %d: %s", w.Code, w.Body)
+			}
+			if points := pointsOf(t, r, response.ID); points.Points != tt.points {
+				t.Errorf("points = %d, want %d", points.Points, tt.points)
+			}
+		})
+	}
+}
@@ -0,0 +1,71 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"sync"
+	"time"
+)
+
+// dedupeWindow remembers recently processed receipts per client, so that an
+// identical receipt resubmitted shortly after, e.g. by a double click, gets
+// the ID of the first one instead of being stored twice.
+type dedupeWindow struct {
+	window time.Duration
+	// now is the clock entries are aged by.
+	now func() time.Time
+
+	mu     sync.Mutex
+	recent map[string]dedupeEntry
+}
+
+type dedupeEntry struct {
+	id string
+	at time.Time
+}
+
+func newDedupeWindow(window time.Duration) *dedupeWindow {
+	return &dedupeWindow{window: window, now: time.Now, recent: make(map[string]dedupeEntry)}
+}
+
+// process returns the ID of an identical receipt the client sent within the
+// window and true, or stores the receipt with insert and remembers its ID.
+// The lock is held while inserting, so concurrent duplicates can't both be stored.
+func (d *dedupeWindow) process(clientIP string, receipt Receipt, insert func() (string, error)) (string, bool, error) {
+	key, err := dedupeKey(clientIP, receipt)
+	if err != nil {
+		id, err := insert()
+		return id, false, err
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	now := d.now()
+	for k, entry := range d.recent {
+		if now.Sub(entry.at) >= d.window {
+			delete(d.recent, k)
+		}
+	}
+	if entry, ok := d.recent[key]; ok {
+		return entry.id, true, nil
+	}
+
+	id, err := insert()
+	if err != nil {
+		return "", false, err
+	}
+	d.recent[key] = dedupeEntry{id: id, at: now}
+	return id, false, nil
+}
+
+// dedupeKey identifies a receipt sent by a client.
+func dedupeKey(clientIP string, receipt Receipt) (string, error) {
+	data, err := json.Marshal(receipt)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(append([]byte(clientIP+"\n"), data...))
+	return hex.EncodeToString(sum[:]), nil
+}
@@ -0,0 +1,91 @@
+package main
+
+import (
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestDedupeWindow(t *testing.T) {
+	receipt := Receipt{Retailer: "Target", PurchaseDate: "2022-01-01", PurchaseTime: "13:01", Total: "1.25", Items: []Item{{ShortDescription: "Pepsi", Price: "1.25"}}}
+	other := receipt
+	other.Total = "1.26"
+
+	type submission struct {
+		// after is how long after the previous submission this one is sent.
+		after    time.Duration
+		clientIP string
+		receipt  Receipt
+		// want is the index of the submission whose ID is returned.
+		want int
+	}
+	tests := []struct {
+		name        string
+		submissions []submission
+	}{
+		{"within the window", []submission{
+			{0, "192.0.2.1", receipt, 0},
+			{time.Second, "192.0.2.1", receipt, 0},
+		}},
+		{"outside the window", []submission{
+			{0, "192.0.2.1", receipt, 0},
+			{5 * time.Second, "192.0.2.1", receipt, 1},
+		}},
+		{"resent within the window of the new copy", []submission{
+			{0, "192.0.2.1", receipt, 0},
+			{6 * time.Second, "192.0.2.1", receipt, 1},
+			{4 * time.Second, "192.0.2.1", receipt, 1},
+		}},
+		{"window is not extended by duplicates", []submission{
+			{0, "192.0.2.1", receipt, 0},
+			{3 * time.Second, "192.0.2.1", receipt, 0},
+			{3 * time.Second, "192.0.2.1", receipt, 2},
+		}},
+		{"another client", []submission{
+			{0, "192.0.2.1", receipt, 0},
+			{time.Second, "192.0.2.2", receipt, 1},
+		}},
+		{"another receipt", []submission{
+			{0, "192.0.2.1", receipt, 0},
+			{time.Second, "192.0.2.1", other, 1},
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d := newDedupeWindow(5 * time.Second)
+			clock := time.Date(2022, 1, 1, 13, 0, 0, 0, time.UTC)
+			d.now = func() time.Time { return clock }
+
+			for i, s := range tt.submissions {
+				clock = clock.Add(s.after)
+				id, duplicate, err := d.process(s.clientIP, s.receipt, func() (string, error) {
+					return strconv.Itoa(i), nil
+				})
+				if err != nil {
+					t.Fatal(err)
+				}
+				if want := strconv.Itoa(s.want); id != want || duplicate != (s.want != i) {
+					t.Errorf("submission %d got ID %s, duplicate %v, want the ID of %s", i, id, duplicate, want)
+				}
+			}
+		})
+	}
+}
+
+func TestDedupeWindowDisabled(t *testing.T) {
+	tests := []struct {
+		args []string
+		same bool
+	}{
+		{nil, false},
+		{[]string{"-dedupe-window", "1m"}, true},
+	}
+	for _, tt := range tests {
+		r := newServer(testConfig(t, tt.args...), newMemoryStore()).router()
+		receipt := example(t, "target-receipt.json")
+		first, second := process(t, r, receipt), process(t, r, receipt)
+		if (first == second) != tt.same {
+			t.Errorf("%v: resubmitting got IDs %s and %s", tt.args, first, second)
+		}
+	}
+}
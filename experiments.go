@@ -0,0 +1,82 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// experimentalRulesHeader lists rules to switch on for a single request in dev
+// mode, e.g. "palindrome,digitSum" or "palindrome=7".
+const experimentalRulesHeader = "X-Experimental-Rules"
+
+// experimentalRule switches on an optional rule with the given points, or
+// defaultPoints when the header doesn't name any.
+type experimentalRule struct {
+	defaultPoints int64
+	enable        func(rules *RulesConfig, points int64)
+}
+
+// experimentalRules are the optional rules the header can switch on, by the
+// name used in the header.
+var experimentalRules = map[string]experimentalRule{
+	"digitSum":      {enable: func(rules *RulesConfig, _ int64) { rules.DigitSum = true }},
+	"distinctItems": {defaultPoints: 1, enable: func(rules *RulesConfig, points int64) { rules.DistinctItemPoints = points }},
+	"firstOfDay":    {defaultPoints: 5, enable: func(rules *RulesConfig, points int64) { rules.FirstOfDayBonus = points }},
+	"palindrome":    {defaultPoints: 10, enable: func(rules *RulesConfig, points int64) { rules.PalindromeBonus = points }},
+	"streak":        {defaultPoints: 10, enable: func(rules *RulesConfig, points int64) { rules.StreakBonus = points }},
+}
+
+// overlayExperimentalRules returns base with the rules in the header value
+// switched on. Only the fields of those rules change, so base's maps and
+// slices can be shared.
+func overlayExperimentalRules(base RulesConfig, header string) (RulesConfig, error) {
+	rules := base
+	for _, part := range strings.Split(header, ",") {
+		name, value, hasPoints := strings.Cut(strings.TrimSpace(part), "=")
+		if name == "" {
+			continue
+		}
+		rule, ok := experimentalRules[name]
+		if !ok {
+			return RulesConfig{}, fmt.Errorf("unknown experimental rule %q", name)
+		}
+
+		points := rule.defaultPoints
+		if hasPoints {
+			parsed, err := strconv.ParseInt(strings.TrimSpace(value), 10, 64)
+			if err != nil {
+				return RulesConfig{}, fmt.Errorf("invalid points %q for experimental rule %q", value, name)
+			}
+			points = parsed
+		}
+		rule.enable(&rules, points)
+	}
+	return rules, nil
+}
+
+// requestRules returns the rules to score the request's receipt with: the
+// server's, with the experimental rules of the header on top in dev mode. It
+// reports whether any were applied, in which case the points mustn't be
+// cached. A bad header has been answered with a 400 when ok is false.
+func (s *server) requestRules(c *gin.Context) (rules RulesConfig, experimental, ok bool) {
+	header := c.GetHeader(experimentalRulesHeader)
+	if !s.cfg.Dev || strings.TrimSpace(header) == "" {
+		return s.cfg.Rules, false, true
+	}
+
+	rules, err := overlayExperimentalRules(s.cfg.Rules, header)
+	if err == nil {
+		err = rules.validate()
+	}
+	if err != nil {
+		body := errorBody(c, codeRulesInvalid)
+		body["reason"] = err.Error()
+		c.JSON(http.StatusBadRequest, body)
+		return RulesConfig{}, false, false
+	}
+	return rules, true, true
+}
@@ -0,0 +1,60 @@
+package main
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestExperimentalRulesHeader(t *testing.T) {
+	receipt := strings.Replace(example(t, "target-receipt.json"), `"Target"`, `"Otto"`, 1)
+	tests := []struct {
+		name   string
+		dev    bool
+		header string
+		// flags configure a server scoring like the header should.
+		flags  []string
+		status int
+	}{
+		{"palindrome", true, "palindrome", []string{"-palindrome-bonus", "10"}, http.StatusOK},
+		{"palindrome with points", true, "palindrome=7", []string{"-palindrome-bonus", "7"}, http.StatusOK},
+		{"several rules", true, "palindrome, digitSum", []string{"-palindrome-bonus", "10", "-digit-sum"}, http.StatusOK},
+		{"outside dev mode", false, "palindrome", nil, http.StatusOK},
+		{"unknown rule", true, "palindrome,nope", nil, http.StatusBadRequest},
+		{"invalid points", true, "palindrome=x", nil, http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var args []string
+			if tt.dev {
+				args = append(args, "-dev")
+			}
+			r := newServer(testConfig(t, args...), newMemoryStore()).router()
+			id := process(t, r, receipt)
+			base := pointsOf(t, r, id).Points
+
+			w := serve(r, http.MethodGet, "/receipts/"+id+"/points", "", experimentalRulesHeader, tt.header)
+			if w.Code != tt.status {
+				t.Fatalf("status = %d, want %d: %s", w.Code, tt.status, w.Body)
+			}
+			if tt.status != http.StatusOK {
+				return
+			}
+
+			want := base
+			if tt.flags != nil {
+				flagged := newServer(testConfig(t, tt.flags...), newMemoryStore()).router()
+				want = pointsOf(t, flagged, process(t, flagged, receipt)).Points
+				if want == base {
+					t.Fatalf("%v doesn't change the points", tt.flags)
+				}
+			}
+			if got := pointsOf(t, r, id, experimentalRulesHeader, tt.header).Points; got != want {
+				t.Errorf("points with the header = %d, want %d", got, want)
+			}
+			if got := pointsOf(t, r, id).Points; got != base {
+				t.Errorf("points without the header afterwards = %d, want %d", got, base)
+			}
+		})
+	}
+}
@@ -0,0 +1,66 @@
+package main
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ExportedReceipt is a line of the GET /receipts/export output.
+type ExportedReceipt struct {
+	ID        string    `json:"id"`
+	CreatedAt time.Time `json:"createdAt"`
+	Receipt   Receipt   `json:"receipt"`
+	// Image refers to the image attached to the receipt, which isn't exported.
+	Image *ImageReference `json:"image,omitempty"`
+	// Cursor resumes an interrupted export after this receipt.
+	Cursor string `json:"cursor"`
+}
+
+// exportReceipts writes every stored receipt of the requesting user, see
+// visibleTo, as newline delimited JSON, oldest first, or only those past the
+// cursor query parameter. Each line is
+// flushed as it is written, and the export stops as soon as the client goes
+// away.
+func (s *server) exportReceipts(c *gin.Context) {
+	ctx := c.Request.Context()
+
+	var after StoredReceipt
+	if cursor := c.Query("cursor"); cursor != "" {
+		var err error
+		if after, err = decodeCursor(cursor); err != nil {
+			c.JSON(http.StatusBadRequest, errorBody(c, codeInvalidCursor))
+			return
+		}
+	}
+
+	receipts, err := s.store.List(ctx)
+	if err != nil {
+		storeFailed(c, err)
+		return
+	}
+	receipts = visibleTo(receipts, requestUser(c))
+	if !after.CreatedAt.IsZero() {
+		receipts = receipts[afterCursor(receipts, after):]
+	}
+
+	c.Header("Content-Type", "application/x-ndjson")
+	c.Status(http.StatusOK)
+
+	enc := json.NewEncoder(c.Writer)
+	for _, stored := range receipts {
+		if err := ctx.Err(); err != nil {
+			log.Printf("export stopped: %v\n", err)
+			return
+		}
+		line := ExportedReceipt{ID: stored.ID, CreatedAt: stored.CreatedAt, Receipt: stored.Receipt, Image: imageReference(stored), Cursor: encodeCursor(stored)}
+		if err := enc.Encode(line); err != nil {
+			log.Printf("export stopped: %v\n", err)
+			return
+		}
+		c.Writer.Flush()
+	}
+}
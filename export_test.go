@@ -0,0 +1,84 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// cancellingWriter is a client that goes away after reading some lines of the
+// export: it cancels the request once it has been flushed cancelAfter times.
+type cancellingWriter struct {
+	header      http.Header
+	body        bytes.Buffer
+	flushes     int
+	cancelAfter int
+	cancel      context.CancelFunc
+	// failWrites makes writes fail once cancelled, like a closed connection.
+	failWrites bool
+}
+
+func (w *cancellingWriter) Header() http.Header { return w.header }
+
+func (w *cancellingWriter) WriteHeader(int) {}
+
+func (w *cancellingWriter) Write(p []byte) (int, error) {
+	if w.failWrites && w.flushes >= w.cancelAfter {
+		return 0, errors.New("broken pipe")
+	}
+	return w.body.Write(p)
+}
+
+func (w *cancellingWriter) Flush() {
+	w.flushes++
+	if w.flushes == w.cancelAfter {
+		w.cancel()
+	}
+}
+
+func TestExportStopsWhenCancelled(t *testing.T) {
+	store := newMemoryStore()
+	for _, id := range []string{"a", "b", "c", "d", "e"} {
+		receipt := Receipt{Retailer: "Target", PurchaseDate: "2022-01-01", PurchaseTime: "13:01", Total: "1.25", Items: []Item{{ShortDescription: "Pepsi", Price: "1.25"}}}
+		if err := store.Add(context.Background(), id, receipt, nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+	r := newServer(testConfig(t), store).router()
+
+	tests := []struct {
+		name        string
+		cancelAfter int
+		failWrites  bool
+		lines       int
+	}{
+		{"not cancelled", 0, false, 5},
+		{"cancelled after the first line", 1, false, 1},
+		{"cancelled after three lines", 3, false, 3},
+		{"connection closed", 2, true, 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			w := &cancellingWriter{header: http.Header{}, cancelAfter: tt.cancelAfter, cancel: cancel, failWrites: tt.failWrites}
+			if tt.failWrites {
+				// The write fails before the cancellation is noticed.
+				w.cancel = func() {}
+			}
+			req := httptest.NewRequest(http.MethodGet, "/receipts/export", nil).WithContext(ctx)
+			r.ServeHTTP(w, req)
+
+			if lines := strings.Count(w.body.String(), "\n"); lines != tt.lines {
+				t.Errorf("exported %d lines, want %d:\n%s", lines, tt.lines, w.body.String())
+			}
+			if w.flushes != tt.lines {
+				t.Errorf("flushed %d times, want %d", w.flushes, tt.lines)
+			}
+		})
+	}
+}
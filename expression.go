@@ -0,0 +1,124 @@
+package main
+
+import (
+	"fmt"
+	"sync/atomic"
+
+	"github.com/expr-lang/expr"
+	"github.com/expr-lang/expr/ast"
+	"github.com/expr-lang/expr/vm"
+)
+
+// expressionEnv is everything an expression rule can see. It only exposes
+// plain values, so expressions have no way to perform I/O.
+type expressionEnv struct {
+	Total     float64 `expr:"total"`
+	ItemCount int     `expr:"itemCount"`
+	Day       int     `expr:"day"`
+	Hour      int     `expr:"hour"`
+}
+
+// maxExpressionNodes bounds the size of an expression rule.
+const maxExpressionNodes = 1000
+
+// expressionBuiltins are the builtin functions an expression rule may call.
+// They all take constant time, while the others, like map or repeat, can loop
+// over or build values much larger than the expression itself.
+var expressionBuiltins = []string{"abs", "ceil", "floor", "round", "int", "float", "max", "min"}
+
+// loopChecker rejects the parts of an expression that could make it run for
+// longer than its size suggests: ranges, closures and variables, which could
+// double a value once per node. Without them an expression of at most
+// maxExpressionNodes nodes runs in bounded time, so no timeout is needed.
+type loopChecker struct {
+	err error
+}
+
+func (l *loopChecker) Visit(node *ast.Node) {
+	if l.err != nil {
+		return
+	}
+	switch n := (*node).(type) {
+	case *ast.BinaryNode:
+		if n.Operator == ".." {
+			l.err = fmt.Errorf("ranges are not allowed")
+		}
+	case *ast.PredicateNode, *ast.PointerNode:
+		l.err = fmt.Errorf("closures are not allowed")
+	case *ast.VariableDeclaratorNode:
+		l.err = fmt.Errorf("variables are not allowed")
+	}
+}
+
+// compiledExpression is an expression rule along with its compiled program.
+type compiledExpression struct {
+	source  string
+	program *vm.Program
+}
+
+// configuredExpression is the compiled -expression-rule. Only that one is
+// cached: expression rules sent by clients, e.g. with a simulation, are
+// compiled whenever they are used, so they can't fill up memory.
+var configuredExpression atomic.Pointer[compiledExpression]
+
+// configureExpression compiles the -expression-rule and caches its program.
+func configureExpression(source string) error {
+	program, err := compileExpression(source)
+	if err != nil {
+		return err
+	}
+	configuredExpression.Store(&compiledExpression{source: source, program: program})
+	return nil
+}
+
+// compileExpression compiles an expression rule such as `total > 50 ? 20 : 0`.
+func compileExpression(source string) (*vm.Program, error) {
+	var loops loopChecker
+	options := []expr.Option{
+		expr.Env(expressionEnv{}),
+		expr.AsInt64(),
+		expr.MaxNodes(maxExpressionNodes),
+		expr.DisableAllBuiltins(),
+		expr.Patch(&loops),
+	}
+	for _, name := range expressionBuiltins {
+		options = append(options, expr.EnableBuiltin(name))
+	}
+	program, err := expr.Compile(source, options...)
+	if err == nil {
+		err = loops.err
+	}
+	if err != nil {
+		return nil, fmt.Errorf("invalid expression rule: %w", err)
+	}
+	return program, nil
+}
+
+// expressionProgram returns the compiled program of an expression rule, which
+// is cached if it is the configured one.
+func expressionProgram(source string) (*vm.Program, error) {
+	if configured := configuredExpression.Load(); configured != nil && configured.source == source {
+		return configured.program, nil
+	}
+	return compileExpression(source)
+}
+
+// evalExpression runs the expression rule against the receipt and returns the points it awards.
+func evalExpression(source string, facts receiptFacts) (int64, error) {
+	program, err := expressionProgram(source)
+	if err != nil {
+		return 0, err
+	}
+
+	env := expressionEnv{
+		Total:     facts.total.InexactFloat64(),
+		ItemCount: len(facts.Items),
+		Day:       facts.purchased.Day(),
+		Hour:      facts.purchased.Hour(),
+	}
+	output, err := expr.Run(program, env)
+	if err != nil {
+		return 0, err
+	}
+	return output.(int64), nil
+}
@@ -0,0 +1,79 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/shopspring/decimal"
+)
+
+func TestCompileExpressionRejectsLoops(t *testing.T) {
+	tests := []struct {
+		source string
+		valid  bool
+	}{
+		{"total > 50 ? 20 : 0", true},
+		{"max(itemCount, 3) + round(total)", true},
+		{"sum(1..100000000)", false},
+		{"len(map(1..1000, map(1..1000, #)))", false},
+		{"1 in 1..1000000000", false},
+		{`let s = "aaaa"; let t = s + s; len(t)`, false},
+		{`repeat("a", 1000000000) == "" ? 1 : 0`, false},
+		{"filter([1, 2], # > 1)[0]", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.source, func(t *testing.T) {
+			if _, err := compileExpression(tt.source); (err == nil) != tt.valid {
+				t.Errorf("compiling: %v, want valid %v", err, tt.valid)
+			}
+		})
+	}
+}
+
+func TestEvalExpression(t *testing.T) {
+	tests := []struct {
+		source string
+		total  string
+		points int64
+	}{
+		{"total > 50 ? 20 : 0", "60.00", 20},
+		{"total > 50 ? 20 : 0", "40.00", 0},
+		{"itemCount * 2", "1.00", 2},
+		{"floor(total / 10)", "35.35", 3},
+	}
+	for _, tt := range tests {
+		t.Run(tt.source+" "+tt.total, func(t *testing.T) {
+			facts := receiptFacts{Receipt: Receipt{Items: []Item{{}}}, total: decimal.RequireFromString(tt.total)}
+			points, err := evalExpression(tt.source, facts)
+			if err != nil || points != tt.points {
+				t.Errorf("points = %d, %v, want %d", points, err, tt.points)
+			}
+		})
+	}
+}
+
+func TestExpressionProgramCachesOnlyConfigured(t *testing.T) {
+	defer configuredExpression.Store(configuredExpression.Load())
+	if err := configureExpression("itemCount * 3"); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		source string
+		cached bool
+	}{
+		{"itemCount * 3", true},
+		{"itemCount * 4", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.source, func(t *testing.T) {
+			first, err := expressionProgram(tt.source)
+			if err != nil {
+				t.Fatal(err)
+			}
+			second, _ := expressionProgram(tt.source)
+			if (first == second) != tt.cached {
+				t.Errorf("same program for both uses: %v, want %v", first == second, tt.cached)
+			}
+		})
+	}
+}
@@ -0,0 +1,244 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// persistedReceipt is the on-disk form of a stored receipt.
+type persistedReceipt struct {
+	ID        string    `json:"id"`
+	Receipt   Receipt   `json:"receipt"`
+	CreatedAt time.Time `json:"createdAt"`
+	Version   int       `json:"version"`
+	Breakdown []award   `json:"breakdown,omitempty"`
+	// Image is the attached image, which files saved before images were kept
+	// apart have as Receipt.ImageBase64 instead.
+	Image []byte `json:"image,omitempty"`
+
+	// Points are the cached points, if the receipt was scored, under the
+	// rules with RulesVersion.
+	Points       *int64 `json:"points,omitempty"`
+	RulesVersion string `json:"rulesVersion,omitempty"`
+}
+
+// fileStore is a memoryStore that saves a snapshot of all receipts to a JSON
+// file after every change and loads it back on startup. Cached points are
+// saved along with the version of the rules they were scored under, and only
+// loaded back under the same rules; otherwise they are recomputed on first use.
+type fileStore struct {
+	*memoryStore
+	path         string
+	rulesVersion string
+
+	// saveMu serializes writing the snapshot.
+	saveMu sync.Mutex
+	// pointsPending is set when points were cached after the last snapshot
+	// was taken.
+	pointsPending atomic.Bool
+}
+
+// openFileStore loads the receipts saved at path, if the file exists, along
+// with the points cached for them under the rules with rulesVersion.
+func openFileStore(path, rulesVersion string) (*fileStore, error) {
+	s := &fileStore{memoryStore: newMemoryStore(), path: path, rulesVersion: rulesVersion}
+
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return s, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var receipts []persistedReceipt
+	if err := json.Unmarshal(data, &receipts); err != nil {
+		return nil, fmt.Errorf("loading %s: %w", path, err)
+	}
+	stale := 0
+	for _, r := range receipts {
+		if r.Version == 0 {
+			// Saved before receipts were versioned.
+			r.Version = 1
+		}
+		stored := StoredReceipt{ID: r.ID, Receipt: r.Receipt, CreatedAt: r.CreatedAt, Version: r.Version, Breakdown: r.Breakdown, Image: r.Image}
+		if r.Points != nil {
+			if r.RulesVersion == rulesVersion {
+				stored.Points, stored.Scored = *r.Points, true
+			} else {
+				stale++
+			}
+		}
+		if err := s.memoryStore.put(stored); err != nil {
+			return nil, fmt.Errorf("loading receipt %s: %w", r.ID, err)
+		}
+	}
+	if stale > 0 {
+		log.Printf("The rules changed since %d receipts in %s were scored, their points will be recomputed\n", stale, path)
+	}
+	return s, nil
+}
+
+func (s *fileStore) Add(ctx context.Context, id string, receipt Receipt, breakdown []award) error {
+	if err := s.memoryStore.Add(ctx, id, receipt, breakdown); err != nil {
+		return err
+	}
+
+	if err := s.save(); err != nil {
+		// Don't keep a receipt in memory that would be lost on restart.
+		s.memoryStore.DeleteWhere(context.Background(), func(stored StoredReceipt) bool { return stored.ID == id })
+		return err
+	}
+	return nil
+}
+
+func (s *fileStore) Update(ctx context.Context, id string, receipt Receipt, breakdown []award, version int) (StoredReceipt, error) {
+	previous, updated, err := s.memoryStore.update(ctx, id, receipt, breakdown, version)
+	if err != nil {
+		return StoredReceipt{}, err
+	}
+
+	if err := s.save(); err != nil {
+		// Put the saved version back, unless another update has replaced it meanwhile.
+		s.memoryStore.mu.Lock()
+		if current := s.memoryStore.receipts[id]; current.Version == updated.Version {
+			s.memoryStore.replace(current, previous)
+		}
+		s.memoryStore.mu.Unlock()
+		return StoredReceipt{}, err
+	}
+	return updated, nil
+}
+
+// SetPoints caches the points like memoryStore.SetPoints and saves them. While
+// another snapshot is being written they are left for that writer to save
+// next, so scoring many receipts at once, e.g. with -warm-cache, doesn't write
+// the file once per receipt.
+func (s *fileStore) SetPoints(ctx context.Context, id string, points int64) error {
+	if err := s.memoryStore.SetPoints(ctx, id, points); err != nil {
+		return err
+	}
+
+	s.pointsPending.Store(true)
+	// Checking again after unlocking catches points cached just as the
+	// previous writer finished.
+	for s.pointsPending.Load() && s.saveMu.TryLock() {
+		var err error
+		for err == nil && s.pointsPending.Swap(false) {
+			err = s.saveLocked()
+		}
+		s.saveMu.Unlock()
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *fileStore) DeleteWhere(ctx context.Context, match func(StoredReceipt) bool) (int, error) {
+	deleted, err := s.memoryStore.DeleteWhere(ctx, match)
+	if err != nil || deleted == 0 {
+		return deleted, err
+	}
+	return deleted, s.save()
+}
+
+// Import adds the receipts like memoryStore.Import and saves them all at once.
+func (s *fileStore) Import(ctx context.Context, receipts []StoredReceipt) error {
+	if err := s.memoryStore.Import(ctx, receipts); err != nil {
+		return err
+	}
+
+	if err := s.save(); err != nil {
+		imported := make(map[string]bool, len(receipts))
+		for _, stored := range receipts {
+			imported[stored.ID] = true
+		}
+		s.memoryStore.DeleteWhere(context.Background(), func(stored StoredReceipt) bool { return imported[stored.ID] })
+		return err
+	}
+	return nil
+}
+
+// save atomically replaces the file with a snapshot of all receipts.
+func (s *fileStore) save() error {
+	s.saveMu.Lock()
+	defer s.saveMu.Unlock()
+	return s.saveLocked()
+}
+
+// saveLocked is save. s.saveMu must be held.
+func (s *fileStore) saveLocked() error {
+	list, err := s.memoryStore.List(context.Background())
+	if err != nil {
+		return err
+	}
+	receipts := make([]persistedReceipt, 0, len(list))
+	for _, stored := range list {
+		r := persistedReceipt{ID: stored.ID, Receipt: stored.Receipt, CreatedAt: stored.CreatedAt, Version: stored.Version, Breakdown: stored.Breakdown, Image: stored.Image}
+		if stored.Scored {
+			points := stored.Points
+			r.Points, r.RulesVersion = &points, s.rulesVersion
+		}
+		receipts = append(receipts, r)
+	}
+
+	data, err := json.Marshal(receipts)
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(s.path, data)
+}
+
+// Probe checks that the data directory can actually be written to and read back.
+func (s *fileStore) Probe(ctx context.Context) error {
+	probe, err := os.CreateTemp(filepath.Dir(s.path), ".probe-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(probe.Name())
+
+	_, err = probe.WriteString("ok")
+	if closeErr := probe.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return err
+	}
+
+	data, err := os.ReadFile(probe.Name())
+	if err != nil {
+		return err
+	}
+	if string(data) != "ok" {
+		return fmt.Errorf("probe file %s read back %q", probe.Name(), data)
+	}
+	return nil
+}
+
+// writeFileAtomic writes data to a temporary file next to path and renames it
+// over path, so a crash never leaves a partially written file behind.
+func writeFileAtomic(path string, data []byte) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".tmp-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
@@ -0,0 +1,332 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+
+	"github.com/go-playground/validator/v10"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+// The messages of receipts.proto are few and small, so rather than generating
+// code for them they are encoded and decoded by hand with protowire. The wire
+// format is the same, so clients generated from receipts.proto just work.
+
+// wireUnmarshaler is implemented by the request messages.
+type wireUnmarshaler interface {
+	unmarshalWire(data []byte) error
+}
+
+// wireMarshaler is implemented by the response messages.
+type wireMarshaler interface {
+	marshalWire() []byte
+}
+
+// wireCodec replaces gRPC's protobuf codec for the receipt processor service.
+type wireCodec struct{}
+
+func (wireCodec) Marshal(v any) ([]byte, error) {
+	m, ok := v.(wireMarshaler)
+	if !ok {
+		return nil, fmt.Errorf("can't marshal %T", v)
+	}
+	return m.marshalWire(), nil
+}
+
+func (wireCodec) Unmarshal(data []byte, v any) error {
+	m, ok := v.(wireUnmarshaler)
+	if !ok {
+		return fmt.Errorf("can't unmarshal %T", v)
+	}
+	return m.unmarshalWire(data)
+}
+
+func (wireCodec) Name() string { return "proto" }
+
+// consumeFields calls field for every field of a message. String and
+// embedded message fields are passed their bytes, varint fields their value.
+// Fields of other types are skipped.
+func consumeFields(data []byte, field func(num protowire.Number, value []byte, varint uint64)) error {
+	for len(data) > 0 {
+		num, typ, n := protowire.ConsumeTag(data)
+		if n < 0 {
+			return protowire.ParseError(n)
+		}
+		data = data[n:]
+
+		switch typ {
+		case protowire.BytesType:
+			value, n := protowire.ConsumeBytes(data)
+			if n < 0 {
+				return protowire.ParseError(n)
+			}
+			field(num, value, 0)
+			data = data[n:]
+		case protowire.VarintType:
+			value, n := protowire.ConsumeVarint(data)
+			if n < 0 {
+				return protowire.ParseError(n)
+			}
+			field(num, nil, value)
+			data = data[n:]
+		default:
+			n := protowire.ConsumeFieldValue(num, typ, data)
+			if n < 0 {
+				return protowire.ParseError(n)
+			}
+			data = data[n:]
+		}
+	}
+	return nil
+}
+
+// receiptMessage is the Receipt message.
+type receiptMessage struct {
+	receipt Receipt
+}
+
+func (m *receiptMessage) unmarshalWire(data []byte) error {
+	// An empty repeated field can't be told from a missing one, so a receipt
+	// without items is treated as having an empty list, see -allow-empty-items.
+	m.receipt.Items = []Item{}
+
+	var itemErr error
+	err := consumeFields(data, func(num protowire.Number, value []byte, varint uint64) {
+		switch num {
+		case 1:
+			m.receipt.Retailer = string(value)
+		case 2:
+			m.receipt.PurchaseDate = string(value)
+		case 3:
+			m.receipt.PurchaseTime = string(value)
+		case 4:
+			m.receipt.Total = string(value)
+		case 5:
+			var item Item
+			if err := consumeFields(value, func(num protowire.Number, value []byte, _ uint64) {
+				switch num {
+				case 1:
+					item.ShortDescription = string(value)
+				case 2:
+					item.Price = string(value)
+				}
+			}); err != nil && itemErr == nil {
+				itemErr = err
+			}
+			m.receipt.Items = append(m.receipt.Items, item)
+		case 6:
+			m.receipt.Currency = string(value)
+		case 7:
+			m.receipt.Discount = string(value)
+		case 8:
+			m.receipt.Tags = append(m.receipt.Tags, string(value))
+		case 9:
+			// Negative int32 values are sign extended to 64 bits on the wire.
+			count := int(int32(varint))
+			m.receipt.ItemCount = &count
+		case 10:
+			m.receipt.ImageBase64 = string(value)
+		case 11:
+			m.receipt.GroupID = string(value)
+		case 12:
+			m.receipt.UserID = string(value)
+		}
+	})
+	if err != nil {
+		return err
+	}
+	return itemErr
+}
+
+// processReceiptResponse is the ProcessReceiptResponse message.
+type processReceiptResponse struct {
+	id string
+}
+
+func (m *processReceiptResponse) marshalWire() []byte {
+	b := protowire.AppendTag(nil, 1, protowire.BytesType)
+	return protowire.AppendString(b, m.id)
+}
+
+// getPointsRequest is the GetPointsRequest message.
+type getPointsRequest struct {
+	id string
+}
+
+func (m *getPointsRequest) unmarshalWire(data []byte) error {
+	return consumeFields(data, func(num protowire.Number, value []byte, _ uint64) {
+		if num == 1 {
+			m.id = string(value)
+		}
+	})
+}
+
+// getPointsResponse is the GetPointsResponse message.
+type getPointsResponse struct {
+	points       int64
+	rulesVersion string
+}
+
+func (m *getPointsResponse) marshalWire() []byte {
+	var b []byte
+	if m.points != 0 {
+		b = protowire.AppendTag(b, 1, protowire.VarintType)
+		b = protowire.AppendVarint(b, uint64(m.points))
+	}
+	b = protowire.AppendTag(b, 2, protowire.BytesType)
+	return protowire.AppendString(b, m.rulesVersion)
+}
+
+// receiptProcessorServer is the ReceiptProcessor service.
+type receiptProcessorServer interface {
+	ProcessReceipt(ctx context.Context, in *receiptMessage) (*processReceiptResponse, error)
+	GetPoints(ctx context.Context, in *getPointsRequest) (*getPointsResponse, error)
+}
+
+var receiptProcessorService = grpc.ServiceDesc{
+	ServiceName: "receipts.v1.ReceiptProcessor",
+	HandlerType: (*receiptProcessorServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "ProcessReceipt",
+			Handler: func(srv any, ctx context.Context, dec func(any) error, interceptor grpc.UnaryServerInterceptor) (any, error) {
+				in := new(receiptMessage)
+				if err := dec(in); err != nil {
+					return nil, err
+				}
+				call := func(ctx context.Context, req any) (any, error) {
+					return srv.(receiptProcessorServer).ProcessReceipt(ctx, req.(*receiptMessage))
+				}
+				if interceptor == nil {
+					return call(ctx, in)
+				}
+				return interceptor(ctx, in, &grpc.UnaryServerInfo{Server: srv, FullMethod: "/receipts.v1.ReceiptProcessor/ProcessReceipt"}, call)
+			},
+		},
+		{
+			MethodName: "GetPoints",
+			Handler: func(srv any, ctx context.Context, dec func(any) error, interceptor grpc.UnaryServerInterceptor) (any, error) {
+				in := new(getPointsRequest)
+				if err := dec(in); err != nil {
+					return nil, err
+				}
+				call := func(ctx context.Context, req any) (any, error) {
+					return srv.(receiptProcessorServer).GetPoints(ctx, req.(*getPointsRequest))
+				}
+				if interceptor == nil {
+					return call(ctx, in)
+				}
+				return interceptor(ctx, in, &grpc.UnaryServerInfo{Server: srv, FullMethod: "/receipts.v1.ReceiptProcessor/GetPoints"}, call)
+			},
+		},
+	},
+	Metadata: "receipts.proto",
+}
+
+// grpcService serves the ReceiptProcessor service with the same validation,
+// store and scoring as the HTTP handlers.
+type grpcService struct {
+	s *server
+}
+
+// newGRPCServer returns a gRPC server for the receipt processor service.
+func (s *server) newGRPCServer() *grpc.Server {
+	srv := grpc.NewServer(grpc.ForceServerCodec(wireCodec{}), grpc.UnaryInterceptor(s.grpcTimeout))
+	srv.RegisterService(&receiptProcessorService, grpcService{s: s})
+	return srv
+}
+
+// grpcTimeout applies the request timeout to gRPC calls, if one is configured.
+func (s *server) grpcTimeout(ctx context.Context, req any, _ *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+	if s.cfg.RequestTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, s.cfg.RequestTimeout)
+		defer cancel()
+	}
+	return handler(ctx, req)
+}
+
+func (g grpcService) ProcessReceipt(ctx context.Context, in *receiptMessage) (*processReceiptResponse, error) {
+	receipt := in.receipt
+	err := prepareReceipt(&receipt, g.s.cfg)
+	if err == nil {
+		err = g.s.checkReceipt(receipt)
+	}
+	if err == nil {
+		// gRPC requests can't carry a user token.
+		err = g.s.claimReceipt(&receipt, "")
+	}
+	if err != nil {
+		return nil, rejectionStatus(err)
+	}
+
+	clientIP := ""
+	if p, ok := peer.FromContext(ctx); ok {
+		clientIP, _, _ = net.SplitHostPort(p.Addr.String())
+	}
+	id, _, err := g.s.ingest(ctx, receipt, clientIP)
+	if err != nil {
+		return nil, storeStatus(err)
+	}
+	return &processReceiptResponse{id: id}, nil
+}
+
+func (g grpcService) GetPoints(ctx context.Context, in *getPointsRequest) (*getPointsResponse, error) {
+	_, points, err := g.s.lookupPoints(ctx, in.id)
+	if errors.Is(err, errNotFound) {
+		return nil, statusFor(codes.NotFound, codeReceiptNotFound)
+	}
+	if err != nil {
+		return nil, storeStatus(err)
+	}
+	return &getPointsResponse{points: points, rulesVersion: g.s.rulesVersion}, nil
+}
+
+// statusFor returns a gRPC error with the English message for code, prefixed
+// by the code like "receipt_invalid: The receipt is invalid.".
+func statusFor(grpcCode codes.Code, code string) error {
+	return status.Errorf(grpcCode, "%s: %s", code, messages[defaultLanguage][code])
+}
+
+// rejectionStatus is the gRPC counterpart of rejection.
+func rejectionStatus(err error) error {
+	var validationErrs validator.ValidationErrors
+	if errors.As(err, &validationErrs) && len(validationErrs) > 0 {
+		err = validationFieldError(validationErrs)
+	}
+	var invalidField *fieldError
+	switch {
+	case errors.Is(err, errRetailerDenied):
+		return statusFor(codes.PermissionDenied, codeRetailerDenied)
+	case errors.Is(err, errUserMismatch):
+		return statusFor(codes.PermissionDenied, codeUserMismatch)
+	case errors.As(err, &invalidField):
+		return status.Errorf(codes.InvalidArgument, "%s: %s %s %s", codeReceiptInvalid, messages[defaultLanguage][codeReceiptInvalid], invalidField.Field, invalidField.Reason)
+	default:
+		return statusFor(codes.InvalidArgument, codeReceiptInvalid)
+	}
+}
+
+// storeStatus is the gRPC counterpart of storeFailed.
+func storeStatus(err error) error {
+	log.Println(err)
+	switch {
+	case errors.Is(err, context.DeadlineExceeded):
+		return statusFor(codes.DeadlineExceeded, codeRequestTimeout)
+	case errors.Is(err, errStoreFull):
+		return statusFor(codes.ResourceExhausted, codeStoreFull)
+	case errors.Is(err, errStoreUnavailable):
+		return statusFor(codes.Unavailable, codeStoreUnavailable)
+	case errors.Is(err, errReadOnly):
+		return statusFor(codes.Unavailable, codeReadOnly)
+	default:
+		return statusFor(codes.Internal, codeStoreFailed)
+	}
+}
@@ -0,0 +1,154 @@
+package main
+
+import (
+	"context"
+	"encoding/base64"
+	"net"
+	"reflect"
+	"testing"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+// rawMessage is a message of the test client, passed through wireCodec as is.
+type rawMessage struct {
+	data []byte
+}
+
+func (m *rawMessage) marshalWire() []byte { return m.data }
+
+func (m *rawMessage) unmarshalWire(data []byte) error {
+	m.data = append([]byte(nil), data...)
+	return nil
+}
+
+// grpcClient serves s over an in-memory listener and returns a client for it.
+func grpcClient(t *testing.T, s *server) *grpc.ClientConn {
+	t.Helper()
+	lis := bufconn.Listen(1 << 20)
+	srv := s.newGRPCServer()
+	go srv.Serve(lis)
+	t.Cleanup(srv.Stop)
+
+	conn, err := grpc.NewClient("passthrough:///bufconn",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) { return lis.DialContext(ctx) }),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+		grpc.WithDefaultCallOptions(grpc.ForceCodec(wireCodec{})))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return conn
+}
+
+// wireString appends a string field like a generated client would.
+func wireString(b []byte, num protowire.Number, value string) []byte {
+	b = protowire.AppendTag(b, num, protowire.BytesType)
+	return protowire.AppendString(b, value)
+}
+
+// wireReceipt encodes the Receipt message of receipts.proto.
+func wireReceipt(receipt Receipt) []byte {
+	b := wireString(nil, 1, receipt.Retailer)
+	b = wireString(b, 2, receipt.PurchaseDate)
+	b = wireString(b, 3, receipt.PurchaseTime)
+	b = wireString(b, 4, receipt.Total)
+	for _, item := range receipt.Items {
+		b = protowire.AppendTag(b, 5, protowire.BytesType)
+		b = protowire.AppendBytes(b, wireString(wireString(nil, 1, item.ShortDescription), 2, item.Price))
+	}
+	for _, tag := range receipt.Tags {
+		b = wireString(b, 8, tag)
+	}
+	if receipt.ItemCount != nil {
+		b = protowire.AppendTag(b, 9, protowire.VarintType)
+		b = protowire.AppendVarint(b, uint64(int64(*receipt.ItemCount)))
+	}
+	for num, value := range map[protowire.Number]string{6: receipt.Currency, 7: receipt.Discount, 10: receipt.ImageBase64, 11: receipt.GroupID, 12: receipt.UserID} {
+		if value != "" {
+			b = wireString(b, num, value)
+		}
+	}
+	return b
+}
+
+func TestGRPCProcessReceipt(t *testing.T) {
+	count := func(n int) *int { return &n }
+	base := func() Receipt {
+		return Receipt{
+			Retailer:     "Target",
+			PurchaseDate: "2022-01-02",
+			PurchaseTime: "13:13",
+			Total:        "1.25",
+			Items:        []Item{{ShortDescription: "Pepsi - 12-oz", Price: "1.25"}},
+		}
+	}
+
+	tests := []struct {
+		name    string
+		args    []string
+		receipt func(*Receipt)
+		code    codes.Code
+	}{
+		{"every field", nil, func(r *Receipt) {
+			r.Currency = "USD"
+			r.Tags = []string{"grocery", "drinks"}
+			r.ItemCount = count(1)
+			r.ImageBase64 = "iVBORw0KGgo="
+			r.GroupID = "trip-1"
+			r.UserID = "alice"
+		}, codes.OK},
+		{"item count mismatch", nil, func(r *Receipt) { r.ItemCount = count(2) }, codes.InvalidArgument},
+		{"negative item count", nil, func(r *Receipt) { r.ItemCount = count(-1) }, codes.InvalidArgument},
+		{"invalid image", nil, func(r *Receipt) { r.ImageBase64 = "aGVsbG8=" }, codes.InvalidArgument},
+		{"user without a token", []string{"-user-key", "secret"}, func(r *Receipt) { r.UserID = "alice" }, codes.PermissionDenied},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store := newMemoryStore()
+			conn := grpcClient(t, newServer(testConfig(t, tt.args...), store))
+
+			receipt := base()
+			tt.receipt(&receipt)
+			var out rawMessage
+			err := conn.Invoke(context.Background(), "/receipts.v1.ReceiptProcessor/ProcessReceipt", &rawMessage{data: wireReceipt(receipt)}, &out)
+			if code := status.Code(err); code != tt.code {
+				t.Fatalf("code = %v, want %v: %v", code, tt.code, err)
+			}
+			if err != nil {
+				return
+			}
+
+			var id string
+			consumeFields(out.data, func(num protowire.Number, value []byte, _ uint64) {
+				if num == 1 {
+					id = string(value)
+				}
+			})
+			stored, err := store.Get(context.Background(), id)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(stored.Image) > 0 {
+				stored.Receipt.ImageBase64 = base64.StdEncoding.EncodeToString(stored.Image)
+			}
+			if !reflect.DeepEqual(stored.Receipt, receipt) {
+				t.Errorf("stored %+v, want %+v", stored.Receipt, receipt)
+			}
+		})
+	}
+}
+
+func TestGRPCGetPoints(t *testing.T) {
+	conn := grpcClient(t, newServer(testConfig(t), newMemoryStore()))
+	var out rawMessage
+	err := conn.Invoke(context.Background(), "/receipts.v1.ReceiptProcessor/GetPoints", &rawMessage{data: wireString(nil, 1, "missing")}, &out)
+	if code := status.Code(err); code != codes.NotFound {
+		t.Errorf("code = %v, want NotFound: %v", code, err)
+	}
+}
@@ -0,0 +1,102 @@
+package main
+
+import (
+	"compress/gzip"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// gzipResponses compresses responses for clients accepting gzip. Responses
+// smaller than minSize are sent as is, since compressing them isn't worth it.
+func gzipResponses(minSize int) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !strings.Contains(c.GetHeader("Accept-Encoding"), "gzip") {
+			c.Next()
+			return
+		}
+
+		writer := &gzipWriter{ResponseWriter: c.Writer, minSize: minSize}
+		c.Writer = writer
+		c.Header("Vary", "Accept-Encoding")
+
+		c.Next()
+
+		writer.finish()
+		c.Writer = writer.ResponseWriter
+	}
+}
+
+// gzipWriter holds back the response until it knows whether it reaches the
+// minimum size, then either compresses it or writes it through unchanged.
+type gzipWriter struct {
+	gin.ResponseWriter
+	minSize int
+
+	buf         []byte
+	gz          *gzip.Writer
+	passthrough bool
+}
+
+func (w *gzipWriter) Write(data []byte) (int, error) {
+	switch {
+	case w.gz != nil:
+		return w.gz.Write(data)
+	case w.passthrough:
+		return w.ResponseWriter.Write(data)
+	}
+
+	w.buf = append(w.buf, data...)
+	if len(w.buf) >= w.minSize {
+		if err := w.startGzip(); err != nil {
+			return 0, err
+		}
+	}
+	return len(data), nil
+}
+
+func (w *gzipWriter) WriteString(s string) (int, error) {
+	return w.Write([]byte(s))
+}
+
+// startGzip switches to compressing, unless the handler already encoded the response.
+func (w *gzipWriter) startGzip() error {
+	buf := w.buf
+	w.buf = nil
+
+	if w.Header().Get("Content-Encoding") != "" {
+		w.passthrough = true
+		_, err := w.ResponseWriter.Write(buf)
+		return err
+	}
+
+	w.Header().Set("Content-Encoding", "gzip")
+	w.Header().Del("Content-Length")
+	w.gz = gzip.NewWriter(w.ResponseWriter)
+	_, err := w.gz.Write(buf)
+	return err
+}
+
+// Flush sends buffered data to the client. A response flushed before
+// reaching the minimum size is sent uncompressed.
+func (w *gzipWriter) Flush() {
+	if w.gz != nil {
+		w.gz.Flush()
+	} else if !w.passthrough {
+		w.passthrough = true
+		w.ResponseWriter.Write(w.buf)
+		w.buf = nil
+	}
+	w.ResponseWriter.Flush()
+}
+
+// finish writes whatever is still held back once the handler is done.
+func (w *gzipWriter) finish() {
+	if w.gz != nil {
+		w.gz.Close()
+		return
+	}
+	if len(w.buf) > 0 {
+		w.ResponseWriter.Write(w.buf)
+	}
+}
@@ -0,0 +1,49 @@
+package main
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+	"net/http"
+	"testing"
+)
+
+func TestGzipThreshold(t *testing.T) {
+	plain := newServer(testConfig(t), newMemoryStore()).router()
+	r := newServer(testConfig(t, "-gzip", "-gzip-min-size", "200"), newMemoryStore()).router()
+	tests := []struct {
+		name       string
+		path       string
+		accept     string
+		compressed bool
+	}{
+		// The error for an unknown receipt is well below 200 bytes, the rules well above.
+		{"tiny", "/receipts/missing/points", "gzip", false},
+		{"large", "/rules", "gzip, deflate", true},
+		{"large without gzip", "/rules", "deflate", false},
+		{"large without Accept-Encoding", "/rules", "", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			want := serve(plain, http.MethodGet, tt.path, "").Body.Bytes()
+			w := serve(r, http.MethodGet, tt.path, "", "Accept-Encoding", tt.accept)
+
+			body := w.Body.Bytes()
+			if compressed := w.Header().Get("Content-Encoding") == "gzip"; compressed != tt.compressed {
+				t.Fatalf("compressed = %v, want %v for %d bytes", compressed, tt.compressed, len(want))
+			}
+			if tt.compressed {
+				gz, err := gzip.NewReader(bytes.NewReader(body))
+				if err != nil {
+					t.Fatal(err)
+				}
+				if body, err = io.ReadAll(gz); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if !bytes.Equal(body, want) {
+				t.Errorf("body = %q, want %q", body, want)
+			}
+		})
+	}
+}
@@ -0,0 +1,29 @@
+package main
+
+import (
+	"context"
+	"log"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// prober is implemented by stores that can check they are able to persist data.
+type prober interface {
+	Probe(ctx context.Context) error
+}
+
+// getReady reports whether the server can serve requests, including whether
+// the store can still persist receipts.
+func (s *server) getReady(c *gin.Context) {
+	if p, ok := s.store.(prober); ok {
+		if err := p.Probe(c.Request.Context()); err != nil {
+			log.Printf("readiness probe failed: %v\n", err)
+			body := errorBody(c, codeStoreNotWritable)
+			body["status"] = "unavailable"
+			c.JSON(http.StatusServiceUnavailable, body)
+			return
+		}
+	}
+	c.JSON(http.StatusOK, gin.H{"status": "ready"})
+}
@@ -0,0 +1,65 @@
+package main
+
+import (
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestReadiness(t *testing.T) {
+	tests := []struct {
+		name string
+		// breakDir makes the data directory unusable after opening the store,
+		// or is nil to leave it alone.
+		breakDir func(t *testing.T, dir string)
+		status   int
+	}{
+		{"writable", nil, http.StatusOK},
+		{"read-only", func(t *testing.T, dir string) {
+			if os.Geteuid() == 0 {
+				t.Skip("root can write to read-only directories")
+			}
+			if err := os.Chmod(dir, 0o500); err != nil {
+				t.Fatal(err)
+			}
+			t.Cleanup(func() { os.Chmod(dir, 0o700) })
+		}, http.StatusServiceUnavailable},
+		{"removed", func(t *testing.T, dir string) {
+			if err := os.RemoveAll(dir); err != nil {
+				t.Fatal(err)
+			}
+		}, http.StatusServiceUnavailable},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := filepath.Join(t.TempDir(), "data")
+			if err := os.Mkdir(dir, 0o700); err != nil {
+				t.Fatal(err)
+			}
+			store, err := openFileStore(filepath.Join(dir, "receipts.json"), "")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if tt.breakDir != nil {
+				tt.breakDir(t, dir)
+			}
+
+			r := newServer(testConfig(t), store).router()
+			w := serve(r, http.MethodGet, "/ready", "")
+			if w.Code != tt.status {
+				t.Errorf("status = %d, want %d: %s", w.Code, tt.status, w.Body)
+			}
+			if tt.status != http.StatusOK && !strings.Contains(w.Body.String(), `"status":"unavailable"`) {
+				t.Errorf("body = %s", w.Body)
+			}
+		})
+	}
+
+	// The memory store has nothing to probe.
+	r := newServer(testConfig(t), newMemoryStore()).router()
+	if w := serve(r, http.MethodGet, "/ready", ""); w.Code != http.StatusOK {
+		t.Errorf("memory store: status = %d, want 200", w.Code)
+	}
+}
@@ -0,0 +1,93 @@
+package main
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// maxImageBytes limits the decoded size of an attached receipt image.
+const maxImageBytes = 1 << 20
+
+// decodeImage decodes a base64 receipt image and detects its content type
+// from the magic bytes. Anything that isn't a reasonably sized image is rejected.
+func decodeImage(encoded string) ([]byte, string, error) {
+	if base64.StdEncoding.DecodedLen(len(encoded)) > maxImageBytes+2 {
+		return nil, "", fmt.Errorf("image exceeds %d bytes", maxImageBytes)
+	}
+
+	data, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, "", err
+	}
+	if len(data) > maxImageBytes {
+		return nil, "", fmt.Errorf("image exceeds %d bytes", maxImageBytes)
+	}
+
+	contentType := http.DetectContentType(data)
+	if !strings.HasPrefix(contentType, "image/") {
+		return nil, "", fmt.Errorf("attachment is %s, not an image", contentType)
+	}
+	return data, contentType, nil
+}
+
+// ImageReference describes the image attached to a receipt, which is served
+// by GET /receipts/:id/image rather than returned with the receipt.
+type ImageReference struct {
+	URL         string `json:"url"`
+	ContentType string `json:"contentType"`
+	Size        int    `json:"size"`
+}
+
+// imageReference returns the reference to the image of the receipt, or nil if
+// it has none.
+func imageReference(stored StoredReceipt) *ImageReference {
+	if len(stored.Image) == 0 {
+		return nil
+	}
+	return &ImageReference{
+		URL:         "/receipts/" + stored.ID + "/image",
+		ContentType: http.DetectContentType(stored.Image),
+		Size:        len(stored.Image),
+	}
+}
+
+// detachImage moves the image of a receipt being stored from its ImageBase64
+// field to stored.Image, decoded. Images can be a megabyte each, so this keeps
+// them out of everything that returns, lists or exports receipts. The image
+// was validated with the receipt.
+func detachImage(stored StoredReceipt) (StoredReceipt, error) {
+	if stored.Receipt.ImageBase64 == "" {
+		return stored, nil
+	}
+	image, err := base64.StdEncoding.DecodeString(stored.Receipt.ImageBase64)
+	if err != nil {
+		return StoredReceipt{}, fmt.Errorf("decoding the image of receipt %s: %w", stored.ID, err)
+	}
+	stored.Receipt.ImageBase64 = ""
+	stored.Image = image
+	return stored, nil
+}
+
+// getImage returns the image attached to the receipt, if any.
+func (s *server) getImage(c *gin.Context) {
+	stored, err := s.store.Get(c.Request.Context(), c.Param("id"))
+	if errors.Is(err, errNotFound) {
+		c.JSON(http.StatusNotFound, errorBody(c, codeReceiptNotFound))
+		return
+	}
+	if err != nil {
+		storeFailed(c, err)
+		return
+	}
+
+	if len(stored.Image) == 0 {
+		c.JSON(http.StatusNotFound, errorBody(c, codeImageNotFound))
+		return
+	}
+	c.Data(http.StatusOK, http.DetectContentType(stored.Image), stored.Image)
+}
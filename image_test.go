@@ -0,0 +1,143 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"image"
+	"image/png"
+	"net/http"
+	"os"
+	"strings"
+	"testing"
+)
+
+// smallPNG returns a 1x1 PNG image.
+func smallPNG(t *testing.T) []byte {
+	t.Helper()
+	var b bytes.Buffer
+	if err := png.Encode(&b, image.NewGray(image.Rect(0, 0, 1, 1))); err != nil {
+		t.Fatal(err)
+	}
+	return b.Bytes()
+}
+
+// withImage adds the imageBase64 field to a JSON receipt.
+func withImage(receipt, encoded string) string {
+	return strings.Replace(receipt, "{", `{"imageBase64": "`+encoded+`",`, 1)
+}
+
+func TestReceiptImage(t *testing.T) {
+	img := smallPNG(t)
+	tests := []struct {
+		name    string
+		encoded string
+		status  int
+	}{
+		{"png", base64.StdEncoding.EncodeToString(img), http.StatusOK},
+		{"not an image", base64.StdEncoding.EncodeToString([]byte("hello, world")), http.StatusBadRequest},
+		{"not base64", "iVBOR!", http.StatusBadRequest},
+		{"too large", base64.StdEncoding.EncodeToString(append(img, make([]byte, maxImageBytes)...)), http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := newServer(testConfig(t), newMemoryStore()).router()
+			w := serve(r, http.MethodPost, "/receipts/process", withImage(example(t, "simple-receipt.json"), tt.encoded))
+			if w.Code != tt.status {
+				t.Fatalf("status = %d, want %d: %s", w.Code, tt.status, w.Body)
+			}
+			if w.Code != http.StatusOK {
+				return
+			}
+			var processed ReceiptResponse
+			json.Unmarshal(w.Body.Bytes(), &processed)
+
+			w = serve(r, http.MethodGet, "/receipts/"+processed.ID+"/image", "")
+			if w.Code != http.StatusOK || w.Header().Get("Content-Type") != "image/png" || !bytes.Equal(w.Body.Bytes(), img) {
+				t.Errorf("image: status %d, type %q, %d bytes", w.Code, w.Header().Get("Content-Type"), w.Body.Len())
+			}
+
+			// The receipt only refers to its image.
+			w = serve(r, http.MethodGet, "/receipts/"+processed.ID+"/full", "")
+			var full FullReceiptResponse
+			json.Unmarshal(w.Body.Bytes(), &full)
+			want := ImageReference{URL: "/receipts/" + processed.ID + "/image", ContentType: "image/png", Size: len(img)}
+			if full.Receipt.ImageBase64 != "" || full.Image == nil || *full.Image != want {
+				t.Errorf("full receipt has image %q, reference %+v, want %+v", full.Receipt.ImageBase64, full.Image, want)
+			}
+			if w = serve(r, http.MethodGet, "/receipts/export", ""); strings.Contains(w.Body.String(), "imageBase64") {
+				t.Errorf("export includes the image: %s", w.Body)
+			}
+		})
+	}
+}
+
+func TestReceiptWithoutImage(t *testing.T) {
+	s := newServer(testConfig(t), newMemoryStore())
+	r := s.router()
+	w := serve(r, http.MethodPost, "/receipts/process", example(t, "simple-receipt.json"))
+	var processed ReceiptResponse
+	json.Unmarshal(w.Body.Bytes(), &processed)
+
+	if w := serve(r, http.MethodGet, "/receipts/"+processed.ID+"/image", ""); w.Code != http.StatusNotFound {
+		t.Errorf("status = %d, want 404", w.Code)
+	}
+	if w := serve(r, http.MethodGet, "/receipts/"+processed.ID+"/full", ""); strings.Contains(w.Body.String(), `"image"`) {
+		t.Errorf("full receipt refers to an image: %s", w.Body)
+	}
+}
+
+func TestFileStoreImages(t *testing.T) {
+	img := smallPNG(t)
+	encoded := base64.StdEncoding.EncodeToString(img)
+	receipt, err := decodeReceipt(strings.NewReader(withImage(example(t, "simple-receipt.json"), encoded)), Config{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Files saved before images were kept apart have them in the receipt.
+	legacy := t.TempDir() + "/legacy.json"
+	data, _ := json.Marshal([]persistedReceipt{{ID: "old", Receipt: receipt, Version: 1}})
+	if err := os.WriteFile(legacy, data, 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name string
+		path string
+		add  bool
+	}{
+		{"saved", t.TempDir() + "/receipts.json", true},
+		{"legacy", legacy, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			store, err := openFileStore(tt.path, "")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if tt.add {
+				if err := store.Add(ctx, "old", receipt, nil); err != nil {
+					t.Fatal(err)
+				}
+				if data, _ := os.ReadFile(tt.path); bytes.Contains(data, []byte("imageBase64")) {
+					t.Errorf("the image is saved in the receipt: %s", data)
+				}
+			}
+
+			reopened, err := openFileStore(tt.path, "")
+			if err != nil {
+				t.Fatal(err)
+			}
+			stored, err := reopened.Get(ctx, "old")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if stored.Receipt.ImageBase64 != "" || !bytes.Equal(stored.Image, img) {
+				t.Errorf("loaded image %q, %d bytes, want %d bytes apart", stored.Receipt.ImageBase64, len(stored.Image), len(img))
+			}
+		})
+	}
+}
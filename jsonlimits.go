@@ -0,0 +1,53 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+)
+
+// errJSONTooComplex is returned for JSON nested deeper, or with longer arrays,
+// than the configured limits.
+var errJSONTooComplex = errors.New("JSON exceeds the nesting or array limits")
+
+// checkJSONLimits scans a JSON document token by token, without decoding it,
+// and returns errJSONTooComplex as soon as objects and arrays are nested more
+// than maxDepth levels or an array has more than maxArray elements. Zero
+// disables a limit. Syntax errors are left for the actual decoding to report.
+func checkJSONLimits(data []byte, maxDepth, maxArray int) error {
+	if maxDepth == 0 && maxArray == 0 {
+		return nil
+	}
+
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	// lengths holds the element count of every open array, and -1 for objects.
+	var lengths []int
+	for {
+		token, err := decoder.Token()
+		if err != nil {
+			// The end of the document, or a syntax error.
+			return nil
+		}
+
+		if n := len(lengths); n > 0 && lengths[n-1] >= 0 {
+			if delim, ok := token.(json.Delim); !ok || (delim != ']' && delim != '}') {
+				lengths[n-1]++
+				if maxArray > 0 && lengths[n-1] > maxArray {
+					return errJSONTooComplex
+				}
+			}
+		}
+
+		switch token {
+		case json.Delim('['):
+			lengths = append(lengths, 0)
+		case json.Delim('{'):
+			lengths = append(lengths, -1)
+		case json.Delim(']'), json.Delim('}'):
+			lengths = lengths[:len(lengths)-1]
+		}
+		if maxDepth > 0 && len(lengths) > maxDepth {
+			return errJSONTooComplex
+		}
+	}
+}
@@ -0,0 +1,69 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestCheckJSONLimits(t *testing.T) {
+	nested := func(depth int) string {
+		return strings.Repeat("[", depth) + strings.Repeat("]", depth)
+	}
+	array := func(n int) string {
+		return "[" + strings.TrimSuffix(strings.Repeat("1,", n), ",") + "]"
+	}
+	tests := []struct {
+		name               string
+		data               string
+		maxDepth, maxArray int
+		tooComplex         bool
+	}{
+		{"no limits", nested(1000), 0, 0, false},
+		{"at the depth", nested(3), 3, 0, false},
+		{"too deep", nested(4), 3, 0, true},
+		{"objects count", `{"a": {"b": {"c": {}}}}`, 3, 0, true},
+		{"strings don't count", `{"a": "[[[[[[[["}`, 2, 0, false},
+		{"at the length", array(3), 0, 3, false},
+		{"too long", array(4), 0, 3, true},
+		{"objects aren't arrays", `{"a": 1, "b": 2, "c": 3, "d": 4}`, 0, 3, false},
+		{"nested arrays counted separately", `[[1, 2, 3], [1, 2, 3], [1, 2, 3]]`, 0, 3, false},
+		{"long nested array", `[[1, 2, 3, 4]]`, 0, 3, true},
+		{"syntax error", `[[[`, 5, 5, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := checkJSONLimits([]byte(tt.data), tt.maxDepth, tt.maxArray)
+			if errors.Is(err, errJSONTooComplex) != tt.tooComplex {
+				t.Errorf("checkJSONLimits = %v, want too complex %v", err, tt.tooComplex)
+			}
+		})
+	}
+}
+
+func TestPathologicalPayloads(t *testing.T) {
+	receipt := example(t, "simple-receipt.json")
+	deep := strings.Replace(receipt, `"retailer"`, `"extra": `+strings.Repeat("[", 100000)+strings.Repeat("]", 100000)+`, "retailer"`, 1)
+	items := strings.Repeat(`{"shortDescription": "Pepsi", "price": "1.25"},`, 1000)
+	long := strings.Replace(receipt, `"items": [`, `"items": [`+items, 1)
+	tests := []struct {
+		name    string
+		args    []string
+		receipt string
+		status  int
+	}{
+		{"deeply nested", nil, deep, http.StatusBadRequest},
+		{"many items", nil, long, http.StatusOK},
+		{"too many items", []string{"-max-json-array", "100"}, long, http.StatusBadRequest},
+		{"nesting allowed", []string{"-max-json-depth", "0"}, strings.Replace(receipt, `"retailer"`, `"extra": [[[[[[[[[[[[]]]]]]]]]]]], "retailer"`, 1), http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := newServer(testConfig(t, tt.args...), newMemoryStore()).router()
+			if w := serve(r, http.MethodPost, "/receipts/process", tt.receipt); w.Code != tt.status {
+				t.Errorf("status = %d, want %d: %.200s", w.Code, tt.status, w.Body)
+			}
+		})
+	}
+}
@@ -0,0 +1,136 @@
+package main
+
+import (
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Casings of the keys of JSON responses.
+const (
+	keyCaseCamel = "camel"
+	keyCaseSnake = "snake"
+)
+
+// snakeCaseKeys rewrites the object keys of JSON responses from camelCase to
+// snake_case, e.g. purchaseDate to purchase_date. Responses are converted as
+// they are written, so streamed ones keep streaming.
+func snakeCaseKeys(c *gin.Context) {
+	writer := &snakeCaseWriter{ResponseWriter: c.Writer}
+	c.Writer = writer
+
+	c.Next()
+
+	c.Writer = writer.ResponseWriter
+}
+
+// snakeCaseWriter tracks just enough of the JSON it passes through to tell
+// object keys from string values.
+type snakeCaseWriter struct {
+	gin.ResponseWriter
+
+	// checked is set once the first write has decided whether the response is
+	// JSON at all. Anything else is passed through.
+	checked     bool
+	passthrough bool
+
+	// objects holds whether each open container is an object, innermost last.
+	objects   []bool
+	expectKey bool
+	inString  bool
+	inKey     bool
+	escaped   bool
+	key       []byte
+}
+
+func (w *snakeCaseWriter) Write(data []byte) (int, error) {
+	if !w.checked {
+		w.checked = true
+		w.passthrough = !strings.Contains(w.Header().Get("Content-Type"), "json")
+	}
+	if w.passthrough {
+		return w.ResponseWriter.Write(data)
+	}
+
+	out := make([]byte, 0, len(data))
+	for _, b := range data {
+		if w.inString {
+			if w.inKey {
+				w.key = append(w.key, b)
+			} else {
+				out = append(out, b)
+			}
+			switch {
+			case w.escaped:
+				w.escaped = false
+			case b == '\\':
+				w.escaped = true
+			case b == '"':
+				w.inString = false
+				if w.inKey {
+					w.inKey = false
+					out = append(out, '"')
+					out = append(out, snakeCase(string(w.key[:len(w.key)-1]))...)
+					out = append(out, '"')
+				}
+			}
+			continue
+		}
+
+		switch b {
+		case '{', '[':
+			w.objects = append(w.objects, b == '{')
+			w.expectKey = b == '{'
+		case '}', ']':
+			if len(w.objects) > 0 {
+				w.objects = w.objects[:len(w.objects)-1]
+			}
+			w.expectKey = false
+		case ':':
+			w.expectKey = false
+		case ',':
+			w.expectKey = len(w.objects) > 0 && w.objects[len(w.objects)-1]
+		case '"':
+			w.inString = true
+			if w.expectKey {
+				w.inKey = true
+				w.key = w.key[:0]
+				continue
+			}
+		}
+		out = append(out, b)
+	}
+
+	if _, err := w.ResponseWriter.Write(out); err != nil {
+		return 0, err
+	}
+	return len(data), nil
+}
+
+func (w *snakeCaseWriter) WriteString(s string) (int, error) {
+	return w.Write([]byte(s))
+}
+
+// snakeCase converts a camelCase key like purchaseDate to purchase_date. Keys
+// that aren't plain camelCase, such as retailer names, are left alone.
+func snakeCase(key string) string {
+	if key == "" || key[0] < 'a' || key[0] > 'z' {
+		return key
+	}
+	for _, c := range key {
+		if !isAlphaNumeric(c) {
+			return key
+		}
+	}
+
+	var b strings.Builder
+	for i := 0; i < len(key); i++ {
+		if c := key[i]; c >= 'A' && c <= 'Z' {
+			b.WriteByte('_')
+			b.WriteByte(c + 'a' - 'A')
+		} else {
+			b.WriteByte(c)
+		}
+	}
+	return b.String()
+}
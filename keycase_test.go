@@ -0,0 +1,99 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestSnakeCase(t *testing.T) {
+	tests := []struct{ key, want string }{
+		{"points", "points"},
+		{"purchaseDate", "purchase_date"},
+		{"shortDescription", "short_description"},
+		{"rulesVersion", "rules_version"},
+		{"ID", "ID"},
+		{"Target", "Target"},
+		{"m&m corner market", "m&m corner market"},
+		{"", ""},
+	}
+	for _, tt := range tests {
+		if got := snakeCase(tt.key); got != tt.want {
+			t.Errorf("snakeCase(%q) = %q, want %q", tt.key, got, tt.want)
+		}
+	}
+}
+
+func TestSnakeCaseWriter(t *testing.T) {
+	tests := []struct {
+		name        string
+		contentType string
+		in, want    string
+	}{
+		{"keys", "application/json", `{"purchaseDate":"2022-01-01","items":[{"shortDescription":"Pepsi"}]}`, `{"purchase_date":"2022-01-01","items":[{"short_description":"Pepsi"}]}`},
+		{"values kept", "application/json", `{"retailer":"shortDescription","tags":["camelCase"]}`, `{"retailer":"shortDescription","tags":["camelCase"]}`},
+		{"escaped quotes", "application/json", `{"retailer":"Mr \"bigDeal\": {x","nextCursor":"a"}`, `{"retailer":"Mr \"bigDeal\": {x","next_cursor":"a"}`},
+		{"escaped keys", "application/json", `{"a\"bC":1,"dE":2}`, `{"a\"bC":1,"d_e":2}`},
+		{"not JSON", "text/plain", `{"purchaseDate":1}`, `{"purchaseDate":1}`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Writing a byte at a time shows keys split across writes are converted.
+			for _, chunk := range []int{len(tt.in), 1} {
+				w := httptest.NewRecorder()
+				c, _ := gin.CreateTestContext(w)
+				c.Header("Content-Type", tt.contentType)
+				writer := &snakeCaseWriter{ResponseWriter: c.Writer}
+				for i := 0; i < len(tt.in); i += chunk {
+					if _, err := writer.WriteString(tt.in[i:min(i+chunk, len(tt.in))]); err != nil {
+						t.Fatal(err)
+					}
+				}
+				if got := w.Body.String(); got != tt.want {
+					t.Errorf("writing %d bytes at a time: %s, want %s", chunk, got, tt.want)
+				}
+			}
+		})
+	}
+}
+
+func TestJSONKeys(t *testing.T) {
+	tests := []struct {
+		args      []string
+		want, not []string
+	}{
+		{nil, []string{`"purchaseDate"`, `"shortDescription"`, `"rulesVersion"`}, []string{`"purchase_date"`}},
+		{[]string{"-json-keys", "camel"}, []string{`"purchaseDate"`}, []string{`"purchase_date"`}},
+		{[]string{"-json-keys", "snake"}, []string{`"purchase_date"`, `"short_description"`, `"rules_version"`}, []string{`"purchaseDate"`, `"shortDescription"`}},
+	}
+	for _, tt := range tests {
+		t.Run(strings.Join(tt.args, " "), func(t *testing.T) {
+			r := newServer(testConfig(t, tt.args...), newMemoryStore()).router()
+			w := serve(r, http.MethodPost, "/receipts/process", example(t, "target-receipt.json"))
+			var response map[string]string
+			if err := json.Unmarshal(w.Body.Bytes(), &response); err != nil || response["id"] == "" {
+				t.Fatalf("status = %d: %s", w.Code, w.Body)
+			}
+			body := serve(r, http.MethodGet, "/receipts/"+response["id"]+"/full", "").Body.String() +
+				serve(r, http.MethodGet, "/receipts/"+response["id"]+"/points", "").Body.String()
+			for _, key := range tt.want {
+				if !strings.Contains(body, key) {
+					t.Errorf("missing %s: %s", key, body)
+				}
+			}
+			for _, key := range tt.not {
+				if strings.Contains(body, key) {
+					t.Errorf("unexpected %s: %s", key, body)
+				}
+			}
+		})
+	}
+
+	if _, err := parseConfig([]string{"-json-keys", "kebab"}); err == nil {
+		t.Error("-json-keys kebab was accepted")
+	}
+}
@@ -0,0 +1,74 @@
+package main
+
+import (
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// legacyPoints is the original scoring of the challenge, kept as it was for
+// ?scheme=legacy: amounts are parsed as floats and the breakdown isn't
+// printed. It must not change, or the legacy points of stored receipts would.
+func legacyPoints(receipt Receipt) int64 {
+	points := int64(0)
+
+	//One point for every alphanumeric character in the retailer name.
+	for _, char := range receipt.Retailer {
+		if legacyIsAlphaNumeric(byte(char)) {
+			points++
+		}
+	}
+
+	totalPrice, _ := strconv.ParseFloat(receipt.Total, 64)
+
+	//50 points if the total is a round dollar amount with no cents.
+	if totalPrice == math.Trunc(totalPrice) {
+		points += 50
+	}
+
+	//25 points if the total is a multiple of 0.25.
+	if int64(totalPrice*100)%25 == 0 {
+		points += 25
+	}
+
+	//5 points for every two items on the receipt
+	numItems := len(receipt.Items)
+	points += int64(numItems / 2 * 5)
+
+	//If the trimmed length of the item description is a multiple of 3, multiply the price by 0.2 and round up to the nearest integer. The result is the number of points earned.
+	for _, item := range receipt.Items {
+		trimedDesc := strings.TrimSpace(item.ShortDescription)
+		if len(trimedDesc)%3 == 0 {
+			price, err := strconv.ParseFloat(item.Price, 64)
+			if err != nil {
+				// The original failed with the error, and the points endpoint
+				// served the 0 points returned along with it.
+				return 0
+			}
+			points += int64(price*.2) + 1
+		}
+	}
+
+	//prepare time and date variables for next point rules.
+	layout := "2006-01-02 15:04"
+	value := receipt.PurchaseDate + " " + receipt.PurchaseTime
+	date, _ := time.Parse(layout, value)
+
+	//6 points if the day in the purchase date is odd.
+	if date.Day()%2 == 1 {
+		points += 6
+	}
+
+	//10 points if the time of purchase is after 2:00pm and before 4:00pm.
+	hoursMinutes := date.Hour()*100 + date.Minute()
+	if hoursMinutes > 1400 && hoursMinutes < 1560 { //1400 represents 2pm, 1560 represents 4pm
+		points += 10
+	}
+
+	return points
+}
+
+func legacyIsAlphaNumeric(c byte) bool {
+	return (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') || (c >= '0' && c <= '9')
+}
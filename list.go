@@ -0,0 +1,138 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	defaultListLimit = 100
+	maxListLimit     = 1000
+)
+
+type ReceiptSummary struct {
+	ID           string `json:"id"`
+	Retailer     string `json:"retailer"`
+	PurchaseDate string `json:"purchaseDate"`
+	Total        string `json:"total"`
+	// Points is only listed with ?withPoints=true.
+	Points *int64 `json:"points,omitempty"`
+}
+
+// listReceipts returns a page of receipts, oldest first. The page starts past
+// the cursor query parameter or, failing that, at the offset, and has at most
+// limit receipts. Cursors stay correct while receipts are added or deleted,
+// unlike offsets. With withPoints=true each receipt includes its points,
+// cached ones where possible. With tag only the receipts with that tag are
+// listed, and counted in the total. Only the requesting user's receipts are
+// listed, see visibleTo.
+func (s *server) listReceipts(c *gin.Context) {
+	cursor := c.Query("cursor")
+	var after StoredReceipt
+	if cursor != "" {
+		var err error
+		if after, err = decodeCursor(cursor); err != nil || c.Query("offset") != "" {
+			c.JSON(http.StatusBadRequest, errorBody(c, codeInvalidCursor))
+			return
+		}
+	}
+	offset, err := queryInt(c, "offset", 0)
+	if err != nil || offset < 0 {
+		c.JSON(http.StatusBadRequest, errorBody(c, codeInvalidOffset))
+		return
+	}
+	limit, err := queryInt(c, "limit", defaultListLimit)
+	if err != nil || limit < 1 || limit > maxListLimit {
+		c.JSON(http.StatusBadRequest, errorBody(c, codeInvalidLimit, maxListLimit))
+		return
+	}
+
+	withPoints := c.Query("withPoints") == "true"
+
+	var receipts []StoredReceipt
+	if tag := c.Query("tag"); tag != "" {
+		receipts, err = taggedReceipts(c.Request.Context(), s.store, tag)
+	} else {
+		receipts, err = s.store.List(c.Request.Context())
+	}
+	if err != nil {
+		storeFailed(c, err)
+		return
+	}
+	receipts = visibleTo(receipts, requestUser(c))
+
+	if cursor != "" {
+		offset = afterCursor(receipts, after)
+	}
+	end := min(offset+limit, len(receipts))
+	start := min(offset, end)
+
+	// The summaries are encoded one at a time straight to the client instead
+	// of building the whole page first. Errors past this point can only be
+	// the client going away, so they are just logged.
+	c.Header("Content-Type", "application/json; charset=utf-8")
+	c.Status(http.StatusOK)
+	w := c.Writer
+	if _, err := io.WriteString(w, `{"receipts":[`); err != nil {
+		log.Println(err)
+		return
+	}
+	err = streamArray(w, end-start, func(i int) any {
+		stored := receipts[start+i]
+		summary := ReceiptSummary{
+			ID:           stored.ID,
+			Retailer:     stored.Receipt.Retailer,
+			PurchaseDate: stored.Receipt.PurchaseDate,
+			Total:        stored.Receipt.Total,
+		}
+		if withPoints {
+			points := s.points(c.Request.Context(), stored)
+			summary.Points = &points
+		}
+		return summary
+	})
+	if err == nil {
+		_, err = fmt.Fprintf(w, `],"total":%d`, len(receipts))
+	}
+	if err == nil && end < len(receipts) && end > 0 {
+		_, err = fmt.Fprintf(w, `,"nextCursor":%q`, encodeCursor(receipts[end-1]))
+	}
+	if err == nil {
+		_, err = io.WriteString(w, "}\n")
+	}
+	if err != nil {
+		log.Println(err)
+	}
+}
+
+// streamArray writes n comma separated JSON values produced by elem, without
+// the enclosing brackets.
+func streamArray(w io.Writer, n int, elem func(i int) any) error {
+	enc := json.NewEncoder(w)
+	for i := 0; i < n; i++ {
+		if i > 0 {
+			if _, err := io.WriteString(w, ","); err != nil {
+				return err
+			}
+		}
+		if err := enc.Encode(elem(i)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// queryInt parses an optional integer query parameter.
+func queryInt(c *gin.Context, name string, fallback int) (int, error) {
+	value := c.Query(name)
+	if value == "" {
+		return fallback, nil
+	}
+	return strconv.Atoi(value)
+}
@@ -0,0 +1,258 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+// bufferedList is what GET /receipts streams, for encoding it in one go.
+type bufferedList struct {
+	Receipts   []ReceiptSummary `json:"receipts"`
+	Total      int              `json:"total"`
+	NextCursor string           `json:"nextCursor,omitempty"`
+}
+
+func TestListStreaming(t *testing.T) {
+	store := newMemoryStore()
+	ctx := context.Background()
+	for _, id := range []string{"a", "b", "c", "d", "e"} {
+		receipt := Receipt{Retailer: "Target " + id, PurchaseDate: "2022-01-01", PurchaseTime: "13:01", Total: "1.25", Items: []Item{{ShortDescription: "Pepsi", Price: "1.25"}}}
+		if err := store.Add(ctx, id, receipt, nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+	s := newServer(testConfig(t), store)
+	r := s.router()
+	all, _ := store.List(ctx)
+
+	buffered := func(start, end int, withPoints bool) bufferedList {
+		list := bufferedList{Receipts: []ReceiptSummary{}, Total: len(all)}
+		for _, stored := range all[start:end] {
+			summary := ReceiptSummary{ID: stored.ID, Retailer: stored.Receipt.Retailer, PurchaseDate: stored.Receipt.PurchaseDate, Total: stored.Receipt.Total}
+			if withPoints {
+				points := s.points(ctx, stored)
+				summary.Points = &points
+			}
+			list.Receipts = append(list.Receipts, summary)
+		}
+		if end < len(all) && end > 0 {
+			list.NextCursor = encodeCursor(all[end-1])
+		}
+		return list
+	}
+
+	tests := []struct {
+		query string
+		want  bufferedList
+	}{
+		{"", buffered(0, 5, false)},
+		{"?limit=2", buffered(0, 2, false)},
+		{"?limit=2&offset=2&withPoints=true", buffered(2, 4, true)},
+		{"?cursor=" + encodeCursor(all[1]), buffered(2, 5, false)},
+		{"?offset=10", buffered(5, 5, false)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.query, func(t *testing.T) {
+			w := serve(r, http.MethodGet, "/receipts"+tt.query, "")
+			if w.Code != http.StatusOK {
+				t.Fatalf("status = %d: %s", w.Code, w.Body)
+			}
+			if !json.Valid(w.Body.Bytes()) {
+				t.Fatalf("invalid JSON: %s", w.Body)
+			}
+
+			want, _ := json.Marshal(tt.want)
+			var got bytes.Buffer
+			if err := json.Compact(&got, w.Body.Bytes()); err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(got.Bytes(), want) {
+				t.Errorf("streamed %s, want %s", got.Bytes(), want)
+			}
+		})
+	}
+}
+
+func TestListWithPoints(t *testing.T) {
+	r := newServer(testConfig(t), newMemoryStore()).router()
+	want := map[string]int64{}
+	for name, points := range map[string]int64{"target-receipt.json": 28, "M&M-receipt.json": 109} {
+		want[process(t, r, example(t, name))] = points
+	}
+
+	tests := []struct {
+		query      string
+		withPoints bool
+	}{
+		{"", false},
+		{"?withPoints=false", false},
+		{"?withPoints=true", true},
+		{"?withPoints=true&limit=1", true},
+		{"?withPoints=true&offset=1", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.query, func(t *testing.T) {
+			w := serve(r, http.MethodGet, "/receipts"+tt.query, "")
+			var list bufferedList
+			if err := json.Unmarshal(w.Body.Bytes(), &list); err != nil || w.Code != http.StatusOK {
+				t.Fatalf("status %d: %s", w.Code, w.Body)
+			}
+			if len(list.Receipts) == 0 {
+				t.Fatal("no receipts listed")
+			}
+			for _, summary := range list.Receipts {
+				if !tt.withPoints {
+					if summary.Points != nil {
+						t.Errorf("%s has points", summary.ID)
+					}
+					continue
+				}
+				if summary.Points == nil || *summary.Points != want[summary.ID] {
+					t.Errorf("%s has points %v, want %d", summary.ID, summary.Points, want[summary.ID])
+				}
+				if points := pointsOf(t, r, summary.ID); points.Points != want[summary.ID] {
+					t.Errorf("%s lists %d points, but has %d", summary.ID, want[summary.ID], points.Points)
+				}
+			}
+		})
+	}
+}
+
+func TestListCursorPages(t *testing.T) {
+	receipt := Receipt{Retailer: "Target", PurchaseDate: "2022-01-01", PurchaseTime: "13:01", Total: "1.25", Items: []Item{{ShortDescription: "Pepsi", Price: "1.25"}}}
+	created := time.Date(2022, 1, 1, 13, 0, 0, 0, time.UTC)
+	var seeded []StoredReceipt
+	// Receipts created at the same time are ordered by ID.
+	for i, id := range []string{"g", "a", "f", "b", "e", "c", "d"} {
+		seeded = append(seeded, StoredReceipt{ID: id, Receipt: receipt, Version: 1, CreatedAt: created.Add(time.Duration(i/2) * time.Second)})
+	}
+	want := []string{"a", "g", "b", "f", "c", "e", "d"}
+
+	tests := []struct {
+		limit int
+		// deleted is removed from the store after the first page.
+		deleted string
+	}{
+		{1, ""},
+		{2, ""},
+		{3, ""},
+		{7, ""},
+		{10, ""},
+		{2, "g"},
+		{2, "b"},
+	}
+	for _, tt := range tests {
+		t.Run(fmt.Sprintf("%d deleting %q", tt.limit, tt.deleted), func(t *testing.T) {
+			store := newMemoryStore()
+			seedStored(t, store, seeded)
+			r := newServer(testConfig(t), store).router()
+
+			var walked []string
+			query := "?limit=" + strconv.Itoa(tt.limit)
+			for page := 0; ; page++ {
+				if page > len(seeded) {
+					t.Fatalf("still paging after %v", walked)
+				}
+				w := serve(r, http.MethodGet, "/receipts"+query, "")
+				var list bufferedList
+				if err := json.Unmarshal(w.Body.Bytes(), &list); err != nil || w.Code != http.StatusOK {
+					t.Fatalf("status = %d: %s", w.Code, w.Body)
+				}
+				if len(list.Receipts) > tt.limit {
+					t.Errorf("page %d has %d receipts", page, len(list.Receipts))
+				}
+				for _, summary := range list.Receipts {
+					walked = append(walked, summary.ID)
+				}
+				if page == 0 && tt.deleted != "" {
+					store.DeleteWhere(context.Background(), func(stored StoredReceipt) bool { return stored.ID == tt.deleted })
+				}
+				if list.NextCursor == "" {
+					break
+				}
+				query = "?limit=" + strconv.Itoa(tt.limit) + "&cursor=" + list.NextCursor
+			}
+
+			expected := want
+			if tt.deleted != "" && !slices.Contains(walked[:tt.limit], tt.deleted) {
+				expected = slices.DeleteFunc(slices.Clone(want), func(id string) bool { return id == tt.deleted })
+			}
+			if !slices.Equal(walked, expected) {
+				t.Errorf("walked %v, want %v", walked, expected)
+			}
+		})
+	}
+
+	r := newServer(testConfig(t), newMemoryStore()).router()
+	for _, query := range []string{"?cursor=nope", "?cursor=" + encodeCursor(seeded[0]) + "&offset=1"} {
+		if w := serve(r, http.MethodGet, "/receipts"+query, ""); w.Code != http.StatusBadRequest {
+			t.Errorf("%s: status = %d", query, w.Code)
+		}
+	}
+}
+
+func TestListTagged(t *testing.T) {
+	tagged := func(tags string) string {
+		return strings.Replace(example(t, "simple-receipt.json"), "{", `{"tags": [`+tags+`],`, 1)
+	}
+	for name, store := range testStores(t) {
+		t.Run(name, func(t *testing.T) {
+			r := newServer(testConfig(t, "-admin-token", "admin"), store).router()
+			grocery := process(t, r, tagged(`"grocery"`))
+			both := process(t, r, tagged(`"Grocery ", "work"`))
+			untagged := process(t, r, example(t, "simple-receipt.json"))
+			retagged := process(t, r, tagged(`"grocery"`))
+			if w := serve(r, http.MethodPut, "/receipts/"+retagged, tagged(`"work"`), "Authorization", "Bearer admin"); w.Code != http.StatusOK {
+				t.Fatalf("retagging: status = %d: %s", w.Code, w.Body)
+			}
+
+			tests := []struct {
+				query string
+				want  []string
+			}{
+				{"", []string{grocery, both, untagged, retagged}},
+				{"?tag=grocery", []string{grocery, both}},
+				{"?tag=GROCERY", []string{grocery, both}},
+				{"?tag=work", []string{both, retagged}},
+				{"?tag=work&limit=1", []string{both}},
+				{"?tag=none", []string{}},
+			}
+			for _, tt := range tests {
+				w := serve(r, http.MethodGet, "/receipts"+tt.query, "")
+				if w.Code != http.StatusOK {
+					t.Fatalf("%s: status = %d: %s", tt.query, w.Code, w.Body)
+				}
+				var list bufferedList
+				if err := json.Unmarshal(w.Body.Bytes(), &list); err != nil {
+					t.Fatal(err)
+				}
+				ids := []string{}
+				for _, summary := range list.Receipts {
+					ids = append(ids, summary.ID)
+				}
+				if !slices.Equal(ids, tt.want) {
+					t.Errorf("%s: listed %v, want %v", tt.query, ids, tt.want)
+				}
+			}
+		})
+	}
+
+	r := newServer(testConfig(t), newMemoryStore()).router()
+	for name, tags := range map[string]string{
+		"blank tag":     `"grocery", " "`,
+		"long tag":      `"` + strings.Repeat("x", 51) + `"`,
+		"too many tags": strings.Repeat(`"x", `, 20) + `"x"`,
+	} {
+		if w := serve(r, http.MethodPost, "/receipts/process", tagged(tags)); w.Code != http.StatusBadRequest {
+			t.Errorf("%s: status = %d, want 400", name, w.Code)
+		}
+	}
+}
@@ -0,0 +1,208 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func init() {
+	gin.SetMode(gin.TestMode)
+	gin.DefaultWriter = io.Discard
+	registerValidators()
+}
+
+// testConfig returns the configuration parsed from the flags in args.
+func testConfig(t *testing.T, args ...string) Config {
+	t.Helper()
+	cfg, err := parseConfig(args)
+	if err != nil {
+		t.Fatalf("parsing %v: %v", args, err)
+	}
+	return cfg
+}
+
+// serve sends a request with a JSON body to the handler. headers are pairs
+// of header names and values.
+func serve(handler http.Handler, method, path, body string, headers ...string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, path, strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	for i := 0; i+1 < len(headers); i += 2 {
+		req.Header.Set(headers[i], headers[i+1])
+	}
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+	return w
+}
+
+// example returns the receipt in examples/name.
+func example(t *testing.T, name string) string {
+	t.Helper()
+	data, err := os.ReadFile("examples/" + name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(data)
+}
+
+// process posts the receipt to the handler and returns its ID.
+func process(t *testing.T, handler http.Handler, receipt string, headers ...string) string {
+	t.Helper()
+	w := serve(handler, http.MethodPost, "/receipts/process", receipt, headers...)
+	var response ReceiptResponse
+	if err := json.Unmarshal(w.Body.Bytes(), &response); err != nil || w.Code != http.StatusOK {
+		t.Fatalf("processing: status %d: %s", w.Code, w.Body)
+	}
+	return response.ID
+}
+
+// pointsOf gets the points of a processed receipt, failing the test if that
+// doesn't succeed.
+func pointsOf(t *testing.T, handler http.Handler, id string, headers ...string) PointsResponse {
+	t.Helper()
+	w := serve(handler, http.MethodGet, "/receipts/"+id+"/points", "", headers...)
+	var response PointsResponse
+	if err := json.Unmarshal(w.Body.Bytes(), &response); err != nil || w.Code != http.StatusOK {
+		t.Fatalf("getting points: status %d: %s", w.Code, w.Body)
+	}
+	return response
+}
+
+func TestGetFullReceipt(t *testing.T) {
+	r := newServer(testConfig(t), newMemoryStore()).router()
+	ids := map[string]string{}
+	for _, name := range []string{"simple-receipt.json", "target-receipt.json", "M&M-receipt.json"} {
+		ids[name] = process(t, r, example(t, name))
+	}
+
+	for name, id := range ids {
+		t.Run(name, func(t *testing.T) {
+			w := serve(r, http.MethodGet, "/receipts/"+id+"/full", "")
+			var full struct {
+				Receipt *Receipt `json:"receipt"`
+				Points  *int64   `json:"points"`
+			}
+			if err := json.Unmarshal(w.Body.Bytes(), &full); err != nil || w.Code != http.StatusOK {
+				t.Fatalf("status %d: %s", w.Code, w.Body)
+			}
+			if full.Receipt == nil || full.Points == nil {
+				t.Fatalf("receipt or points missing: %s", w.Body)
+			}
+
+			var sent Receipt
+			json.Unmarshal([]byte(example(t, name)), &sent)
+			if full.Receipt.Retailer != sent.Retailer || full.Receipt.Total != sent.Total || len(full.Receipt.Items) != len(sent.Items) {
+				t.Errorf("receipt = %+v, want %+v", *full.Receipt, sent)
+			}
+			var points PointsResponse
+			json.Unmarshal(serve(r, http.MethodGet, "/receipts/"+id+"/points", "").Body.Bytes(), &points)
+			if *full.Points != points.Points {
+				t.Errorf("points = %d, want %d like /points", *full.Points, points.Points)
+			}
+			// The examples of the specification.
+			if want, ok := map[string]int64{"target-receipt.json": 28, "M&M-receipt.json": 109}[name]; ok && *full.Points != want {
+				t.Errorf("points = %d, want %d", *full.Points, want)
+			}
+		})
+	}
+
+	if w := serve(r, http.MethodGet, "/receipts/missing/full", ""); w.Code != http.StatusNotFound {
+		t.Errorf("unknown ID: status = %d, want 404", w.Code)
+	}
+}
+
+func TestPointsSchemes(t *testing.T) {
+	tests := []struct {
+		name    string
+		args    []string
+		receipt string
+		v2      int64
+		legacy  int64
+	}{
+		{"target receipt", nil, example(t, "target-receipt.json"), 28, 28},
+		{"M&M receipt", nil, example(t, "M&M-receipt.json"), 109, 109},
+		{"capped points", []string{"-max-points", "50"}, example(t, "M&M-receipt.json"), 50, 109},
+		{"configured rules", []string{"-round-points", "5", "-retailer-bonus", "Target=100"}, example(t, "target-receipt.json"), 130, 28},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := newServer(testConfig(t, tt.args...), newMemoryStore()).router()
+			id := process(t, r, tt.receipt)
+			for scheme, want := range map[string]int64{"": tt.v2, "v2": tt.v2, "legacy": tt.legacy} {
+				w := serve(r, http.MethodGet, "/receipts/"+id+"/points?scheme="+scheme, "")
+				if w.Code != http.StatusOK {
+					t.Fatalf("scheme %q: status = %d: %s", scheme, w.Code, w.Body)
+				}
+				var response PointsResponse
+				if err := json.Unmarshal(w.Body.Bytes(), &response); err != nil {
+					t.Fatal(err)
+				}
+				if response.Points != want {
+					t.Errorf("scheme %q: points = %d, want %d", scheme, response.Points, want)
+				}
+				if scheme == "legacy" && response.RulesVersion != pointsSchemeLegacy {
+					t.Errorf("legacy rules version = %q", response.RulesVersion)
+				}
+			}
+		})
+	}
+
+	r := newServer(testConfig(t), newMemoryStore()).router()
+	id := process(t, r, example(t, "target-receipt.json"))
+	if w := serve(r, http.MethodGet, "/receipts/"+id+"/points?scheme=v3", ""); w.Code != http.StatusBadRequest {
+		t.Errorf("unknown scheme: status = %d, want 400", w.Code)
+	}
+
+	// The original scored a receipt with an unparsable item price as 0, even
+	// with points counted before the item.
+	store := newMemoryStore()
+	unparsable := Receipt{Retailer: "Target", PurchaseDate: "2022-01-01", PurchaseTime: "14:33", Total: "2.00", Items: []Item{{ShortDescription: "Pepsi", Price: "1.00"}, {ShortDescription: "abc", Price: "1,00"}}}
+	if err := store.Add(context.Background(), "unparsable", unparsable, nil); err != nil {
+		t.Fatal(err)
+	}
+	r = newServer(testConfig(t), store).router()
+	w := serve(r, http.MethodGet, "/receipts/unparsable/points?scheme=legacy", "")
+	if w.Code != http.StatusOK || !strings.Contains(w.Body.String(), `"points":0,`) {
+		t.Errorf("legacy points with an unparsable price: %d %s, want 0 points", w.Code, w.Body)
+	}
+}
+
+func TestTrustedProxies(t *testing.T) {
+	// httptest requests come from 192.0.2.1.
+	tests := []struct {
+		name    string
+		args    []string
+		headers []string
+		want    string
+	}{
+		{"direct", nil, nil, "192.0.2.1"},
+		{"untrusted proxy", nil, []string{"X-Forwarded-For", "203.0.113.7"}, "192.0.2.1"},
+		{"untrusted real IP", nil, []string{"X-Real-IP", "203.0.113.7"}, "192.0.2.1"},
+		{"trusted proxy", []string{"-trusted-proxy", "192.0.2.1"}, []string{"X-Forwarded-For", "203.0.113.7"}, "203.0.113.7"},
+		{"trusted CIDR", []string{"-trusted-proxy", "192.0.2.0/24"}, []string{"X-Forwarded-For", "203.0.113.7"}, "203.0.113.7"},
+		{"trusted proxy without header", []string{"-trusted-proxy", "192.0.2.1"}, nil, "192.0.2.1"},
+		{"other proxy trusted", []string{"-trusted-proxy", "10.0.0.0/8"}, []string{"X-Forwarded-For", "203.0.113.7"}, "192.0.2.1"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := newServer(testConfig(t, tt.args...), newMemoryStore()).router()
+			r.GET("/client-ip", func(c *gin.Context) { c.String(http.StatusOK, c.ClientIP()) })
+			if got := serve(r, http.MethodGet, "/client-ip", "", tt.headers...).Body.String(); got != tt.want {
+				t.Errorf("client IP = %s, want %s", got, tt.want)
+			}
+		})
+	}
+
+	for _, value := range []string{"proxy", "10.0.0.0/33"} {
+		if _, err := parseConfig([]string{"-trusted-proxy", value}); err == nil {
+			t.Errorf("-trusted-proxy %s was accepted", value)
+		}
+	}
+}
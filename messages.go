@@ -0,0 +1,168 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Error codes. They are sent along with every error message and stay the same
+// whatever language the message is in.
+const (
+	codeReceiptInvalid       = "receipt_invalid"
+	codeBatchInvalid         = "batch_invalid"
+	codePointsBatchInvalid   = "points_batch_invalid"
+	codeJSONTooComplex       = "json_too_complex"
+	codeRulesInvalid         = "rules_invalid"
+	codeReceiptNotFound      = "receipt_not_found"
+	codeInvalidReceiptID     = "invalid_receipt_id"
+	codeGroupNotFound        = "group_not_found"
+	codeImageNotFound        = "image_not_found"
+	codeRetailerDenied       = "retailer_denied"
+	codeVersionMismatch      = "version_mismatch"
+	codeInvalidOffset        = "invalid_offset"
+	codeInvalidLimit         = "invalid_limit"
+	codeInvalidCursor        = "invalid_cursor"
+	codeInvalidScheme        = "invalid_scheme"
+	codeInvalidBefore        = "invalid_before"
+	codeInvalidIterations    = "invalid_iterations"
+	codeDeleteFilterRequired = "delete_filter_required"
+	codeAdminTokenRequired   = "admin_token_required"
+	codeTokenInvalid         = "token_invalid"
+	codeUserTokenRequired    = "user_token_required"
+	codeUserMismatch         = "user_mismatch"
+	codeUserIDInvalid        = "user_id_invalid"
+	codeRequestTimeout       = "request_timeout"
+	codeServerBusy           = "server_busy"
+	codeBodyTooLarge         = "body_too_large"
+	codeBodyUnreadable       = "body_unreadable"
+	codeStoreFailed          = "store_failed"
+	codeStoreUnavailable     = "store_unavailable"
+	codeStoreFull            = "store_full"
+	codeStoreNotWritable     = "store_not_writable"
+	codeReadOnly             = "read_only"
+	codeMigrationConflict    = "migration_conflict"
+	codeReadOnlyInvalid      = "read_only_invalid"
+)
+
+// defaultLanguage is used when the client accepts none of the catalog's languages.
+const defaultLanguage = "en"
+
+// messages is the catalog of error messages by language and code. Messages
+// may contain fmt verbs, filled in with the arguments given to errorBody.
+var messages = map[string]map[string]string{
+	"en": {
+		codeReceiptInvalid:       "The receipt is invalid.",
+		codeBatchInvalid:         "The batch must be a JSON array of 1 to %d receipts.",
+		codePointsBatchInvalid:   "Send the receipt IDs as {\"ids\": [...]}, 1 to %d of them.",
+		codeJSONTooComplex:       "The JSON is nested too deeply or has too many array elements.",
+		codeRulesInvalid:         "The rules config is invalid.",
+		codeReceiptNotFound:      "No receipt found for that ID.",
+		codeInvalidReceiptID:     "Receipt IDs must be 1 to %d letters, digits, '-', '_', '.' or '~'.",
+		codeGroupNotFound:        "No receipts found for that group.",
+		codeImageNotFound:        "No image attached to that receipt.",
+		codeRetailerDenied:       "Receipts from this retailer are not accepted.",
+		codeVersionMismatch:      "The receipt has been changed since it was read.",
+		codeInvalidOffset:        "offset must be a non-negative number.",
+		codeInvalidLimit:         "limit must be a number from 1 to %d.",
+		codeInvalidCursor:        "cursor must come from an earlier page or export, and can't be combined with offset.",
+		codeInvalidScheme:        "scheme must be v2 or legacy.",
+		codeInvalidBefore:        "before must be a date like 2022-01-01.",
+		codeInvalidIterations:    "n must be a number from 1 to %d.",
+		codeDeleteFilterRequired: "Specify a retailer or before filter, or all=true to delete every receipt.",
+		codeAdminTokenRequired:   "A valid admin token is required.",
+		codeTokenInvalid:         "The token is invalid or has been tampered with.",
+		codeUserTokenRequired:    "A valid user token is required in the X-User-Token header.",
+		codeUserMismatch:         "That belongs to another user.",
+		codeUserIDInvalid:        "The userId must be 1 to 100 characters.",
+		codeRequestTimeout:       "The request timed out.",
+		codeServerBusy:           "The server is busy, please retry shortly.",
+		codeBodyTooLarge:         "The request body must not exceed %d bytes.",
+		codeBodyUnreadable:       "The request body couldn't be read.",
+		codeStoreFailed:          "The receipt store failed.",
+		codeStoreUnavailable:     "The receipt store is temporarily unavailable.",
+		codeStoreFull:            "The receipt store is full, no more receipts can be processed.",
+		codeStoreNotWritable:     "The receipt store is not writable.",
+		codeReadOnly:             "The server is in read-only mode, receipts can't be changed right now.",
+		codeMigrationConflict:    "The receipts have already been migrated, or the target already holds some of them.",
+		codeReadOnlyInvalid:      "Send {\"readOnly\": true} or {\"readOnly\": false}.",
+	},
+	"es": {
+		codeReceiptInvalid:       "El recibo no es válido.",
+		codeBatchInvalid:         "El lote debe ser un array JSON de 1 a %d recibos.",
+		codePointsBatchInvalid:   "Envíe los ID de recibo como {\"ids\": [...]}, de 1 a %d.",
+		codeJSONTooComplex:       "El JSON está anidado demasiado o tiene demasiados elementos en un array.",
+		codeRulesInvalid:         "La configuración de reglas no es válida.",
+		codeReceiptNotFound:      "No se encontró ningún recibo con ese ID.",
+		codeInvalidReceiptID:     "Los ID de recibo deben tener de 1 a %d letras, dígitos, '-', '_', '.' o '~'.",
+		codeGroupNotFound:        "No se encontraron recibos para ese grupo.",
+		codeImageNotFound:        "Ese recibo no tiene ninguna imagen adjunta.",
+		codeRetailerDenied:       "No se aceptan recibos de este comercio.",
+		codeVersionMismatch:      "El recibo ha cambiado desde que se leyó.",
+		codeInvalidOffset:        "offset debe ser un número no negativo.",
+		codeInvalidLimit:         "limit debe ser un número del 1 al %d.",
+		codeInvalidCursor:        "cursor debe venir de una página o exportación anterior y no puede combinarse con offset.",
+		codeInvalidScheme:        "scheme debe ser v2 o legacy.",
+		codeInvalidBefore:        "before debe ser una fecha como 2022-01-01.",
+		codeInvalidIterations:    "n debe ser un número del 1 al %d.",
+		codeDeleteFilterRequired: "Indique un filtro retailer o before, o all=true para borrar todos los recibos.",
+		codeAdminTokenRequired:   "Se requiere un token de administrador válido.",
+		codeTokenInvalid:         "El token no es válido o ha sido manipulado.",
+		codeUserTokenRequired:    "Se requiere un token de usuario válido en la cabecera X-User-Token.",
+		codeUserMismatch:         "Eso pertenece a otro usuario.",
+		codeUserIDInvalid:        "El userId debe tener de 1 a 100 caracteres.",
+		codeRequestTimeout:       "La solicitud superó el tiempo de espera.",
+		codeServerBusy:           "El servidor está ocupado, vuelva a intentarlo en breve.",
+		codeBodyTooLarge:         "El cuerpo de la solicitud no debe superar los %d bytes.",
+		codeBodyUnreadable:       "No se pudo leer el cuerpo de la solicitud.",
+		codeStoreFailed:          "Falló el almacén de recibos.",
+		codeStoreUnavailable:     "El almacén de recibos no está disponible temporalmente.",
+		codeStoreFull:            "El almacén de recibos está lleno, no se pueden procesar más recibos.",
+		codeStoreNotWritable:     "No se puede escribir en el almacén de recibos.",
+		codeReadOnly:             "El servidor está en modo de solo lectura, ahora no se pueden modificar recibos.",
+		codeMigrationConflict:    "Los recibos ya se migraron, o el destino ya contiene algunos de ellos.",
+		codeReadOnlyInvalid:      "Envíe {\"readOnly\": true} o {\"readOnly\": false}.",
+	},
+}
+
+// errorBody returns the error response for code, with the message in the
+// language the client prefers.
+func errorBody(c *gin.Context, code string, args ...any) gin.H {
+	language := preferredLanguage(c.GetHeader("Accept-Language"))
+	c.Header("Content-Language", language)
+
+	message := messages[language][code]
+	if len(args) > 0 {
+		message = fmt.Sprintf(message, args...)
+	}
+	return gin.H{"error": message, "code": code}
+}
+
+// preferredLanguage picks the catalog language with the highest quality in an
+// Accept-Language header such as "es-MX,es;q=0.9,en;q=0.5". Only the primary
+// language subtag is compared.
+func preferredLanguage(header string) string {
+	best, bestQuality := defaultLanguage, 0.0
+	for _, part := range strings.Split(header, ",") {
+		tag, params, _ := strings.Cut(part, ";")
+		primary, _, _ := strings.Cut(strings.ToLower(strings.TrimSpace(tag)), "-")
+		if _, ok := messages[primary]; !ok {
+			continue
+		}
+
+		quality := 1.0
+		if q, ok := strings.CutPrefix(strings.TrimSpace(params), "q="); ok {
+			parsed, err := strconv.ParseFloat(q, 64)
+			if err != nil {
+				continue
+			}
+			quality = parsed
+		}
+		if quality > bestQuality {
+			best, bestQuality = primary, quality
+		}
+	}
+	return best
+}
@@ -0,0 +1,72 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+)
+
+func TestPreferredLanguage(t *testing.T) {
+	tests := []struct {
+		header string
+		want   string
+	}{
+		{"", "en"},
+		{"es", "es"},
+		{"es-MX,es;q=0.9,en;q=0.5", "es"},
+		{"en;q=0.4, es;q=0.8", "es"},
+		{"fr, de;q=0.9", "en"},
+		{"fr, es;q=0.1", "es"},
+		{"ES-es", "es"},
+		{"es;q=abc, en;q=0.1", "en"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.header, func(t *testing.T) {
+			if got := preferredLanguage(tt.header); got != tt.want {
+				t.Errorf("preferredLanguage = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLocalizedErrors(t *testing.T) {
+	r := newServer(testConfig(t), newMemoryStore()).router()
+	tests := []struct {
+		language string
+		message  string
+	}{
+		{"en-US", messages["en"][codeReceiptNotFound]},
+		{"es", messages["es"][codeReceiptNotFound]},
+	}
+	for _, tt := range tests {
+		t.Run(tt.language, func(t *testing.T) {
+			w := serve(r, http.MethodGet, "/receipts/missing/points", "", "Accept-Language", tt.language)
+			var body struct{ Error, Code string }
+			json.Unmarshal(w.Body.Bytes(), &body)
+			if body.Error != tt.message || body.Code != codeReceiptNotFound {
+				t.Errorf("error %q, code %q, want %q", body.Error, body.Code, tt.message)
+			}
+			if language := w.Header().Get("Content-Language"); language != tt.language[:2] {
+				t.Errorf("Content-Language = %q", language)
+			}
+		})
+	}
+	if messages["en"][codeReceiptNotFound] == messages["es"][codeReceiptNotFound] {
+		t.Error("the messages aren't translated")
+	}
+}
+
+func TestMessageCatalogComplete(t *testing.T) {
+	for code := range messages[defaultLanguage] {
+		for language, catalog := range messages {
+			if catalog[code] == "" {
+				t.Errorf("no %s message for %s", language, code)
+			}
+		}
+	}
+	for language, catalog := range messages {
+		if len(catalog) != len(messages[defaultLanguage]) {
+			t.Errorf("%s has %d messages, %s %d", language, len(catalog), defaultLanguage, len(messages[defaultLanguage]))
+		}
+	}
+}
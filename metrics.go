@@ -0,0 +1,112 @@
+package main
+
+import (
+	"strings"
+	"sync"
+
+	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// otherRetailers is the retailer label used once the label limit is reached.
+const otherRetailers = "other"
+
+// metrics holds the Prometheus metrics served on /metrics.
+type metrics struct {
+	registry *prometheus.Registry
+
+	receipts prometheus.Counter
+	points   *prometheus.CounterVec
+	deducted *prometheus.CounterVec
+	ruleHits *prometheus.CounterVec
+
+	// retailers are the retailer labels handed out so far, at most maxRetailers.
+	mu           sync.Mutex
+	retailers    map[string]bool
+	maxRetailers int
+}
+
+func newMetrics(maxRetailers int) *metrics {
+	m := &metrics{
+		registry: prometheus.NewRegistry(),
+		receipts: prometheus.NewCounter(prometheus.CounterOpts{
+			Name: "receipt_processor_receipts_processed_total",
+			Help: "Number of receipts processed.",
+		}),
+		points: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "receipt_processor_points_awarded_total",
+			Help: "Points awarded to receipts, by lowercased retailer name.",
+		}, []string{"retailer"}),
+		deducted: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "receipt_processor_points_deducted_total",
+			Help: "Negative points of receipts, as a positive number, by lowercased retailer name.",
+		}, []string{"retailer"}),
+		ruleHits: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "receipt_processor_rule_hits_total",
+			Help: "Number of times a scoring rule awarded points to a receipt, by rule name.",
+		}, []string{"rule"}),
+		retailers:    make(map[string]bool),
+		maxRetailers: maxRetailers,
+	}
+	// The rules are known up front, so they are all listed from the start.
+	for _, r := range scoringRules {
+		m.ruleHits.WithLabelValues(r.name)
+	}
+	m.registry.MustRegister(
+		m.receipts,
+		m.points,
+		m.deducted,
+		m.ruleHits,
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+	)
+	return m
+}
+
+// retailerLabel returns the label value for the retailer. Only the first
+// maxRetailers retailers get a label of their own, every later one is
+// counted as otherRetailers, so the number of series stays bounded.
+func (m *metrics) retailerLabel(retailer string) string {
+	retailer = strings.ToLower(strings.TrimSpace(retailer))
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.retailers[retailer] {
+		return retailer
+	}
+	if len(m.retailers) >= m.maxRetailers {
+		return otherRetailers
+	}
+	m.retailers[retailer] = true
+	return retailer
+}
+
+// observePoints counts the points scored for a receipt from the retailer.
+// Counters can't go down, so negative points, e.g. from penalties, are counted
+// as deducted instead.
+func (m *metrics) observePoints(retailer string, points int64) {
+	label := m.retailerLabel(retailer)
+	if points < 0 {
+		m.deducted.WithLabelValues(label).Add(float64(-points))
+		return
+	}
+	m.points.WithLabelValues(label).Add(float64(points))
+}
+
+// observeAwards counts the rules that awarded points to a receipt. Rule names
+// come from the scoring code, never from requests, so they are safe labels.
+func (m *metrics) observeAwards(awards []award) {
+	for _, a := range awards {
+		if a.Points != 0 {
+			m.ruleHits.WithLabelValues(a.Rule).Inc()
+		}
+	}
+}
+
+// handler serves the metrics in the Prometheus text format.
+func (m *metrics) handler() gin.HandlerFunc {
+	return gin.WrapH(promhttp.HandlerFor(m.registry, promhttp.HandlerOpts{}))
+}
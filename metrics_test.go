@@ -0,0 +1,122 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+// scrape returns the lines of the /metrics output of the handler.
+func scrape(t *testing.T, handler http.Handler) []string {
+	t.Helper()
+	w := serve(handler, http.MethodGet, "/metrics", "")
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d", w.Code)
+	}
+	return strings.Split(w.Body.String(), "\n")
+}
+
+// hasMetric reports whether the line is in the /metrics output.
+func hasMetric(lines []string, line string) bool {
+	for _, l := range lines {
+		if l == line {
+			return true
+		}
+	}
+	return false
+}
+
+func TestPointsByRetailer(t *testing.T) {
+	observations := []struct {
+		retailer string
+		points   int64
+	}{
+		{"Target", 28},
+		{"M&M Corner Market", 109},
+		{" target", 2},
+		// The limit of two retailers is reached here.
+		{"Walgreens", 15},
+		{"Costco", 5},
+		{"M&M Corner Market", 1},
+		{"Costco", -3},
+	}
+	tests := []struct {
+		limit string
+		want  []string
+		// absent are retailer labels that must not appear.
+		absent []string
+	}{
+		{"2", []string{
+			`receipt_processor_points_awarded_total{retailer="target"} 30`,
+			`receipt_processor_points_awarded_total{retailer="m&m corner market"} 110`,
+			`receipt_processor_points_awarded_total{retailer="other"} 20`,
+			`receipt_processor_points_deducted_total{retailer="other"} 3`,
+		}, []string{`retailer="walgreens"`, `retailer="costco"`}},
+		{"10", []string{
+			`receipt_processor_points_awarded_total{retailer="walgreens"} 15`,
+			`receipt_processor_points_awarded_total{retailer="costco"} 5`,
+			`receipt_processor_points_deducted_total{retailer="costco"} 3`,
+		}, []string{`retailer="other"`}},
+		{"0", []string{
+			`receipt_processor_points_awarded_total{retailer="other"} 160`,
+		}, []string{`retailer="target"`}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.limit, func(t *testing.T) {
+			s := newServer(testConfig(t, "-metrics-retailers", tt.limit), newMemoryStore())
+			for _, o := range observations {
+				s.metrics.observePoints(o.retailer, o.points)
+			}
+			lines := scrape(t, s.router())
+			for _, line := range tt.want {
+				if !hasMetric(lines, line) {
+					t.Errorf("missing %s", line)
+				}
+			}
+			for _, line := range lines {
+				for _, label := range tt.absent {
+					if strings.Contains(line, label) {
+						t.Errorf("unexpected %s", line)
+					}
+				}
+			}
+		})
+	}
+}
+
+func TestRuleHits(t *testing.T) {
+	s := newServer(testConfig(t), newMemoryStore())
+	r := s.router()
+	target := process(t, r, example(t, "target-receipt.json"))
+	process(t, r, example(t, "target-receipt.json"))
+	process(t, r, example(t, "M&M-receipt.json"))
+	// Cached points aren't counted again.
+	pointsOf(t, r, target)
+
+	lines := scrape(t, r)
+	tests := []struct {
+		rule string
+		hits int
+	}{
+		{"retailerName", 3},
+		{"itemPairs", 3},
+		// Two items of every Target receipt qualify.
+		{"itemDescription", 4},
+		{"oddDay", 2},
+		{"roundTotal", 1},
+		{"quarterTotal", 1},
+		{"afternoonTime", 1},
+		// Rules that never fired are listed as well.
+		{"retailerStreak", 0},
+	}
+	for _, tt := range tests {
+		line := fmt.Sprintf(`receipt_processor_rule_hits_total{rule="%s"} %d`, tt.rule, tt.hits)
+		if !hasMetric(lines, line) {
+			t.Errorf("missing %s", line)
+		}
+	}
+	if !hasMetric(lines, "receipt_processor_receipts_processed_total 3") {
+		t.Error("processed receipts aren't counted")
+	}
+}
@@ -0,0 +1,85 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// requestTimeout bounds the context of every request to the given duration,
+// so store operations give up instead of blocking indefinitely.
+func requestTimeout(timeout time.Duration) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(c.Request.Context(), timeout)
+		defer cancel()
+
+		c.Request = c.Request.WithContext(ctx)
+		c.Next()
+	}
+}
+
+// concurrencyLimit lets at most limit requests be handled at once. Requests
+// beyond that are rejected right away with a 503 rather than queued, so the
+// work in flight stays bounded.
+func concurrencyLimit(limit int) gin.HandlerFunc {
+	slots := make(chan struct{}, limit)
+	return func(c *gin.Context) {
+		select {
+		case slots <- struct{}{}:
+		default:
+			c.Header("Retry-After", "1")
+			c.AbortWithStatusJSON(http.StatusServiceUnavailable, errorBody(c, codeServerBusy))
+			return
+		}
+		defer func() { <-slots }()
+		c.Next()
+	}
+}
+
+// limitBody rejects request bodies larger than max bytes with a 413. The body
+// is read up front through http.MaxBytesReader, so the limit holds for every
+// handler and bodies without a Content-Length alike.
+func limitBody(max int64) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if c.Request.ContentLength > max {
+			c.AbortWithStatusJSON(http.StatusRequestEntityTooLarge, errorBody(c, codeBodyTooLarge, max))
+			return
+		}
+		data, err := io.ReadAll(http.MaxBytesReader(c.Writer, c.Request.Body, max))
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			c.AbortWithStatusJSON(http.StatusRequestEntityTooLarge, errorBody(c, codeBodyTooLarge, max))
+			return
+		}
+		if err != nil {
+			c.AbortWithStatusJSON(http.StatusBadRequest, errorBody(c, codeBodyUnreadable))
+			return
+		}
+		c.Request.Body = io.NopCloser(bytes.NewReader(data))
+		c.Next()
+	}
+}
+
+// defaultSecurityHeaders are sent with every response unless configured
+// otherwise. The API only serves JSON and images, so nothing needs to be
+// framed or load further resources.
+var defaultSecurityHeaders = map[string]string{
+	"X-Content-Type-Options":  "nosniff",
+	"X-Frame-Options":         "DENY",
+	"Content-Security-Policy": "default-src 'none'; frame-ancestors 'none'",
+}
+
+// securityHeaders sets the given headers on every response.
+func securityHeaders(headers map[string]string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		for name, value := range headers {
+			c.Header(name, value)
+		}
+		c.Next()
+	}
+}
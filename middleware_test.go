@@ -0,0 +1,201 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestLimitBody(t *testing.T) {
+	receipt := example(t, "simple-receipt.json")
+	tests := []struct {
+		name    string
+		limit   string
+		chunked bool
+		status  int
+	}{
+		{"below the limit", "4096", false, http.StatusOK},
+		{"announced above the limit", "100", false, http.StatusRequestEntityTooLarge},
+		{"chunked above the limit", "100", true, http.StatusRequestEntityTooLarge},
+		{"disabled", "0", true, http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := newServer(testConfig(t, "-max-body-size", tt.limit), newMemoryStore()).router()
+
+			req := httptest.NewRequest(http.MethodPost, "/receipts/process", strings.NewReader(receipt))
+			req.Header.Set("Content-Type", "application/json")
+			if tt.chunked {
+				req.ContentLength = -1
+			}
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, req)
+			if w.Code != tt.status {
+				t.Errorf("status = %d, want %d: %s", w.Code, tt.status, w.Body)
+			}
+		})
+	}
+}
+
+// slowStore takes delay for every lookup and insert, unless the context ends first.
+type slowStore struct {
+	Store
+	delay time.Duration
+}
+
+func (s slowStore) wait(ctx context.Context) error {
+	select {
+	case <-time.After(s.delay):
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (s slowStore) Add(ctx context.Context, id string, receipt Receipt, breakdown []award) error {
+	if err := s.wait(ctx); err != nil {
+		return err
+	}
+	return s.Store.Add(ctx, id, receipt, breakdown)
+}
+
+func (s slowStore) Get(ctx context.Context, id string) (StoredReceipt, error) {
+	if err := s.wait(ctx); err != nil {
+		return StoredReceipt{}, err
+	}
+	return s.Store.Get(ctx, id)
+}
+
+func TestRequestTimeout(t *testing.T) {
+	store := newMemoryStore()
+	if err := store.Add(context.Background(), "a", Receipt{Retailer: "Target", PurchaseDate: "2022-01-01", PurchaseTime: "13:01", Total: "1.00", Items: []Item{{ShortDescription: "ab", Price: "1.00"}}}, nil); err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name    string
+		timeout string
+		delay   time.Duration
+		status  int
+	}{
+		{"within the deadline", "1s", time.Millisecond, http.StatusOK},
+		{"past the deadline", "10ms", time.Minute, http.StatusGatewayTimeout},
+		{"disabled", "0", 20 * time.Millisecond, http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := newServer(testConfig(t, "-request-timeout", tt.timeout), slowStore{Store: store, delay: tt.delay}).router()
+			requests := []struct{ method, path, body string }{
+				{http.MethodGet, "/receipts/a/points", ""},
+				{http.MethodPost, "/receipts/process", example(t, "simple-receipt.json")},
+			}
+			for _, req := range requests {
+				start := time.Now()
+				w := serve(r, req.method, req.path, req.body)
+				if w.Code != tt.status {
+					t.Errorf("%s %s: status = %d, want %d: %s", req.method, req.path, w.Code, tt.status, w.Body)
+				}
+				if elapsed := time.Since(start); elapsed > 10*time.Second {
+					t.Errorf("%s %s took %v", req.method, req.path, elapsed)
+				}
+			}
+		})
+	}
+}
+
+func TestSecurityHeaders(t *testing.T) {
+	tests := []struct {
+		name string
+		args []string
+		want map[string]string
+	}{
+		{"default", nil, map[string]string{
+			"X-Content-Type-Options":  "nosniff",
+			"X-Frame-Options":         "DENY",
+			"Content-Security-Policy": "default-src 'none'; frame-ancestors 'none'",
+		}},
+		{"configured", []string{"-security-header", "x-frame-options=SAMEORIGIN", "-security-header", "Content-Security-Policy=", "-security-header", "Referrer-Policy=no-referrer"}, map[string]string{
+			"X-Content-Type-Options":  "nosniff",
+			"X-Frame-Options":         "SAMEORIGIN",
+			"Content-Security-Policy": "",
+			"Referrer-Policy":         "no-referrer",
+		}},
+		{"disabled", []string{"-no-security-headers"}, map[string]string{
+			"X-Content-Type-Options":  "",
+			"X-Frame-Options":         "",
+			"Content-Security-Policy": "",
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := newServer(testConfig(t, tt.args...), newMemoryStore()).router()
+			// Errors and unknown routes have the headers as well.
+			for _, path := range []string{"/receipts", "/receipts/missing/points", "/nope"} {
+				w := serve(r, http.MethodGet, path, "")
+				for name, value := range tt.want {
+					if got := w.Header().Get(name); got != value {
+						t.Errorf("GET %s: %s = %q, want %q", path, name, got, value)
+					}
+				}
+			}
+		})
+	}
+
+	if _, err := parseConfig([]string{"-security-header", "nosniff"}); err == nil {
+		t.Error("-security-header without a name was accepted")
+	}
+}
+
+// blockingStore blocks every Get until release is closed, announcing each on
+// entered first.
+type blockingStore struct {
+	Store
+	entered chan struct{}
+	release chan struct{}
+}
+
+func (s blockingStore) Get(ctx context.Context, id string) (StoredReceipt, error) {
+	s.entered <- struct{}{}
+	<-s.release
+	return s.Store.Get(ctx, id)
+}
+
+func TestConcurrencyLimit(t *testing.T) {
+	for _, limit := range []int{1, 3} {
+		t.Run(strconv.Itoa(limit), func(t *testing.T) {
+			store := blockingStore{Store: newMemoryStore(), entered: make(chan struct{}), release: make(chan struct{})}
+			r := newServer(testConfig(t, "-max-concurrent", strconv.Itoa(limit)), store).router()
+
+			statuses := make(chan int, limit)
+			for range limit {
+				go func() { statuses <- serve(r, http.MethodGet, "/receipts/a/points", "").Code }()
+				<-store.entered
+			}
+
+			// Every slot is taken by a blocked request.
+			w := serve(r, http.MethodGet, "/receipts/a/points", "")
+			if w.Code != http.StatusServiceUnavailable || w.Header().Get("Retry-After") == "" {
+				t.Errorf("saturated: status = %d, Retry-After %q: %s", w.Code, w.Header().Get("Retry-After"), w.Body)
+			}
+			if !strings.Contains(w.Body.String(), codeServerBusy) {
+				t.Errorf("saturated: %s, want %s", w.Body, codeServerBusy)
+			}
+
+			close(store.release)
+			for range limit {
+				if status := <-statuses; status != http.StatusNotFound {
+					t.Errorf("blocked request: status = %d", status)
+				}
+			}
+
+			// The slots are free again once the blocked requests finish.
+			go func() { <-store.entered }()
+			if w := serve(r, http.MethodGet, "/receipts/a/points", ""); w.Code != http.StatusNotFound {
+				t.Errorf("after the release: status = %d: %s", w.Code, w.Body)
+			}
+		})
+	}
+}
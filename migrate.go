@@ -0,0 +1,156 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net/http"
+	"sync"
+
+	"github.com/gin-gonic/gin"
+)
+
+// errAlreadyMigrated is returned for a second migration of a switchableStore.
+var errAlreadyMigrated = errors.New("receipts have already been migrated")
+
+// importer is implemented by stores that can take over complete stored
+// receipts, such as the receipts of another store.
+type importer interface {
+	Store
+	Import(ctx context.Context, receipts []StoredReceipt) error
+}
+
+// switchableStore passes every call through to the current store, which
+// migrate can replace while the server is running.
+type switchableStore struct {
+	// mu is held for reading by every call, and for writing by migrate so no
+	// change gets lost between copying the receipts and switching over.
+	mu       sync.RWMutex
+	current  Store
+	migrated bool
+}
+
+func newSwitchableStore(store Store) *switchableStore {
+	return &switchableStore{current: store}
+}
+
+// migrate copies every receipt to target and makes it the current store. If
+// the copy fails the current store stays in use. It returns the number of
+// receipts migrated.
+func (s *switchableStore) migrate(ctx context.Context, target importer) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.migrated {
+		return 0, errAlreadyMigrated
+	}
+	receipts, err := s.current.List(ctx)
+	if err != nil {
+		return 0, err
+	}
+	if err := target.Import(ctx, receipts); err != nil {
+		return 0, err
+	}
+	s.current = target
+	s.migrated = true
+	return len(receipts), nil
+}
+
+func (s *switchableStore) Add(ctx context.Context, id string, receipt Receipt, breakdown []award) error {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.current.Add(ctx, id, receipt, breakdown)
+}
+
+func (s *switchableStore) Get(ctx context.Context, id string) (StoredReceipt, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.current.Get(ctx, id)
+}
+
+func (s *switchableStore) GetMany(ctx context.Context, ids []string) (map[string]StoredReceipt, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return getMany(ctx, s.current, ids)
+}
+
+func (s *switchableStore) Update(ctx context.Context, id string, receipt Receipt, breakdown []award, version int) (StoredReceipt, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.current.Update(ctx, id, receipt, breakdown, version)
+}
+
+func (s *switchableStore) SetPoints(ctx context.Context, id string, points int64) error {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.current.SetPoints(ctx, id, points)
+}
+
+func (s *switchableStore) List(ctx context.Context) ([]StoredReceipt, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.current.List(ctx)
+}
+
+func (s *switchableStore) Group(ctx context.Context, groupID string) ([]StoredReceipt, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.current.Group(ctx, groupID)
+}
+
+func (s *switchableStore) Tagged(ctx context.Context, tag string) ([]StoredReceipt, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return taggedReceipts(ctx, s.current, tag)
+}
+
+func (s *switchableStore) Purchases(ctx context.Context, retailer string, dates []string) ([]StoredReceipt, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return purchases(ctx, s.current, retailer, dates)
+}
+
+func (s *switchableStore) DeleteWhere(ctx context.Context, match func(StoredReceipt) bool) (int, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.current.DeleteWhere(ctx, match)
+}
+
+// Probe passes the readiness check through to the current store.
+func (s *switchableStore) Probe(ctx context.Context) error {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if p, ok := s.current.(prober); ok {
+		return p.Probe(ctx)
+	}
+	return nil
+}
+
+type MigrateResponse struct {
+	Migrated int    `json:"migrated"`
+	DataFile string `json:"dataFile"`
+}
+
+// migrateReceipts moves every receipt to the file configured as the migration
+// target and keeps using that file from then on.
+func (s *server) migrateReceipts(c *gin.Context) {
+	target, err := openFileStore(s.cfg.MigrateTo, s.rulesVersion)
+	if err != nil {
+		storeFailed(c, err)
+		return
+	}
+
+	migrated, err := s.migration.migrate(c.Request.Context(), target)
+	if errors.Is(err, errAlreadyMigrated) || errors.Is(err, errIDTaken) {
+		log.Println(err)
+		c.JSON(http.StatusConflict, errorBody(c, codeMigrationConflict))
+		return
+	}
+	if err != nil {
+		storeFailed(c, err)
+		return
+	}
+
+	log.Printf("Migrated %d receipts to %s\n", migrated, s.cfg.MigrateTo)
+	c.JSON(http.StatusOK, MigrateResponse{Migrated: migrated, DataFile: s.cfg.MigrateTo})
+}
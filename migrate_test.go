@@ -0,0 +1,118 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// fakeTarget is a migration target that records what it imports, or fails.
+type fakeTarget struct {
+	*memoryStore
+	fail     error
+	imported []StoredReceipt
+}
+
+func (f *fakeTarget) Import(ctx context.Context, receipts []StoredReceipt) error {
+	if f.fail != nil {
+		return f.fail
+	}
+	f.imported = append(f.imported, receipts...)
+	return f.memoryStore.Import(ctx, receipts)
+}
+
+func TestMigrate(t *testing.T) {
+	ctx := context.Background()
+	created := time.Date(2022, 1, 1, 13, 0, 0, 0, time.UTC)
+	receipt := Receipt{Retailer: "Target", PurchaseDate: "2022-01-01", PurchaseTime: "13:01", Total: "1.25", Items: []Item{{ShortDescription: "Pepsi", Price: "1.25"}}, Tags: []string{"food"}}
+	seeded := []StoredReceipt{
+		{ID: "a", Receipt: receipt, CreatedAt: created, Version: 1, Breakdown: []award{{Rule: "retailerName", Points: 6, Reason: "Target"}}, Points: 6, Scored: true},
+		{ID: "b", Receipt: receipt, CreatedAt: created.Add(time.Second), Version: 3},
+		{ID: "c", Receipt: receipt, CreatedAt: created.Add(2 * time.Second), Version: 1, Image: smallPNG(t)},
+	}
+
+	tests := []struct {
+		name string
+		fail error
+	}{
+		{"migrated", nil},
+		{"failed copy", errors.New("disk full")},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			source := newMemoryStore()
+			seedStored(t, source, seeded)
+			store := newSwitchableStore(source)
+			target := &fakeTarget{memoryStore: newMemoryStore(), fail: tt.fail}
+
+			migrated, err := store.migrate(ctx, target)
+			if tt.fail != nil {
+				if !errors.Is(err, tt.fail) || store.current != source {
+					t.Fatalf("migrate = %v, switched over %v", err, store.current != source)
+				}
+				return
+			}
+			if err != nil || migrated != len(seeded) {
+				t.Fatalf("migrated %d, %v", migrated, err)
+			}
+
+			// Everything about the receipts is copied.
+			list, _ := store.List(ctx)
+			if !reflect.DeepEqual(list, seeded) || !reflect.DeepEqual(target.imported, seeded) {
+				t.Errorf("listed %+v after migrating\n%+v", list, seeded)
+			}
+			if tagged, _ := store.Tagged(ctx, "food"); len(tagged) != len(seeded) {
+				t.Errorf("%d receipts tagged after migrating", len(tagged))
+			}
+
+			// Changes go to the target from now on.
+			if err := store.Add(ctx, "d", receipt, nil); err != nil {
+				t.Fatal(err)
+			}
+			if _, err := target.Get(ctx, "d"); err != nil {
+				t.Errorf("added receipt isn't in the target: %v", err)
+			}
+			if _, err := source.Get(ctx, "d"); !errors.Is(err, errNotFound) {
+				t.Errorf("added receipt is in the source: %v", err)
+			}
+
+			if _, err := store.migrate(ctx, &fakeTarget{memoryStore: newMemoryStore()}); !errors.Is(err, errAlreadyMigrated) {
+				t.Errorf("migrating again: %v", err)
+			}
+		})
+	}
+}
+
+func TestMigrateReceipts(t *testing.T) {
+	path := t.TempDir() + "/receipts.json"
+	cfg := testConfig(t, "-admin-token", "admin", "-migrate-to", path)
+	migration := newSwitchableStore(newMemoryStore())
+	s := newServer(cfg, migration)
+	s.migration = migration
+	r := s.router()
+	id := process(t, r, example(t, "target-receipt.json"))
+
+	admin := []string{"Authorization", "Bearer admin"}
+	for _, status := range []int{http.StatusOK, http.StatusConflict} {
+		if w := serve(r, http.MethodPost, "/admin/migrate", "", admin...); w.Code != status {
+			t.Errorf("status = %d, want %d: %s", w.Code, status, w.Body)
+		}
+	}
+	if w := serve(r, http.MethodPost, "/admin/migrate", ""); w.Code != http.StatusUnauthorized {
+		t.Errorf("status = %d without the token", w.Code)
+	}
+
+	reopened, err := openFileStore(path, s.rulesVersion)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stored, err := reopened.Get(context.Background(), id); err != nil || !stored.Scored || stored.Points != 28 {
+		t.Errorf("migrated receipt %+v, %v", stored, err)
+	}
+	if points := pointsOf(t, r, id); points.Points != 28 {
+		t.Errorf("points = %d after migrating", points.Points)
+	}
+}
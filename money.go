@@ -0,0 +1,140 @@
+package main
+
+import (
+	"net/http"
+	"slices"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/shopspring/decimal"
+)
+
+// defaultCurrency is assumed for receipts that don't specify a currency.
+const defaultCurrency = "USD"
+
+// currencyMinorUnits maps the supported ISO 4217 currency codes to the number
+// of decimal places their amounts are written with.
+var currencyMinorUnits = map[string]int32{
+	"USD": 2,
+	"EUR": 2,
+	"GBP": 2,
+	"CAD": 2,
+	"JPY": 0,
+}
+
+type CurrencyInfo struct {
+	Code       string `json:"code"`
+	MinorUnits int32  `json:"minorUnits"`
+}
+
+type CurrenciesResponse struct {
+	Currencies []CurrencyInfo `json:"currencies"`
+	// Default is the currency of receipts that don't specify one.
+	Default string `json:"default"`
+}
+
+// getCurrencies lists the supported currencies by code, with the decimal
+// places their amounts are written with.
+func (s *server) getCurrencies(c *gin.Context) {
+	codes := make([]string, 0, len(currencyMinorUnits))
+	for code := range currencyMinorUnits {
+		codes = append(codes, code)
+	}
+	slices.Sort(codes)
+
+	response := CurrenciesResponse{Currencies: make([]CurrencyInfo, 0, len(codes)), Default: defaultCurrency}
+	for _, code := range codes {
+		response.Currencies = append(response.Currencies, CurrencyInfo{Code: code, MinorUnits: currencyMinorUnits[code]})
+	}
+	c.JSON(http.StatusOK, response)
+}
+
+var (
+	quarter     = decimal.RequireFromString("0.25")
+	itemPercent = decimal.RequireFromString("0.2")
+)
+
+// minorUnits returns the decimal places of the currency, or false if it isn't supported.
+// An empty code means the default currency.
+func minorUnits(code string) (int32, bool) {
+	if code == "" {
+		code = defaultCurrency
+	}
+	places, ok := currencyMinorUnits[strings.ToUpper(code)]
+	return places, ok
+}
+
+// isMoney reports whether value is a money amount with exactly the given
+// number of decimal places, e.g. "6.49" for 2 places or "649" for none.
+func isMoney(value string, places int32) bool {
+	whole, fraction, hasPoint := strings.Cut(value, ".")
+	if !isDigits(whole) {
+		return false
+	}
+	if places == 0 {
+		return !hasPoint
+	}
+	return hasPoint && len(fraction) == int(places) && isDigits(fraction)
+}
+
+func isDigits(s string) bool {
+	if s == "" {
+		return false
+	}
+	for i := 0; i < len(s); i++ {
+		if s[i] < '0' || s[i] > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+// formatMoney formats an amount with the currency's decimal places, as "$9.00"
+// for US dollars and e.g. "1200 JPY" for any other currency.
+func formatMoney(amount decimal.Decimal, code string) string {
+	if code == "" {
+		code = defaultCurrency
+	}
+	code = strings.ToUpper(code)
+
+	places, ok := minorUnits(code)
+	if !ok {
+		places = 2
+	}
+	if code == defaultCurrency {
+		return "$" + amount.StringFixed(places)
+	}
+	return amount.StringFixed(places) + " " + code
+}
+
+// Rounding modes for Config.RoundMoney.
+const (
+	roundMoneyNone     = ""
+	roundMoneyNearest  = "nearest"
+	roundMoneyHalfEven = "half-even"
+	roundMoneyDown     = "down"
+)
+
+// roundMoney rounds an amount with more decimal places than the currency has,
+// such as "35.350000001", to those places using the mode. Other values,
+// including those that aren't numbers at all, are returned as is.
+func roundMoney(value string, places int32, mode string) string {
+	amount, err := decimal.NewFromString(value)
+	if err != nil || mode == roundMoneyNone || amount.Exponent() >= -places {
+		return value
+	}
+	switch mode {
+	case roundMoneyNearest:
+		amount = amount.Round(places)
+	case roundMoneyHalfEven:
+		amount = amount.RoundBank(places)
+	case roundMoneyDown:
+		amount = amount.Truncate(places)
+	}
+	return amount.StringFixed(places)
+}
+
+// parseMoney converts a validated money string into an exact decimal value.
+func parseMoney(value string) (decimal.Decimal, error) {
+	return decimal.NewFromString(value)
+}
@@ -0,0 +1,188 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestMoneyIsExact(t *testing.T) {
+	item := func(description, price string) Item { return Item{ShortDescription: description, Price: price} }
+	tests := []struct {
+		name  string
+		total string
+		items []Item
+		// want are the points of each rule the float amounts got wrong.
+		want map[string]int64
+	}{
+		// 9007199254740993.25 is 9007199254740994 as a float64.
+		{"total beyond float precision", "9007199254740993.25", []Item{item("ab", "9007199254740993.25")}, map[string]int64{"roundTotal": 0, "quarterTotal": 25}},
+		// 0.1 + 0.2 is 0.30000000000000004 in floats.
+		{"sum of inexact prices", "0.30", []Item{item("ab", "0.10"), item("cd", "0.20")}, map[string]int64{"roundTotal": 0, "quarterTotal": 0}},
+		{"whole item points", "15.00", []Item{item("abc", "15.00")}, map[string]int64{"itemDescription": 4}},
+		{"large item price", "90071992547409.95", []Item{item("abc", "90071992547409.95")}, map[string]int64{"itemDescription": 18014398509482}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			receipt := Receipt{Retailer: "M", PurchaseDate: "2022-01-02", PurchaseTime: "08:00", Total: tt.total, Items: tt.items}
+			if err := validateItemSum(receipt, 0); err != nil {
+				t.Errorf("validateItemSum: %v", err)
+			}
+			got := awarded(t, receipt, RulesConfig{})
+			for rule, points := range tt.want {
+				if got[rule] != points {
+					t.Errorf("%s = %d, want %d", rule, got[rule], points)
+				}
+			}
+		})
+	}
+}
+
+func TestParseMoney(t *testing.T) {
+	tests := []struct {
+		value string
+		want  string
+		err   bool
+	}{
+		{"6.49", "6.49", false},
+		{"0.10", "0.1", false},
+		{"9007199254740993.25", "9007199254740993.25", false},
+		{"abc", "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.value, func(t *testing.T) {
+			got, err := parseMoney(tt.value)
+			if (err != nil) != tt.err {
+				t.Fatalf("err = %v, want error %v", err, tt.err)
+			}
+			if err == nil && got.String() != tt.want {
+				t.Errorf("parseMoney = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLoggedMoneyPrecision(t *testing.T) {
+	tests := []struct {
+		currency string
+		total    string
+		want     []string
+		// wrong is a precision the currency must not be logged with.
+		wrong string
+	}{
+		{"", "9.00", []string{"total is $9.00, a round value", "item price is $9.00 * 0.2 = $1.80"}, "$9.0000"},
+		{"USD", "9.10", []string{"item price is $9.10 * 0.2 = $1.82"}, "$9.1 "},
+		{"EUR", "9.50", []string{"item price is 9.50 EUR * 0.2 = 1.90 EUR"}, "$"},
+		{"JPY", "1205", []string{"item price is 1205 JPY * 0.2 = 241 JPY"}, "1205.00"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.currency+" "+tt.total, func(t *testing.T) {
+			receipt := `{"retailer": "M", "purchaseDate": "2022-01-02", "purchaseTime": "08:00", "currency": "` + tt.currency + `", "total": "` + tt.total + `", "items": [{"shortDescription": "abc", "price": "` + tt.total + `"}]}`
+			var stdout, stderr bytes.Buffer
+			if code := runScore([]string{"-breakdown", "-"}, strings.NewReader(receipt), &stdout, &stderr); code != 0 {
+				t.Fatalf("exit code %d: %s", code, stderr.String())
+			}
+			for _, want := range tt.want {
+				if !strings.Contains(stdout.String(), want) {
+					t.Errorf("breakdown doesn't contain %q:\n%s", want, stdout.String())
+				}
+			}
+			if strings.Contains(stdout.String(), tt.wrong) {
+				t.Errorf("breakdown contains %q:\n%s", tt.wrong, stdout.String())
+			}
+		})
+	}
+}
+
+func TestRoundMoney(t *testing.T) {
+	tests := []struct {
+		name  string
+		args  []string
+		total string
+		// want is the decoded total, or "" if the receipt is rejected.
+		want string
+		// round is whether the total gets the round dollar points.
+		round bool
+	}{
+		{"strict", nil, "9.000000001", "", false},
+		{"exact in strict mode", nil, "9.00", "9.00", true},
+		{"noise above", []string{"-round-money", "nearest"}, "9.000000001", "9.00", true},
+		{"noise below", []string{"-round-money", "nearest"}, "8.999999999", "9.00", true},
+		{"half up", []string{"-round-money", "nearest"}, "8.995", "9.00", true},
+		{"half even", []string{"-round-money", "half-even"}, "8.985", "8.98", false},
+		{"half even up", []string{"-round-money", "half-even"}, "8.995", "9.00", true},
+		{"down", []string{"-round-money", "down"}, "8.999999999", "8.99", false},
+		{"down keeps exact amounts", []string{"-round-money", "down"}, "9.00", "9.00", true},
+		{"lenient number", []string{"-round-money", "nearest", "-lenient-money"}, "8.999999999", "9.00", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			total := `"` + tt.total + `"`
+			if slices.Contains(tt.args, "-lenient-money") {
+				total = tt.total
+			}
+			body := `{"retailer": "M", "purchaseDate": "2022-01-02", "purchaseTime": "08:00", "total": ` + total + `, "items": [{"shortDescription": "ab", "price": "` + tt.total + `"}]}`
+			receipt, err := decodeReceipt(strings.NewReader(body), testConfig(t, tt.args...))
+			if tt.want == "" {
+				if err == nil {
+					t.Errorf("accepted with total %q", receipt.Total)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if receipt.Total != tt.want || receipt.Items[0].Price != tt.want {
+				t.Errorf("total %q and price %q, want %q", receipt.Total, receipt.Items[0].Price, tt.want)
+			}
+			if round := awarded(t, receipt, RulesConfig{})["roundTotal"] != 0; round != tt.round {
+				t.Errorf("round total %v, want %v", round, tt.round)
+			}
+		})
+	}
+
+	if _, err := parseConfig([]string{"-round-money", "up"}); err == nil {
+		t.Error("-round-money up was accepted")
+	}
+}
+
+func TestGetCurrencies(t *testing.T) {
+	r := newServer(testConfig(t), newMemoryStore()).router()
+	w := serve(r, http.MethodGet, "/currencies", "")
+	var response CurrenciesResponse
+	if err := json.Unmarshal(w.Body.Bytes(), &response); err != nil || w.Code != http.StatusOK {
+		t.Fatalf("%d %s", w.Code, w.Body)
+	}
+	if response.Default != "USD" {
+		t.Errorf("default = %q, want USD", response.Default)
+	}
+	if !slices.IsSortedFunc(response.Currencies, func(a, b CurrencyInfo) int { return strings.Compare(a.Code, b.Code) }) {
+		t.Errorf("currencies aren't sorted by code: %+v", response.Currencies)
+	}
+
+	tests := []struct {
+		code       string
+		minorUnits int32
+	}{
+		{"USD", 2},
+		{"EUR", 2},
+		{"JPY", 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.code, func(t *testing.T) {
+			i := slices.IndexFunc(response.Currencies, func(c CurrencyInfo) bool { return c.Code == tt.code })
+			if i < 0 {
+				t.Fatalf("%s isn't listed: %+v", tt.code, response.Currencies)
+			}
+			if got := response.Currencies[i].MinorUnits; got != tt.minorUnits {
+				t.Errorf("minor units = %d, want %d", got, tt.minorUnits)
+			}
+		})
+	}
+	if len(response.Currencies) != len(currencyMinorUnits) {
+		t.Errorf("%d currencies listed, %d supported", len(response.Currencies), len(currencyMinorUnits))
+	}
+}
@@ -0,0 +1,613 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+// now is the clock of the rules that depend on the current date. It is a
+// variable so the clock can be swapped out.
+var now = time.Now
+
+// award is the points a rule gave a receipt, with the reason for them.
+type award struct {
+	// Rule is the name of the rule that gave the points.
+	Rule   string `json:"rule"`
+	Points int64  `json:"points"`
+	Reason string `json:"reason"`
+	// Detail optionally explains how the points were computed.
+	Detail string `json:"detail,omitempty"`
+	// Item is the index in the receipt's items of the item the points are
+	// for, so items with the same description can be told apart. It is only
+	// set by rules that score items one by one.
+	Item *int `json:"item,omitempty"`
+
+	// fraction is the part of the points left out of Points with fractional
+	// points, for scoreReceipt to round along with the others.
+	fraction decimal.Decimal
+}
+
+// receiptFacts holds the receipt values the rules need, parsed once up front.
+type receiptFacts struct {
+	Receipt
+	total      decimal.Decimal
+	minorUnits int32
+	purchased  time.Time
+
+	// history is what the store knows about the retailer's other receipts.
+	history retailerHistory
+}
+
+// retailerHistory holds the purchase dates, as YYYY-MM-DD, of the other stored
+// receipts from a receipt's retailer, for the rules that depend on them. The
+// maps are nil when the dates aren't known, e.g. when scoring from the command
+// line.
+type retailerHistory struct {
+	// dates are the purchase dates of the other receipts, at least those
+	// from the day before to the day after this one.
+	dates map[string]bool
+	// earlier are the purchase dates of the receipts stored before this one.
+	earlier map[string]bool
+}
+
+// rule is a single scoring rule. apply returns the points the rule awards to the receipt.
+type rule struct {
+	name        string
+	description string
+	// points is the value of a single match, or zero when it depends on the receipt.
+	points int64
+	// enabled reports whether the rule is active; rules without it always are.
+	enabled func(RulesConfig) bool
+	// configured optionally describes the rule under a config that changes
+	// its description and points, reporting whether it does.
+	configured func(RulesConfig) (string, int64, bool)
+	apply      func(facts receiptFacts, rules RulesConfig) ([]award, error)
+}
+
+// scoringRules are evaluated in order by scoreReceipt.
+var scoringRules = []rule{
+	{
+		//One point for every alphanumeric character in the retailer name.
+		name:        "retailerName",
+		description: "One point for every alphanumeric character in the retailer name.",
+		points:      1,
+		apply: func(facts receiptFacts, rules RulesConfig) ([]award, error) {
+			//Count the name as printed on the receipt, not the normalized one.
+			retailer := facts.Retailer
+			if facts.RetailerOriginal != "" {
+				retailer = facts.RetailerOriginal
+			}
+
+			points := int64(0)
+			for _, char := range retailer {
+				if isAlphaNumeric(char) {
+					points++
+				}
+			}
+			return []award{{Points: points, Reason: fmt.Sprintf("the retailer name, \"%s\", has %d characters", retailer, points)}}, nil
+		},
+	},
+	{
+		//50 points if the total is a round dollar amount with no cents.
+		//Currencies without minor units (e.g. JPY) skip this rule, as every total would qualify.
+		name:        "roundTotal",
+		description: "50 points if the total is a round dollar amount with no cents.",
+		points:      50,
+		apply: func(facts receiptFacts, rules RulesConfig) ([]award, error) {
+			if facts.minorUnits == 0 || !facts.total.Equal(facts.total.Truncate(0)) {
+				return nil, nil
+			}
+			return []award{{Points: 50, Reason: fmt.Sprintf("total is %s, a round value", facts.money(facts.total))}}, nil
+		},
+	},
+	{
+		//25 points if the total is a multiple of 0.25.
+		//This is checked independently of the round dollar rule; a total like 9.00 earns both.
+		//Currencies without minor units (e.g. JPY) skip this rule, as every total would qualify.
+		name:        "quarterTotal",
+		description: "25 points if the total is a multiple of 0.25.",
+		points:      25,
+		apply: func(facts receiptFacts, rules RulesConfig) ([]award, error) {
+			if facts.minorUnits == 0 || !facts.total.Mod(quarter).IsZero() {
+				return nil, nil
+			}
+			return []award{{Points: 25, Reason: fmt.Sprintf("the total, %s is a multiple of .25", facts.money(facts.total))}}, nil
+		},
+	},
+	{
+		//5 points for every two items on the receipt
+		name:        "itemPairs",
+		description: "5 points for every two items on the receipt.",
+		points:      5,
+		apply: func(facts receiptFacts, rules RulesConfig) ([]award, error) {
+			numItems := len(facts.Items)
+			pointsToAdd := int64(numItems / 2 * 5)
+			pairs := award{Points: pointsToAdd, Reason: fmt.Sprintf("%d items (5 points for every two items)", numItems)}
+			if rules.MaxItemPairPoints > 0 && pointsToAdd > rules.MaxItemPairPoints {
+				pairs.Points = rules.MaxItemPairPoints
+				pairs.Detail = fmt.Sprintf("%d points are capped at %d", pointsToAdd, rules.MaxItemPairPoints)
+			}
+			return []award{pairs}, nil
+		},
+	},
+	{
+		//If the trimmed length of the item description is a multiple of 3, multiply the price by 0.2 and round up to the nearest integer. The result is the number of points earned.
+		//the phrase "round up to the nearest integer" is unclear.  For example, for the number 2.2, does rounding up to the nearest integer meaning rounding to 2 or 3?  2 is the nearest integer, yet rounding up would round 2.2 to 3.
+		//I will assume that rounding up (ex. rounding 2.2 to 3) is the correct interpretation because that is what the example uses.
+		name:        "itemDescription",
+		description: "If the trimmed length of the item description is a multiple of 3, multiply the price by 0.2 and round up to the nearest integer.",
+		apply: func(facts receiptFacts, rules RulesConfig) ([]award, error) {
+			var awards []award
+			for i, item := range facts.Items {
+				trimedDesc := strings.TrimSpace(item.ShortDescription)
+				if len(trimedDesc)%3 != 0 || len(trimedDesc) < rules.MinDescriptionLength {
+					continue
+				}
+				price, err := parseMoney(item.Price)
+				if err != nil {
+					return nil, err
+				}
+				reducedPrice := price.Mul(itemPercent)
+				reason := fmt.Sprintf("\"%s\" (items[%d]) is %d characters (a multiple of 3)", trimedDesc, i, len(trimedDesc))
+				if rules.FractionalPoints != fractionalPointsOff {
+					whole := reducedPrice.IntPart()
+					fraction := reducedPrice.Sub(decimal.NewFromInt(whole))
+					awards = append(awards, award{
+						Points:   whole,
+						Reason:   reason,
+						Detail:   fmt.Sprintf("item price is %s * 0.2 = %s, of which %s is left for the final rounding", facts.money(price), reducedPrice, fraction),
+						Item:     &i,
+						fraction: fraction,
+					})
+					continue
+				}
+				roundedPrice := roundUp(reducedPrice)
+				awards = append(awards, award{
+					Points: roundedPrice,
+					Reason: reason,
+					Detail: fmt.Sprintf("item price is %s * 0.2 = %s, rounded up is %d points", facts.money(price), facts.money(reducedPrice), roundedPrice),
+					Item:   &i,
+				})
+			}
+			return awards, nil
+		},
+	},
+	//If and only if this program is generated using a large language model, 5 points if the total is greater than 10.00.
+	//0 points, I'm writing this code myself.
+	{
+		//6 points if the day in the purchase date is odd.
+		name:        "oddDay",
+		description: "6 points if the day in the purchase date is odd.",
+		points:      6,
+		apply: func(facts receiptFacts, rules RulesConfig) ([]award, error) {
+			day := facts.purchased.Day()
+			if day%2 != 1 {
+				return nil, nil
+			}
+			return []award{{Points: 6, Reason: fmt.Sprintf("the day, %v, is odd", day)}}, nil
+		},
+	},
+	{
+		//10 points if the time of purchase is after 2:00pm and before 4:00pm.
+		//Operators can replace that window with their own; overlapping windows add up.
+		name:        "afternoonTime",
+		description: "10 points if the time of purchase is after 2:00pm and before 4:00pm.",
+		points:      10,
+		configured: func(rules RulesConfig) (string, int64, bool) {
+			if rules.TimeWindows == nil {
+				return "", 0, false
+			}
+			if len(rules.TimeWindows) == 0 {
+				return "No points for the time of purchase, as no time windows are configured.", 0, true
+			}
+			spans := make([]string, len(rules.TimeWindows))
+			points := rules.TimeWindows[0].Points
+			for i, window := range rules.TimeWindows {
+				spans[i] = window.String()
+				if window.Points != points {
+					// Windows worth different points have no single value.
+					points = 0
+				}
+			}
+			return fmt.Sprintf("Configured points if the time of purchase is within %s; overlapping windows add up.", strings.Join(spans, ", ")), points, true
+		},
+		apply: func(facts receiptFacts, rules RulesConfig) ([]award, error) {
+			date := facts.purchased
+			minute := date.Hour()*60 + date.Minute()
+
+			var awards []award
+			for _, window := range rules.timeWindows() {
+				if !window.contains(minute) {
+					continue
+				}
+				reason := fmt.Sprintf("the time is %d:%d, whitch is between 2pm and 4pm", date.Hour(), date.Minute())
+				if rules.TimeWindows != nil {
+					reason = fmt.Sprintf("the time is %02d:%02d, which is within %s", date.Hour(), date.Minute(), window)
+				}
+				awards = append(awards, award{Points: window.Points, Reason: reason})
+			}
+			return awards, nil
+		},
+	},
+	{
+		//Configured bonus points for promoted retailers.
+		name:        "retailerBonus",
+		description: "Configured bonus points for receipts from promoted retailers.",
+		enabled:     func(rules RulesConfig) bool { return len(rules.RetailerBonuses) > 0 },
+		apply: func(facts receiptFacts, rules RulesConfig) ([]award, error) {
+			bonus := rules.retailerBonus(facts.Retailer)
+			if bonus == 0 {
+				return nil, nil
+			}
+			return []award{{Points: bonus, Reason: fmt.Sprintf("\"%s\" is a promoted retailer", facts.Retailer)}}, nil
+		},
+	},
+	{
+		//Configured bonus points if the retailer also has a receipt from the day before or after.
+		name:        "retailerStreak",
+		description: "Configured bonus points if the same retailer has another receipt from the day before or after.",
+		enabled:     func(rules RulesConfig) bool { return rules.StreakBonus != 0 },
+		apply: func(facts receiptFacts, rules RulesConfig) ([]award, error) {
+			for _, day := range []time.Time{facts.purchased.AddDate(0, 0, -1), facts.purchased.AddDate(0, 0, 1)} {
+				date := day.Format("2006-01-02")
+				if facts.history.dates[date] {
+					return []award{{Points: rules.StreakBonus, Reason: fmt.Sprintf("\"%s\" also has a receipt from %s", facts.Retailer, date)}}, nil
+				}
+			}
+			return nil, nil
+		},
+	},
+	{
+		//Configured bonus points for receipts purchased within the last few days.
+		name:        "recency",
+		description: "Configured bonus points if the purchase date is within the last days, today included.",
+		enabled:     func(rules RulesConfig) bool { return rules.RecencyBonus != 0 },
+		apply: func(facts receiptFacts, rules RulesConfig) ([]award, error) {
+			year, month, day := now().Date()
+			today := time.Date(year, month, day, 0, 0, 0, 0, time.UTC)
+			purchased := time.Date(facts.purchased.Year(), facts.purchased.Month(), facts.purchased.Day(), 0, 0, 0, 0, time.UTC)
+			age := int(today.Sub(purchased).Hours() / 24)
+			if age < 0 || age >= rules.recencyDays() {
+				return nil, nil
+			}
+			return []award{{Points: rules.RecencyBonus, Reason: fmt.Sprintf("%s is %d day(s) ago, within the last %d days", facts.PurchaseDate, age, rules.recencyDays())}}, nil
+		},
+	},
+	{
+		//Configured bonus points for the retailer's first receipt from a day.
+		name:        "firstOfDay",
+		description: "Configured bonus points if no receipt from the same retailer and day was processed before.",
+		enabled:     func(rules RulesConfig) bool { return rules.FirstOfDayBonus != 0 },
+		apply: func(facts receiptFacts, rules RulesConfig) ([]award, error) {
+			if facts.history.earlier == nil || facts.history.earlier[facts.PurchaseDate] {
+				return nil, nil
+			}
+			return []award{{Points: rules.FirstOfDayBonus, Reason: fmt.Sprintf("this is the first receipt from \"%s\" on %s", facts.Retailer, facts.PurchaseDate)}}, nil
+		},
+	},
+	{
+		//Configured points for keywords in the retailer name, e.g. "market".
+		name:        "retailerKeyword",
+		description: "Configured points for every keyword occurring in the retailer name.",
+		enabled:     func(rules RulesConfig) bool { return len(rules.RetailerKeywords) > 0 },
+		apply: func(facts receiptFacts, rules RulesConfig) ([]award, error) {
+			retailer := strings.ToLower(facts.Retailer)
+
+			keywords := make([]string, 0, len(rules.RetailerKeywords))
+			for keyword := range rules.RetailerKeywords {
+				keywords = append(keywords, keyword)
+			}
+			sort.Strings(keywords)
+
+			var awards []award
+			for _, keyword := range keywords {
+				matches := int64(strings.Count(retailer, keyword))
+				if matches == 0 {
+					continue
+				}
+				awards = append(awards, award{
+					Points: matches * rules.RetailerKeywords[keyword],
+					Reason: fmt.Sprintf("the retailer name contains \"%s\" %d time(s)", keyword, matches),
+				})
+			}
+			return awards, nil
+		},
+	},
+	{
+		//Configured bonus points if the retailer name is a palindrome, e.g. "Otto".
+		name:        "retailerPalindrome",
+		description: "Configured bonus points if the retailer name reads the same backwards, ignoring case and non-alphanumeric characters.",
+		enabled:     func(rules RulesConfig) bool { return rules.PalindromeBonus != 0 },
+		apply: func(facts receiptFacts, rules RulesConfig) ([]award, error) {
+			if !isPalindrome(facts.Retailer) {
+				return nil, nil
+			}
+			return []award{{Points: rules.PalindromeBonus, Reason: fmt.Sprintf("the retailer name, \"%s\", is a palindrome", facts.Retailer)}}, nil
+		},
+	},
+	{
+		//Configured bonus points for purchases on a Saturday or Sunday.
+		name:        "weekend",
+		description: "Configured bonus points if the purchase date is a Saturday or Sunday.",
+		enabled:     func(rules RulesConfig) bool { return rules.WeekendBonus != 0 },
+		apply: func(facts receiptFacts, rules RulesConfig) ([]award, error) {
+			weekday := facts.purchased.Weekday()
+			if weekday != time.Saturday && weekday != time.Sunday {
+				return nil, nil
+			}
+			return []award{{Points: rules.WeekendBonus, Reason: fmt.Sprintf("%s is a %s", facts.PurchaseDate, weekday)}}, nil
+		},
+	},
+	{
+		//Configured points for every distinct item description.
+		name:        "distinctItems",
+		description: "Configured points for every distinct item description, compared trimmed and case-insensitively.",
+		enabled:     func(rules RulesConfig) bool { return rules.DistinctItemPoints != 0 },
+		apply: func(facts receiptFacts, rules RulesConfig) ([]award, error) {
+			distinct := make(map[string]bool, len(facts.Items))
+			for _, item := range facts.Items {
+				distinct[strings.ToLower(strings.TrimSpace(item.ShortDescription))] = true
+			}
+			count := int64(len(distinct))
+			return []award{{Points: count * rules.DistinctItemPoints, Reason: fmt.Sprintf("%d distinct items (%d points each)", count, rules.DistinctItemPoints)}}, nil
+		},
+	},
+	{
+		//Configured bonus points if all items cost the same, e.g. a dollar store receipt.
+		name:        "samePrice",
+		description: "Configured bonus points if the receipt has at least two items and all of them have the same price.",
+		enabled:     func(rules RulesConfig) bool { return rules.SamePriceBonus != 0 },
+		apply: func(facts receiptFacts, rules RulesConfig) ([]award, error) {
+			if len(facts.Items) < 2 {
+				return nil, nil
+			}
+			first, err := parseMoney(facts.Items[0].Price)
+			if err != nil {
+				return nil, err
+			}
+			for _, item := range facts.Items[1:] {
+				price, err := parseMoney(item.Price)
+				if err != nil {
+					return nil, err
+				}
+				if !price.Equal(first) {
+					return nil, nil
+				}
+			}
+			return []award{{Points: rules.SamePriceBonus, Reason: fmt.Sprintf("all %d items cost %s", len(facts.Items), facts.money(first))}}, nil
+		},
+	},
+	{
+		//Configured points depending on whether the total's cents are even or odd.
+		//Currencies without minor units (e.g. JPY) skip this rule, as their totals have no cents.
+		name:        "centsParity",
+		description: "Configured points if the total's cents are even, and others if they are odd. Skipped for currencies without cents.",
+		enabled:     func(rules RulesConfig) bool { return rules.EvenCentsPoints != 0 || rules.OddCentsPoints != 0 },
+		apply: func(facts receiptFacts, rules RulesConfig) ([]award, error) {
+			if facts.minorUnits == 0 {
+				return nil, nil
+			}
+			//The cents are the last digits of the total in minor units, so they share its parity.
+			if facts.total.Shift(facts.minorUnits).IntPart()%2 == 0 {
+				return []award{{Points: rules.EvenCentsPoints, Reason: fmt.Sprintf("the total, %s, has an even number of cents", facts.money(facts.total))}}, nil
+			}
+			return []award{{Points: rules.OddCentsPoints, Reason: fmt.Sprintf("the total, %s, has an odd number of cents", facts.money(facts.total))}}, nil
+		},
+	},
+	{
+		//Optionally, one point for every unit of the digits of the total added up, e.g. 16 for 35.35.
+		name:        "digitSum",
+		description: "If enabled, the digits of the total, written with the currency's decimal places, added up.",
+		enabled:     func(rules RulesConfig) bool { return rules.DigitSum },
+		apply: func(facts receiptFacts, rules RulesConfig) ([]award, error) {
+			total := facts.total.StringFixed(facts.minorUnits)
+			sum := digitSum(total)
+			return []award{{Points: sum, Reason: fmt.Sprintf("the digits of the total, %s, add up to %d", facts.money(facts.total), sum)}}, nil
+		},
+	},
+	{
+		//Points from the configured expression rule.
+		name:        "expression",
+		description: "Points computed by the configured expression rule.",
+		enabled:     func(rules RulesConfig) bool { return rules.ExpressionRule != "" },
+		apply: func(facts receiptFacts, rules RulesConfig) ([]award, error) {
+			points, err := evalExpression(rules.ExpressionRule, facts)
+			if err != nil || points == 0 {
+				return nil, err
+			}
+			return []award{{Points: points, Reason: fmt.Sprintf("the expression rule `%s`", rules.ExpressionRule)}}, nil
+		},
+	},
+}
+
+// money formats an amount in the receipt's currency for the breakdown.
+func (f receiptFacts) money(amount decimal.Decimal) string {
+	return formatMoney(amount, f.Currency)
+}
+
+// isEnabled reports whether the rule is active under the given config.
+func (r rule) isEnabled(rules RulesConfig) bool {
+	return r.enabled == nil || r.enabled(rules)
+}
+
+// newReceiptFacts parses the receipt values the rules depend on.
+func newReceiptFacts(receipt Receipt) (receiptFacts, error) {
+	totalPrice, err := parseMoney(receipt.Total)
+	if err != nil {
+		return receiptFacts{}, err
+	}
+	places, _ := minorUnits(receipt.Currency)
+
+	//prepare time and date variables for the date and time rules.
+	layout := "2006-01-02 15:04"
+	value := receipt.PurchaseDate + " " + receipt.PurchaseTime
+	date, _ := time.Parse(layout, value)
+
+	return receiptFacts{Receipt: receipt, total: totalPrice, minorUnits: places, purchased: date}, nil
+}
+
+// scoreReceipt applies the enabled rules to the receipt and returns the
+// total points along with every award that makes them up. history is what is
+// known about the retailer's other receipts.
+func scoreReceipt(receipt Receipt, rules RulesConfig, history retailerHistory) (int64, []award, error) {
+	facts, err := newReceiptFacts(receipt)
+	if err != nil {
+		return 0, nil, err
+	}
+	facts.history = history
+
+	if rules.MinTotal != "" {
+		minTotal, err := parseMoney(rules.MinTotal)
+		if err != nil {
+			return 0, nil, err
+		}
+		if facts.total.LessThan(minTotal) {
+			return 0, []award{{
+				Rule:   "minTotal",
+				Reason: fmt.Sprintf("the total, %s, is below the minimum of %s", facts.money(facts.total), facts.money(minTotal)),
+			}}, nil
+		}
+	}
+
+	points := int64(0)
+	var awards []award
+	for _, r := range scoringRules {
+		if !r.isEnabled(rules) {
+			continue
+		}
+		ruleAwards, err := r.apply(facts, rules)
+		if err != nil {
+			return 0, nil, err
+		}
+		for i := range ruleAwards {
+			ruleAwards[i].Rule = r.name
+			points += ruleAwards[i].Points
+		}
+		awards = append(awards, ruleAwards...)
+	}
+
+	if rules.FractionalPoints != fractionalPointsOff {
+		//The other points are whole, so rounding the fractions left over rounds the total.
+		fractions := decimal.Zero
+		for _, a := range awards {
+			fractions = fractions.Add(a.fraction)
+		}
+		if rounded := roundFraction(fractions, rules.FractionalPoints); rounded != 0 {
+			awards = append(awards, award{
+				Rule:   "fractionRounding",
+				Points: rounded,
+				Reason: fmt.Sprintf("the fractional points add up to %s, rounded %s", fractions, rules.FractionalPoints),
+			})
+			points += rounded
+		}
+	}
+
+	if rules.RoundPoints > 1 {
+		//Like the cap, the rounding shows up in the breakdown as an adjustment.
+		if rounded := roundToNearest(points, rules.RoundPoints); rounded != points {
+			awards = append(awards, award{
+				Rule:   "pointsRounding",
+				Points: rounded - points,
+				Reason: fmt.Sprintf("%d points are rounded to the nearest %d", points, rules.RoundPoints),
+			})
+			points = rounded
+		}
+	}
+
+	if rules.MaxPoints > 0 && points > rules.MaxPoints {
+		//The cap shows up in the breakdown as a deduction, so the awards still add up to the points.
+		awards = append(awards, award{
+			Rule:   "pointsCap",
+			Points: rules.MaxPoints - points,
+			Reason: fmt.Sprintf("%d points are capped at %d", points, rules.MaxPoints),
+		})
+		points = rules.MaxPoints
+	}
+
+	return points, awards, nil
+}
+
+// roundToNearest rounds points to the nearest multiple of n, rounding halves up.
+func roundToNearest(points, n int64) int64 {
+	remainder := points % n
+	if remainder < 0 {
+		remainder += n
+	}
+	down := points - remainder
+	if 2*remainder >= n {
+		return down + n
+	}
+	return down
+}
+
+// printBreakdown writes one line per award followed by the total.
+func printBreakdown(w io.Writer, awards []award, points int64) {
+	for _, a := range awards {
+		fmt.Fprintf(w, "%d points - %s\n", a.Points, a.Reason)
+		if a.Detail != "" {
+			fmt.Fprintf(w, "    %s\n", a.Detail)
+		}
+	}
+	fmt.Fprintf(w, "Total Points: %d\n", points)
+}
+
+func isAlphaNumeric(c rune) bool {
+	return (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') || (c >= '0' && c <= '9')
+}
+
+// digitSum adds up the decimal digits in s, skipping everything else such as
+// the decimal point.
+func digitSum(s string) int64 {
+	sum := int64(0)
+	for _, c := range s {
+		if c >= '0' && c <= '9' {
+			sum += int64(c - '0')
+		}
+	}
+	return sum
+}
+
+// isPalindrome reports whether the alphanumeric characters of name read the
+// same backwards, ignoring case. Names with fewer than two of them aren't
+// palindromes.
+func isPalindrome(name string) bool {
+	var chars []rune
+	for _, char := range strings.ToLower(name) {
+		if isAlphaNumeric(char) {
+			chars = append(chars, char)
+		}
+	}
+	if len(chars) < 2 {
+		return false
+	}
+	for i, j := 0, len(chars)-1; i < j; i, j = i+1, j-1 {
+		if chars[i] != chars[j] {
+			return false
+		}
+	}
+	return true
+}
+
+// roundUp rounds up the way the original implementation did, by dropping the
+// fraction and adding one, so a whole number such as 2.0 also becomes 3.
+// Receipts have always been scored like this, so it isn't a plain ceiling.
+func roundUp(num decimal.Decimal) int64 {
+	return num.IntPart() + 1
+}
+
+// roundFraction rounds fractional points with one of the fractionalPoints modes.
+func roundFraction(num decimal.Decimal, mode string) int64 {
+	switch mode {
+	case fractionalPointsDown:
+		return num.Floor().IntPart()
+	case fractionalPointsNearest:
+		return num.Round(0).IntPart()
+	default:
+		return num.Ceil().IntPart()
+	}
+}
@@ -0,0 +1,741 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+// awarded returns the points of each rule in the breakdown of the receipt.
+func awarded(t *testing.T, receipt Receipt, rules RulesConfig) map[string]int64 {
+	t.Helper()
+	_, awards, err := scoreReceipt(receipt, rules, retailerHistory{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	byRule := make(map[string]int64)
+	for _, a := range awards {
+		byRule[a.Rule] += a.Points
+	}
+	return byRule
+}
+
+func TestTotalRulesByCurrency(t *testing.T) {
+	tests := []struct {
+		currency string
+		total    string
+		round    int64
+		quarter  int64
+	}{
+		{"", "9.00", 50, 25},
+		{"USD", "9.25", 0, 25},
+		{"EUR", "9.10", 0, 0},
+		{"JPY", "1200", 0, 0},
+		{"JPY", "1201", 0, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.currency+" "+tt.total, func(t *testing.T) {
+			receipt := Receipt{
+				Retailer:     "M",
+				PurchaseDate: "2022-01-02",
+				PurchaseTime: "08:00",
+				Currency:     tt.currency,
+				Total:        tt.total,
+				Items:        []Item{{ShortDescription: "ab", Price: tt.total}},
+			}
+			got := awarded(t, receipt, RulesConfig{})
+			if got["roundTotal"] != tt.round || got["quarterTotal"] != tt.quarter {
+				t.Errorf("roundTotal %d, quarterTotal %d, want %d and %d", got["roundTotal"], got["quarterTotal"], tt.round, tt.quarter)
+			}
+		})
+	}
+}
+
+func TestRoundTotalAlsoQuarter(t *testing.T) {
+	tests := []struct {
+		total   string
+		round   int64
+		quarter int64
+	}{
+		{"9.00", 50, 25},
+		{"0.00", 50, 25},
+		{"9.75", 0, 25},
+		{"9.01", 0, 0},
+		{"9.10", 0, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.total, func(t *testing.T) {
+			receipt := Receipt{Retailer: "M", PurchaseDate: "2022-01-02", PurchaseTime: "08:00", Total: tt.total, Items: []Item{{ShortDescription: "ab", Price: tt.total}}}
+			got := awarded(t, receipt, RulesConfig{})
+			if got["roundTotal"] != tt.round || got["quarterTotal"] != tt.quarter {
+				t.Errorf("roundTotal %d, quarterTotal %d, want %d and %d", got["roundTotal"], got["quarterTotal"], tt.round, tt.quarter)
+			}
+			// A round dollar amount is a multiple of 0.25 as well, so both apply.
+			points, _, err := scoreReceipt(receipt, RulesConfig{}, retailerHistory{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if want := 1 + tt.round + tt.quarter; points != want {
+				t.Errorf("points = %d, want %d", points, want)
+			}
+		})
+	}
+}
+
+func TestRetailerBonus(t *testing.T) {
+	rules := testConfig(t, "-retailer-bonus", "Target=10", "-retailer-bonus", "walgreens=3").Rules
+	tests := []struct {
+		retailer string
+		want     int64
+	}{
+		{"Target", 10},
+		{"TARGET", 10},
+		{" target ", 10},
+		{"Walgreens", 3},
+		{"Target Express", 0},
+		{"M&M Corner Market", 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.retailer, func(t *testing.T) {
+			receipt := Receipt{Retailer: tt.retailer, PurchaseDate: "2022-01-02", PurchaseTime: "08:00", Total: "1.01", Items: []Item{{ShortDescription: "ab", Price: "1.01"}}}
+			if got := awarded(t, receipt, rules)["retailerBonus"]; got != tt.want {
+				t.Errorf("retailerBonus = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestTimeWindows(t *testing.T) {
+	overlapping := []string{"-time-window", "11:00-14:00=5", "-time-window", "13:00-15:00=7"}
+	separate := []string{"-time-window", "07:00-09:00=3", "-time-window", "17:00-19:00=4"}
+	tests := []struct {
+		name string
+		args []string
+		time string
+		want int64
+	}{
+		{"default inside", nil, "14:01", 10},
+		{"default at the start", nil, "14:00", 0},
+		{"default at the end", nil, "16:00", 0},
+		{"default outside", nil, "13:59", 0},
+		{"only the first of overlapping", overlapping, "12:00", 5},
+		{"both overlapping", overlapping, "13:30", 12},
+		{"only the second of overlapping", overlapping, "14:30", 7},
+		{"none of overlapping", overlapping, "15:30", 0},
+		{"first of separate", separate, "08:00", 3},
+		{"second of separate", separate, "18:59", 4},
+		{"between separate", separate, "12:00", 0},
+		{"replaced default", separate, "15:00", 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rules := testConfig(t, tt.args...).Rules
+			receipt := Receipt{Retailer: "M", PurchaseDate: "2022-01-02", PurchaseTime: tt.time, Total: "1.01", Items: []Item{{ShortDescription: "ab", Price: "1.01"}}}
+			if got := awarded(t, receipt, rules)["afternoonTime"]; got != tt.want {
+				t.Errorf("afternoonTime = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseTimeWindow(t *testing.T) {
+	tests := []struct {
+		value string
+		want  TimeWindow
+		err   bool
+	}{
+		{"14:00-16:00=10", TimeWindow{Start: 840, End: 960, Points: 10}, false},
+		{" 07:30 - 09:15 = 3", TimeWindow{Start: 450, End: 555, Points: 3}, false},
+		{"14:00-16:00", TimeWindow{}, true},
+		{"14:00=10", TimeWindow{}, true},
+		{"25:00-26:00=1", TimeWindow{}, true},
+		{"14:00-16:00=many", TimeWindow{}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.value, func(t *testing.T) {
+			got, err := parseTimeWindow(tt.value)
+			if (err != nil) != tt.err || got != tt.want {
+				t.Errorf("parseTimeWindow = %+v, %v, want %+v", got, err, tt.want)
+			}
+		})
+	}
+}
+
+func TestRetailerKeywords(t *testing.T) {
+	rules := testConfig(t, "-retailer-keyword", "Mart=5", "-retailer-keyword", "fresh=2").Rules
+	tests := []struct {
+		retailer string
+		want     int64
+	}{
+		{"Walmart", 5},
+		{"FRESH MART", 7},
+		{"Fresh Fresh Market", 4},
+		{"MartMart", 10},
+		{"Target", 0},
+		{"Mar t", 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.retailer, func(t *testing.T) {
+			receipt := Receipt{Retailer: tt.retailer, PurchaseDate: "2022-01-02", PurchaseTime: "08:00", Total: "1.01", Items: []Item{{ShortDescription: "ab", Price: "1.01"}}}
+			if got := awarded(t, receipt, rules)["retailerKeyword"]; got != tt.want {
+				t.Errorf("retailerKeyword = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRetailerStreak(t *testing.T) {
+	receipt := func(retailer, date string) string {
+		return `{"retailer": "` + retailer + `", "purchaseDate": "` + date + `", "purchaseTime": "13:01", "total": "1.25", "items": [{"shortDescription": "Pepsi", "price": "1.25"}]}`
+	}
+	type purchase struct{ retailer, date string }
+	tests := []struct {
+		name   string
+		seeded []purchase
+		// date is the purchase date of the scored Target receipt.
+		date  string
+		bonus int64
+	}{
+		{"no other receipts", nil, "2022-01-02", 0},
+		{"day before", []purchase{{"Target", "2022-01-01"}}, "2022-01-02", 10},
+		{"day after", []purchase{{"Target", "2022-01-03"}}, "2022-01-02", 10},
+		{"both days", []purchase{{"Target", "2022-01-01"}, {"Target", "2022-01-03"}}, "2022-01-02", 10},
+		{"across months", []purchase{{"Target", "2022-01-31"}}, "2022-02-01", 10},
+		{"two days apart", []purchase{{"Target", "2021-12-31"}, {"Target", "2022-01-04"}}, "2022-01-02", 0},
+		{"same day", []purchase{{"Target", "2022-01-02"}}, "2022-01-02", 0},
+		{"other retailer", []purchase{{"Walgreens", "2022-01-01"}}, "2022-01-02", 0},
+		{"retailer in another case", []purchase{{"TARGET", "2022-01-03"}}, "2022-01-02", 10},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			points := map[bool]int64{}
+			for _, enabled := range []bool{false, true} {
+				var args []string
+				if enabled {
+					args = []string{"-streak-bonus", "10"}
+				}
+				r := newServer(testConfig(t, args...), newMemoryStore()).router()
+				for _, p := range tt.seeded {
+					process(t, r, receipt(p.retailer, p.date))
+				}
+				points[enabled] = pointsOf(t, r, process(t, r, receipt("Target", tt.date))).Points
+			}
+			if bonus := points[true] - points[false]; bonus != tt.bonus {
+				t.Errorf("streak bonus %d, want %d", bonus, tt.bonus)
+			}
+		})
+	}
+}
+
+func TestPointsCap(t *testing.T) {
+	var receipt Receipt
+	if err := json.Unmarshal([]byte(example(t, "M&M-receipt.json")), &receipt); err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		max    int64
+		points int64
+		// capped is the pointsCap deduction, 0 if the cap isn't applied.
+		capped int64
+	}{
+		{0, 109, 0},
+		{100, 100, -9},
+		{1, 1, -108},
+		{109, 109, 0},
+		{200, 109, 0},
+	}
+	for _, tt := range tests {
+		t.Run(strconv.FormatInt(tt.max, 10), func(t *testing.T) {
+			points, awards, err := scoreReceipt(receipt, RulesConfig{MaxPoints: tt.max}, retailerHistory{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if points != tt.points {
+				t.Errorf("points = %d, want %d", points, tt.points)
+			}
+			capped := int64(0)
+			for _, a := range awards {
+				if a.Rule == "pointsCap" {
+					capped += a.Points
+				}
+			}
+			if capped != tt.capped {
+				t.Errorf("capped by %d, want %d: %+v", capped, tt.capped, awards)
+			}
+		})
+	}
+
+	for _, value := range []string{"0", "-1", "many"} {
+		if _, err := parseConfig([]string{"-max-points", value}); err == nil {
+			t.Errorf("-max-points %s was accepted", value)
+		}
+	}
+}
+
+func TestRetailerPalindrome(t *testing.T) {
+	rules := testConfig(t, "-palindrome-bonus", "7").Rules
+	tests := []struct {
+		retailer string
+		want     int64
+	}{
+		{"Otto", 7},
+		{"Race car", 7},
+		{"A man, a plan, a canal: Panama", 7},
+		{"12321", 7},
+		{"Target", 0},
+		{"Ottos", 0},
+		{"A", 0},
+		{"&&", 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.retailer, func(t *testing.T) {
+			receipt := Receipt{Retailer: tt.retailer, PurchaseDate: "2022-01-02", PurchaseTime: "08:00", Total: "1.01", Items: []Item{{ShortDescription: "ab", Price: "1.01"}}}
+			if got := awarded(t, receipt, rules)["retailerPalindrome"]; got != tt.want {
+				t.Errorf("retailerPalindrome = %d, want %d", got, tt.want)
+			}
+			if got := awarded(t, receipt, RulesConfig{})["retailerPalindrome"]; got != 0 {
+				t.Errorf("retailerPalindrome = %d while disabled", got)
+			}
+		})
+	}
+}
+
+func TestFirstOfDay(t *testing.T) {
+	receipt := func(retailer, date string) string {
+		return `{"retailer": "` + retailer + `", "purchaseDate": "` + date + `", "purchaseTime": "13:01", "total": "1.25", "items": [{"shortDescription": "Pepsi", "price": "1.25"}]}`
+	}
+	type purchase struct{ retailer, date string }
+	tests := []struct {
+		name   string
+		seeded []purchase
+		bonus  int64
+	}{
+		{"first", nil, 10},
+		{"first of the day", []purchase{{"Target", "2022-01-01"}, {"Target", "2022-01-03"}}, 10},
+		{"subsequent", []purchase{{"Target", "2022-01-02"}}, 0},
+		{"third", []purchase{{"Target", "2022-01-02"}, {"Target", "2022-01-02"}}, 0},
+		{"retailer in another case", []purchase{{"TARGET ", "2022-01-02"}}, 0},
+		{"other retailer", []purchase{{"Walgreens", "2022-01-02"}}, 10},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			points := map[bool]int64{}
+			for _, enabled := range []bool{false, true} {
+				var args []string
+				if enabled {
+					args = []string{"-first-of-day-bonus", "10"}
+				}
+				r := newServer(testConfig(t, args...), newMemoryStore()).router()
+				for _, p := range tt.seeded {
+					process(t, r, receipt(p.retailer, p.date))
+				}
+				points[enabled] = pointsOf(t, r, process(t, r, receipt("Target", "2022-01-02"))).Points
+			}
+			if bonus := points[true] - points[false]; bonus != tt.bonus {
+				t.Errorf("first of day bonus %d, want %d", bonus, tt.bonus)
+			}
+		})
+	}
+}
+
+func TestCentsParity(t *testing.T) {
+	rules := testConfig(t, "-even-cents-points", "4", "-odd-cents-points", "-3").Rules
+	tests := []struct {
+		currency string
+		total    string
+		want     int64
+	}{
+		{"", "1.00", 4},
+		{"", "1.02", 4},
+		{"", "1.01", -3},
+		{"", "35.35", -3},
+		{"", "10.99", -3},
+		{"EUR", "0.10", 4},
+		{"", "0.01", -3},
+		{"", "123456.78", 4},
+		{"", "123456.79", -3},
+		// Whole yen have no cents, so the rule is skipped for them.
+		{"JPY", "1201", 0},
+		{"JPY", "1200", 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.currency+" "+tt.total, func(t *testing.T) {
+			receipt := Receipt{Retailer: "M", PurchaseDate: "2022-01-02", PurchaseTime: "08:00", Currency: tt.currency, Total: tt.total, Items: []Item{{ShortDescription: "ab", Price: tt.total}}}
+			if got := awarded(t, receipt, rules)["centsParity"]; got != tt.want {
+				t.Errorf("centsParity = %d, want %d", got, tt.want)
+			}
+			if got := awarded(t, receipt, RulesConfig{})["centsParity"]; got != 0 {
+				t.Errorf("centsParity = %d while disabled", got)
+			}
+		})
+	}
+}
+
+func TestDistinctItems(t *testing.T) {
+	rules := testConfig(t, "-distinct-item-points", "3").Rules
+	tests := []struct {
+		name         string
+		descriptions []string
+		want         int64
+	}{
+		{"all distinct", []string{"Pepsi", "Doritos", "Gatorade"}, 9},
+		{"duplicates", []string{"Pepsi", "Pepsi", "Doritos"}, 6},
+		{"all the same", []string{"Pepsi", "Pepsi", "Pepsi", "Pepsi"}, 3},
+		{"case and spaces", []string{"Pepsi", " pepsi ", "PEPSI"}, 3},
+		{"inner spaces differ", []string{"Pepsi Max", "Pepsi  Max"}, 6},
+		{"one item", []string{"Pepsi"}, 3},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			receipt := Receipt{Retailer: "M", PurchaseDate: "2022-01-02", PurchaseTime: "08:00", Total: "1.01"}
+			for _, description := range tt.descriptions {
+				receipt.Items = append(receipt.Items, Item{ShortDescription: description, Price: "1.01"})
+			}
+			if got := awarded(t, receipt, rules)["distinctItems"]; got != tt.want {
+				t.Errorf("distinctItems = %d, want %d", got, tt.want)
+			}
+			if got := awarded(t, receipt, RulesConfig{})["distinctItems"]; got != 0 {
+				t.Errorf("distinctItems = %d while disabled", got)
+			}
+		})
+	}
+}
+
+func TestDigitSum(t *testing.T) {
+	rules := testConfig(t, "-digit-sum").Rules
+	tests := []struct {
+		currency string
+		total    string
+		want     int64
+	}{
+		{"", "35.35", 16},
+		{"", "9.00", 9},
+		{"", "0.00", 0},
+		{"", "1234.56", 21},
+		{"", "99.99", 36},
+		{"JPY", "1200", 3},
+		{"JPY", "987", 24},
+	}
+	for _, tt := range tests {
+		t.Run(tt.currency+" "+tt.total, func(t *testing.T) {
+			receipt := Receipt{Retailer: "M", PurchaseDate: "2022-01-02", PurchaseTime: "08:00", Currency: tt.currency, Total: tt.total, Items: []Item{{ShortDescription: "ab", Price: tt.total}}}
+			if got := awarded(t, receipt, rules)["digitSum"]; got != tt.want {
+				t.Errorf("digitSum = %d, want %d", got, tt.want)
+			}
+			if got := awarded(t, receipt, RulesConfig{})["digitSum"]; got != 0 {
+				t.Errorf("digitSum = %d while disabled", got)
+			}
+		})
+	}
+
+	for s, want := range map[string]int64{"35.35": 16, "": 0, "1.2.3": 6, "-4.50": 9} {
+		if got := digitSum(s); got != want {
+			t.Errorf("digitSum(%q) = %d, want %d", s, got, want)
+		}
+	}
+}
+
+func TestPointsRounding(t *testing.T) {
+	tests := []struct {
+		example string
+		n       int64
+		max     int64
+		points  int64
+		// adjusted is the pointsRounding adjustment, 0 if nothing is rounded.
+		adjusted int64
+	}{
+		{"target-receipt.json", 5, 0, 30, 2},
+		{"M&M-receipt.json", 5, 0, 110, 1},
+		{"simple-receipt.json", 5, 0, 30, -1},
+		{"target-receipt.json", 10, 0, 30, 2},
+		{"simple-receipt.json", 1, 0, 31, 0},
+		{"M&M-receipt.json", 5, 100, 100, 1},
+	}
+	for _, tt := range tests {
+		t.Run(fmt.Sprintf("%s %d", tt.example, tt.n), func(t *testing.T) {
+			var receipt Receipt
+			if err := json.Unmarshal([]byte(example(t, tt.example)), &receipt); err != nil {
+				t.Fatal(err)
+			}
+			points, awards, err := scoreReceipt(receipt, RulesConfig{RoundPoints: tt.n, MaxPoints: tt.max}, retailerHistory{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if points != tt.points {
+				t.Errorf("points = %d, want %d", points, tt.points)
+			}
+			adjusted, sum := int64(0), int64(0)
+			for _, a := range awards {
+				if a.Rule == "pointsRounding" {
+					adjusted += a.Points
+				}
+				sum += a.Points
+			}
+			if adjusted != tt.adjusted {
+				t.Errorf("rounded by %d, want %d: %+v", adjusted, tt.adjusted, awards)
+			}
+			if sum != points {
+				t.Errorf("the breakdown adds up to %d, not %d", sum, points)
+			}
+		})
+	}
+
+	for _, tt := range []struct{ points, n, want int64 }{{0, 5, 0}, {2, 5, 0}, {3, 5, 5}, {7, 5, 5}, {-3, 5, -5}, {-2, 5, 0}, {15, 10, 20}} {
+		if got := roundToNearest(tt.points, tt.n); got != tt.want {
+			t.Errorf("roundToNearest(%d, %d) = %d, want %d", tt.points, tt.n, got, tt.want)
+		}
+	}
+	for _, value := range []string{"0", "-5", "many"} {
+		if _, err := parseConfig([]string{"-round-points", value}); err == nil {
+			t.Errorf("-round-points %s was accepted", value)
+		}
+	}
+}
+
+func TestMinDescriptionLength(t *testing.T) {
+	tests := []struct {
+		description string
+		min         string
+		// want is 0 where the rule doesn't apply to the $5.00 item.
+		want int64
+	}{
+		{"abc", "", 2},
+		{"abc", "0", 2},
+		{"abc", "3", 2},
+		{"abc", "4", 0},
+		{"  abc  ", "4", 0},
+		{"abcdef", "4", 2},
+		{"abcdef", "6", 2},
+		{"abcdef", "7", 0},
+		{"abcd", "0", 0},
+	}
+	for _, tt := range tests {
+		t.Run(fmt.Sprintf("%q %s", tt.description, tt.min), func(t *testing.T) {
+			var args []string
+			if tt.min != "" {
+				args = append(args, "-min-description-length", tt.min)
+			}
+			receipt := Receipt{Retailer: "M", PurchaseDate: "2022-01-02", PurchaseTime: "08:00", Total: "5.00", Items: []Item{{ShortDescription: tt.description, Price: "5.00"}}}
+			if got := awarded(t, receipt, testConfig(t, args...).Rules)["itemDescription"]; got != tt.want {
+				t.Errorf("itemDescription = %d, want %d", got, tt.want)
+			}
+		})
+	}
+
+	for _, value := range []string{"-1", "short"} {
+		if _, err := parseConfig([]string{"-min-description-length", value}); err == nil {
+			t.Errorf("-min-description-length %s was accepted", value)
+		}
+	}
+}
+
+func TestWeekendBonus(t *testing.T) {
+	rules := testConfig(t, "-weekend-bonus", "15").Rules
+	tests := []struct {
+		date string
+		want int64
+	}{
+		{"2022-01-01", 15}, // Saturday
+		{"2022-01-02", 15}, // Sunday
+		{"2022-01-03", 0},  // Monday
+		{"2022-01-07", 0},  // Friday
+		{"2024-02-24", 15}, // Saturday
+	}
+	for _, tt := range tests {
+		t.Run(tt.date, func(t *testing.T) {
+			receipt := Receipt{Retailer: "M", PurchaseDate: tt.date, PurchaseTime: "08:00", Total: "1.00", Items: []Item{{ShortDescription: "ab", Price: "1.00"}}}
+			if got := awarded(t, receipt, rules)["weekend"]; got != tt.want {
+				t.Errorf("weekend = %d, want %d", got, tt.want)
+			}
+			if got := awarded(t, receipt, RulesConfig{})["weekend"]; got != 0 {
+				t.Errorf("weekend = %d while disabled", got)
+			}
+		})
+	}
+}
+
+func TestRecencyBonus(t *testing.T) {
+	clock := time.Date(2024, 3, 10, 23, 30, 0, 0, time.UTC)
+	now = func() time.Time { return clock }
+	t.Cleanup(func() { now = time.Now })
+
+	tests := []struct {
+		date string
+		days string
+		want int64
+	}{
+		{"2024-03-10", "", 20},
+		{"2024-03-09", "", 20},
+		{"2024-03-04", "", 20},
+		{"2024-03-03", "", 0},
+		{"2023-03-10", "", 0},
+		{"2024-03-11", "", 0},
+		{"2024-03-10", "1", 20},
+		{"2024-03-09", "1", 0},
+		{"2024-02-10", "30", 20},
+		{"2024-02-09", "30", 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.date+" "+tt.days, func(t *testing.T) {
+			args := []string{"-recency-bonus", "20"}
+			if tt.days != "" {
+				args = append(args, "-recency-days", tt.days)
+			}
+			receipt := Receipt{Retailer: "M", PurchaseDate: tt.date, PurchaseTime: "08:00", Total: "1.00", Items: []Item{{ShortDescription: "ab", Price: "1.00"}}}
+			if got := awarded(t, receipt, testConfig(t, args...).Rules)["recency"]; got != tt.want {
+				t.Errorf("recency = %d, want %d", got, tt.want)
+			}
+			if got := awarded(t, receipt, RulesConfig{})["recency"]; got != 0 {
+				t.Errorf("recency = %d while disabled", got)
+			}
+		})
+	}
+}
+
+func TestMaxItemPairPoints(t *testing.T) {
+	tests := []struct {
+		items int
+		max   string
+		want  int64
+	}{
+		{100, "", 250},
+		{100, "20", 20},
+		{1000, "20", 20},
+		{8, "20", 20},
+		{9, "20", 20},
+		{7, "20", 15},
+		{1, "20", 0},
+	}
+	for _, tt := range tests {
+		t.Run(fmt.Sprintf("%d %s", tt.items, tt.max), func(t *testing.T) {
+			args := []string{"-max-points", "100000"}
+			if tt.max != "" {
+				args = append(args, "-max-item-pair-points", tt.max)
+			}
+			receipt := Receipt{Retailer: "M", PurchaseDate: "2022-01-02", PurchaseTime: "08:00", Total: "1.00"}
+			for range tt.items {
+				receipt.Items = append(receipt.Items, Item{ShortDescription: "ab", Price: "0.01"})
+			}
+			points, awards, err := scoreReceipt(receipt, testConfig(t, args...).Rules, retailerHistory{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			var pairs *award
+			for i := range awards {
+				if awards[i].Rule == "itemPairs" {
+					pairs = &awards[i]
+				}
+			}
+			if pairs == nil || pairs.Points != tt.want {
+				t.Fatalf("itemPairs = %+v, want %d points", pairs, tt.want)
+			}
+			if capped := strings.Contains(pairs.Detail, "capped"); capped != (tt.max != "" && int64(tt.items/2*5) > tt.want) {
+				t.Errorf("detail %q doesn't match the cap", pairs.Detail)
+			}
+			// The retailer name, round total and quarter total add 76 points.
+			if points != 76+tt.want {
+				t.Errorf("points = %d, want %d", points, 76+tt.want)
+			}
+		})
+	}
+
+	for _, value := range []string{"0", "-5", "many"} {
+		if _, err := parseConfig([]string{"-max-item-pair-points", value}); err == nil {
+			t.Errorf("-max-item-pair-points %s was accepted", value)
+		}
+	}
+}
+
+func TestFractionalPoints(t *testing.T) {
+	target := mustDecode(t, example(t, "target-receipt.json"))
+	whole := mustDecode(t, strings.ReplaceAll(receiptWith("10.00", "5.00", "5.00"), `"ab"`, `"abc"`))
+	tests := []struct {
+		name    string
+		receipt Receipt
+		mode    string
+		points  int64
+		// items and rounding are the points of the itemDescription and
+		// fractionRounding entries.
+		items, rounding int64
+	}{
+		// The target receipt's descriptions are worth 2.45 and 2.40 points.
+		{"target", target, "", 28, 6, 0},
+		{"target", target, "up", 27, 4, 1},
+		{"target", target, "nearest", 27, 4, 1},
+		{"target", target, "down", 26, 4, 0},
+		// Each rounds up to 2 points on its own, though worth exactly 1.
+		{"whole", whole, "", 90, 4, 0},
+		{"whole", whole, "up", 88, 2, 0},
+		{"whole", whole, "down", 88, 2, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name+" "+tt.mode, func(t *testing.T) {
+			var args []string
+			if tt.mode != "" {
+				args = append(args, "-fractional-points", tt.mode)
+			}
+			rules := testConfig(t, args...).Rules
+			points, _, err := scoreReceipt(tt.receipt, rules, retailerHistory{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if points != tt.points {
+				t.Errorf("points = %d, want %d", points, tt.points)
+			}
+			byRule := awarded(t, tt.receipt, rules)
+			if byRule["itemDescription"] != tt.items || byRule["fractionRounding"] != tt.rounding {
+				t.Errorf("itemDescription %d and fractionRounding %d, want %d and %d", byRule["itemDescription"], byRule["fractionRounding"], tt.items, tt.rounding)
+			}
+		})
+	}
+
+	for _, tt := range []struct {
+		num  string
+		mode string
+		want int64
+	}{{"0.5", "up", 1}, {"0.5", "nearest", 1}, {"0.49", "nearest", 0}, {"0.99", "down", 0}, {"2", "up", 2}, {"1.01", "up", 2}} {
+		if got := roundFraction(decimal.RequireFromString(tt.num), tt.mode); got != tt.want {
+			t.Errorf("roundFraction(%s, %s) = %d, want %d", tt.num, tt.mode, got, tt.want)
+		}
+	}
+	if _, err := parseConfig([]string{"-fractional-points", "sideways"}); err == nil {
+		t.Error("-fractional-points sideways was accepted")
+	}
+}
+
+func TestSamePrice(t *testing.T) {
+	tests := []struct {
+		name     string
+		currency string
+		prices   []string
+		want     int64
+	}{
+		{"uniform", "", []string{"1.25", "1.25", "1.25"}, 12},
+		{"two uniform", "", []string{"0.99", "0.99"}, 12},
+		{"mixed", "", []string{"1.25", "1.25", "1.26"}, 0},
+		{"mixed first", "", []string{"2.00", "1.00", "1.00"}, 0},
+		{"single item", "", []string{"1.25"}, 0},
+		{"uniform yen", "JPY", []string{"100", "100"}, 12},
+		{"mixed yen", "JPY", []string{"100", "101"}, 0},
+	}
+	rules := testConfig(t, "-same-price-bonus", "12").Rules
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			receipt := Receipt{Retailer: "M", PurchaseDate: "2022-01-02", PurchaseTime: "08:00", Currency: tt.currency, Total: "1"}
+			for _, price := range tt.prices {
+				receipt.Items = append(receipt.Items, Item{ShortDescription: "ab", Price: price})
+			}
+			if got := awarded(t, receipt, rules)["samePrice"]; got != tt.want {
+				t.Errorf("samePrice = %d, want %d", got, tt.want)
+			}
+			if got := awarded(t, receipt, RulesConfig{})["samePrice"]; got != 0 {
+				t.Errorf("samePrice = %d while disabled", got)
+			}
+		})
+	}
+}
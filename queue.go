@@ -0,0 +1,111 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log"
+
+	"github.com/nats-io/nats.go"
+)
+
+// messageQueue is the message broker receipts can be consumed from.
+type messageQueue interface {
+	// Subscribe calls handle with the data of every message published on subject.
+	Subscribe(subject string, handle func(data []byte)) (unsubscribe func() error, err error)
+	Publish(subject string, data []byte) error
+}
+
+// natsQueue is a messageQueue backed by NATS. Subscribers join a queue group,
+// so each message is handled by only one of several running servers.
+type natsQueue struct {
+	conn *nats.Conn
+}
+
+func (q natsQueue) Subscribe(subject string, handle func(data []byte)) (func() error, error) {
+	sub, err := q.conn.QueueSubscribe(subject, serviceName, func(msg *nats.Msg) {
+		handle(msg.Data)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return sub.Unsubscribe, nil
+}
+
+func (q natsQueue) Publish(subject string, data []byte) error {
+	return q.conn.Publish(subject, data)
+}
+
+// QueueResult is published for every receipt consumed from the queue.
+type QueueResult struct {
+	ID     string `json:"id"`
+	Points int64  `json:"points"`
+}
+
+// DeadLetter is published for a message that couldn't be processed.
+type DeadLetter struct {
+	Error   string `json:"error"`
+	Message string `json:"message"`
+}
+
+// queueConsumer processes receipts received from a queue like
+// POST /receipts/process does, and publishes the results.
+type queueConsumer struct {
+	s     *server
+	queue messageQueue
+
+	// results and deadLetter are the subjects results and failed messages go to.
+	results, deadLetter string
+}
+
+// handle validates, stores and scores one receipt message.
+func (q *queueConsumer) handle(data []byte) {
+	ctx := context.Background()
+	if q.s.cfg.RequestTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, q.s.cfg.RequestTimeout)
+		defer cancel()
+	}
+
+	receipt, err := decodeReceipt(bytes.NewReader(data), q.s.cfg)
+	if err == nil {
+		err = q.s.checkReceipt(receipt)
+	}
+	if err == nil {
+		// Queued receipts can't carry a user token.
+		err = q.s.claimReceipt(&receipt, "")
+	}
+	if err != nil {
+		q.fail(data, err)
+		return
+	}
+
+	id, _, err := q.s.ingest(ctx, receipt, "")
+	if err != nil {
+		q.fail(data, err)
+		return
+	}
+	stored, err := q.s.store.Get(ctx, id)
+	if err != nil {
+		q.fail(data, err)
+		return
+	}
+
+	q.publish(q.results, QueueResult{ID: id, Points: q.s.points(ctx, stored)})
+}
+
+// fail sends a message that couldn't be processed to the dead letter subject.
+func (q *queueConsumer) fail(data []byte, err error) {
+	log.Printf("queued receipt rejected: %v\n", err)
+	q.publish(q.deadLetter, DeadLetter{Error: err.Error(), Message: string(data)})
+}
+
+func (q *queueConsumer) publish(subject string, v any) {
+	data, err := json.Marshal(v)
+	if err == nil {
+		err = q.queue.Publish(subject, data)
+	}
+	if err != nil {
+		log.Printf("publishing to %s: %v\n", subject, err)
+	}
+}
@@ -0,0 +1,101 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// fakeQueue is an in-memory messageQueue delivering messages synchronously.
+type fakeQueue struct {
+	mu          sync.Mutex
+	subscribers map[string][]func([]byte)
+	published   map[string][][]byte
+}
+
+func newFakeQueue() *fakeQueue {
+	return &fakeQueue{subscribers: make(map[string][]func([]byte)), published: make(map[string][][]byte)}
+}
+
+func (q *fakeQueue) Subscribe(subject string, handle func(data []byte)) (func() error, error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.subscribers[subject] = append(q.subscribers[subject], handle)
+	return func() error {
+		q.mu.Lock()
+		defer q.mu.Unlock()
+		delete(q.subscribers, subject)
+		return nil
+	}, nil
+}
+
+func (q *fakeQueue) Publish(subject string, data []byte) error {
+	q.mu.Lock()
+	q.published[subject] = append(q.published[subject], data)
+	handlers := q.subscribers[subject]
+	q.mu.Unlock()
+	for _, handle := range handlers {
+		handle(data)
+	}
+	return nil
+}
+
+func TestQueueConsumer(t *testing.T) {
+	tests := []struct {
+		name    string
+		message string
+		// points are those of the published result, or -1 for a dead letter.
+		points int64
+		reason string
+	}{
+		{"target", example(t, "target-receipt.json"), 28, ""},
+		{"M&M", example(t, "M&M-receipt.json"), 109, ""},
+		{"not JSON", "retailer,total", -1, "invalid"},
+		{"invalid receipt", `{"retailer": "Target"}`, -1, "purchaseDate"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store := newMemoryStore()
+			queue := newFakeQueue()
+			consumer := &queueConsumer{s: newServer(testConfig(t), store), queue: queue, results: "results", deadLetter: "dead"}
+			unsubscribe, err := queue.Subscribe("receipts", consumer.handle)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer unsubscribe()
+
+			queue.Publish("receipts", []byte(tt.message))
+
+			stored, _ := store.List(context.Background())
+			if tt.points < 0 {
+				if len(queue.published["dead"]) != 1 || len(queue.published["results"]) != 0 || len(stored) != 0 {
+					t.Fatalf("published %d results and %d dead letters, stored %d receipts", len(queue.published["results"]), len(queue.published["dead"]), len(stored))
+				}
+				var letter DeadLetter
+				if err := json.Unmarshal(queue.published["dead"][0], &letter); err != nil {
+					t.Fatal(err)
+				}
+				if letter.Message != tt.message || !strings.Contains(letter.Error, tt.reason) {
+					t.Errorf("dead letter %+v, want the message and an error about %s", letter, tt.reason)
+				}
+				return
+			}
+
+			if len(queue.published["results"]) != 1 || len(queue.published["dead"]) != 0 {
+				t.Fatalf("published %d results and %d dead letters", len(queue.published["results"]), len(queue.published["dead"]))
+			}
+			var result QueueResult
+			if err := json.Unmarshal(queue.published["results"][0], &result); err != nil {
+				t.Fatal(err)
+			}
+			if result.Points != tt.points {
+				t.Errorf("published %d points, want %d", result.Points, tt.points)
+			}
+			if len(stored) != 1 || stored[0].ID != result.ID {
+				t.Errorf("stored %v, want %s", storedIDs(stored), result.ID)
+			}
+		})
+	}
+}
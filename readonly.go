@@ -0,0 +1,83 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"sync/atomic"
+
+	"github.com/gin-gonic/gin"
+)
+
+// errReadOnly is returned for changes to a readOnlyStore in read-only mode.
+var errReadOnly = errors.New("receipt store is read-only")
+
+// readOnlyStore rejects new, updated and deleted receipts with errReadOnly
+// while read-only mode is on, and passes everything else through. Caching
+// points is still allowed, so reads stay fast.
+type readOnlyStore struct {
+	Store
+	enabled atomic.Bool
+}
+
+func (s *readOnlyStore) Add(ctx context.Context, id string, receipt Receipt, breakdown []award) error {
+	if s.enabled.Load() {
+		return errReadOnly
+	}
+	return s.Store.Add(ctx, id, receipt, breakdown)
+}
+
+func (s *readOnlyStore) Update(ctx context.Context, id string, receipt Receipt, breakdown []award, version int) (StoredReceipt, error) {
+	if s.enabled.Load() {
+		return StoredReceipt{}, errReadOnly
+	}
+	return s.Store.Update(ctx, id, receipt, breakdown, version)
+}
+
+func (s *readOnlyStore) DeleteWhere(ctx context.Context, match func(StoredReceipt) bool) (int, error) {
+	if s.enabled.Load() {
+		return 0, errReadOnly
+	}
+	return s.Store.DeleteWhere(ctx, match)
+}
+
+func (s *readOnlyStore) GetMany(ctx context.Context, ids []string) (map[string]StoredReceipt, error) {
+	return getMany(ctx, s.Store, ids)
+}
+
+func (s *readOnlyStore) Tagged(ctx context.Context, tag string) ([]StoredReceipt, error) {
+	return taggedReceipts(ctx, s.Store, tag)
+}
+
+func (s *readOnlyStore) Purchases(ctx context.Context, retailer string, dates []string) ([]StoredReceipt, error) {
+	return purchases(ctx, s.Store, retailer, dates)
+}
+
+// Probe passes the readiness check through to the wrapped store. Read-only
+// mode doesn't make the server unready, as reads are still served.
+func (s *readOnlyStore) Probe(ctx context.Context) error {
+	if p, ok := s.Store.(prober); ok {
+		return p.Probe(ctx)
+	}
+	return nil
+}
+
+type ReadOnlyRequest struct {
+	ReadOnly *bool `json:"readOnly" binding:"required"`
+}
+
+type ReadOnlyResponse struct {
+	ReadOnly bool `json:"readOnly"`
+}
+
+// setReadOnly turns read-only mode on or off at runtime.
+func (s *server) setReadOnly(c *gin.Context) {
+	var req ReadOnlyRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, errorBody(c, codeReadOnlyInvalid))
+		return
+	}
+
+	s.readOnly.enabled.Store(*req.ReadOnly)
+	c.JSON(http.StatusOK, ReadOnlyResponse{ReadOnly: *req.ReadOnly})
+}
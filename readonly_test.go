@@ -0,0 +1,80 @@
+package main
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestReadOnly(t *testing.T) {
+	receipt := example(t, "target-receipt.json")
+	admin := []string{"Authorization", "Bearer admin"}
+
+	r := newServer(testConfig(t, "-admin-token", "admin"), newMemoryStore()).router()
+	id := process(t, r, receipt)
+
+	requests := []struct {
+		method, path, body string
+		// write is whether read-only mode rejects the request.
+		write bool
+	}{
+		{http.MethodPost, "/receipts/process", receipt, true},
+		{http.MethodPost, "/receipts/batch", "[" + receipt + "]", true},
+		{http.MethodPut, "/receipts/" + id, receipt, true},
+		{http.MethodDelete, "/receipts?all=true", "", true},
+		{http.MethodGet, "/receipts/" + id + "/points", "", false},
+		{http.MethodGet, "/receipts/" + id + "/full", "", false},
+		{http.MethodGet, "/receipts", "", false},
+		{http.MethodGet, "/receipts/export", "", false},
+		{http.MethodPost, "/score", receipt, false},
+	}
+	check := func(t *testing.T, readOnly bool) {
+		t.Helper()
+		for _, req := range requests {
+			if req.method == http.MethodDelete && !readOnly {
+				// Deleting would leave nothing to read.
+				continue
+			}
+			w := serve(r, req.method, req.path, req.body, admin...)
+			// Batches report the rejection per receipt.
+			rejected := strings.Contains(w.Body.String(), codeReadOnly)
+			if rejected != (readOnly && req.write) {
+				t.Errorf("%s %s: status = %d: %.200s", req.method, req.path, w.Code, w.Body)
+			}
+			if !rejected && w.Code >= 300 {
+				t.Errorf("%s %s failed: status = %d: %.200s", req.method, req.path, w.Code, w.Body)
+			}
+		}
+	}
+
+	tests := []struct {
+		name     string
+		body     string
+		headers  []string
+		status   int
+		readOnly bool
+	}{
+		{"switched on", `{"readOnly": true}`, admin, http.StatusOK, true},
+		{"without the token", `{"readOnly": false}`, nil, http.StatusUnauthorized, true},
+		{"without a value", `{}`, admin, http.StatusBadRequest, true},
+		{"switched off", `{"readOnly": false}`, admin, http.StatusOK, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if w := serve(r, http.MethodPut, "/admin/read-only", tt.body, tt.headers...); w.Code != tt.status {
+				t.Fatalf("status = %d, want %d: %s", w.Code, tt.status, w.Body)
+			}
+			check(t, tt.readOnly)
+		})
+	}
+
+	t.Run("flag", func(t *testing.T) {
+		r = newServer(testConfig(t, "-read-only", "-admin-token", "admin"), newMemoryStore()).router()
+		if w := serve(r, http.MethodPost, "/receipts/process", receipt); w.Code != http.StatusServiceUnavailable {
+			t.Errorf("status = %d: %s", w.Code, w.Body)
+		}
+		if w := serve(r, http.MethodGet, "/receipts", ""); w.Code != http.StatusOK {
+			t.Errorf("status = %d: %s", w.Code, w.Body)
+		}
+	})
+}
@@ -0,0 +1,308 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// RulesConfig holds the configurable parts of the scoring rules in points.go.
+// The zero value scores receipts exactly as described in the README.
+type RulesConfig struct {
+	// RetailerBonuses maps a lowercased retailer name to extra points
+	// awarded to every receipt from that retailer.
+	RetailerBonuses map[string]int64 `json:"retailerBonuses,omitempty"`
+
+	// ExpressionRule is an optional expression, e.g. `total > 50 ? 20 : 0`,
+	// whose result is added to the points. See expressionEnv for its inputs.
+	ExpressionRule string `json:"expressionRule,omitempty"`
+
+	// StreakBonus is awarded when the retailer has another receipt from the
+	// day before or after. Zero disables the rule.
+	StreakBonus int64 `json:"streakBonus,omitempty"`
+
+	// RecencyBonus is awarded when the purchase date is one of the last
+	// RecencyDays days, today included, when the receipt is scored. Zero
+	// disables the rule; zero days means defaultRecencyDays.
+	RecencyBonus int64 `json:"recencyBonus,omitempty"`
+	RecencyDays  int   `json:"recencyDays,omitempty"`
+
+	// FirstOfDayBonus is awarded to the first receipt processed from a
+	// retailer for a purchase date. Zero disables the rule.
+	FirstOfDayBonus int64 `json:"firstOfDayBonus,omitempty"`
+
+	// DistinctItemPoints is awarded for every distinct item description.
+	// Zero disables the rule.
+	DistinctItemPoints int64 `json:"distinctItemPoints,omitempty"`
+
+	// SamePriceBonus is awarded when a receipt has at least two items, all
+	// with the same price. Zero disables the rule.
+	SamePriceBonus int64 `json:"samePriceBonus,omitempty"`
+
+	// EvenCentsPoints and OddCentsPoints are added to receipts whose total
+	// has an even or odd number of cents. They may be negative; both zero
+	// disables the rule.
+	EvenCentsPoints int64 `json:"evenCentsPoints,omitempty"`
+	OddCentsPoints  int64 `json:"oddCentsPoints,omitempty"`
+
+	// DigitSum awards the digits of the total added up as points.
+	DigitSum bool `json:"digitSum,omitempty"`
+
+	// PalindromeBonus is awarded when the retailer name reads the same
+	// backwards, see isPalindrome. Zero disables the rule.
+	PalindromeBonus int64 `json:"palindromeBonus,omitempty"`
+
+	// WeekendBonus is awarded when the purchase date is a Saturday or
+	// Sunday. Zero disables the rule.
+	WeekendBonus int64 `json:"weekendBonus,omitempty"`
+
+	// RetailerKeywords maps lowercased keywords to the points awarded for
+	// every time one occurs in a retailer name.
+	RetailerKeywords map[string]int64 `json:"retailerKeywords,omitempty"`
+
+	// TimeWindows award points for purchases within them. Nil means
+	// defaultTimeWindows, the specification's 2:00pm to 4:00pm rule.
+	TimeWindows []TimeWindow `json:"timeWindows,omitempty"`
+
+	// FractionalPoints keeps the fractions of the item description rule's
+	// points instead of rounding each one up, and rounds them once all rules
+	// are applied: fractionalPointsUp, fractionalPointsNearest or
+	// fractionalPointsDown. Empty rounds every item's points up.
+	FractionalPoints string `json:"fractionalPoints,omitempty"`
+
+	// RoundPoints rounds the points of a receipt to the nearest multiple of
+	// it, before the cap. Zero leaves them as they are.
+	RoundPoints int64 `json:"roundPoints,omitempty"`
+
+	// MaxItemPairPoints caps the points of the 5 points for every two items
+	// rule. Zero means no cap.
+	MaxItemPairPoints int64 `json:"maxItemPairPoints,omitempty"`
+
+	// MinDescriptionLength is the trimmed length an item description needs
+	// before the multiple of 3 rule applies to it. Zero applies it to all.
+	MinDescriptionLength int `json:"minDescriptionLength,omitempty"`
+
+	// MaxPoints caps the points of a single receipt. Zero means no cap.
+	MaxPoints int64 `json:"maxPoints,omitempty"`
+
+	// MinTotal is an amount such as "5.00"; receipts with a smaller total
+	// score zero points. Empty scores every receipt.
+	MinTotal string `json:"minTotal,omitempty"`
+}
+
+// TimeWindow awards Points to purchases made after Start and before End, both
+// minutes after midnight.
+type TimeWindow struct {
+	Start  int   `json:"start"`
+	End    int   `json:"end"`
+	Points int64 `json:"points"`
+}
+
+// defaultTimeWindows is the 10 point window between 2:00pm and 4:00pm.
+var defaultTimeWindows = []TimeWindow{{Start: 14 * 60, End: 16 * 60, Points: 10}}
+
+// Final rounding modes for RulesConfig.FractionalPoints.
+const (
+	fractionalPointsOff     = ""
+	fractionalPointsUp      = "up"
+	fractionalPointsNearest = "nearest"
+	fractionalPointsDown    = "down"
+)
+
+// defaultRecencyDays is the week the recency bonus is awarded in by default.
+const defaultRecencyDays = 7
+
+// recencyDays returns the configured number of days of the recency rule, or
+// the default.
+func (rules RulesConfig) recencyDays() int {
+	if rules.RecencyDays == 0 {
+		return defaultRecencyDays
+	}
+	return rules.RecencyDays
+}
+
+// timeWindows returns the configured time windows, or the default ones.
+func (rules RulesConfig) timeWindows() []TimeWindow {
+	if rules.TimeWindows == nil {
+		return defaultTimeWindows
+	}
+	return rules.TimeWindows
+}
+
+// contains reports whether the minute of the day is strictly inside the window.
+func (w TimeWindow) contains(minute int) bool {
+	return minute > w.Start && minute < w.End
+}
+
+func (w TimeWindow) String() string {
+	return fmt.Sprintf("%02d:%02d-%02d:%02d", w.Start/60, w.Start%60, w.End/60, w.End%60)
+}
+
+// parseTimeWindow parses a "14:00-16:00=10" flag value.
+func parseTimeWindow(value string) (TimeWindow, error) {
+	span, pointsStr, ok := strings.Cut(value, "=")
+	startStr, endStr, hasEnd := strings.Cut(span, "-")
+	if !ok || !hasEnd {
+		return TimeWindow{}, fmt.Errorf("expected start-end=points such as 14:00-16:00=10, got %q", value)
+	}
+
+	var minutes [2]int
+	for i, clock := range []string{startStr, endStr} {
+		t, err := time.Parse("15:04", strings.TrimSpace(clock))
+		if err != nil {
+			return TimeWindow{}, fmt.Errorf("invalid time %q in window %q", clock, value)
+		}
+		minutes[i] = t.Hour()*60 + t.Minute()
+	}
+	if minutes[0] >= minutes[1] {
+		return TimeWindow{}, fmt.Errorf("window %q must start before it ends", value)
+	}
+
+	points, err := strconv.ParseInt(strings.TrimSpace(pointsStr), 10, 64)
+	if err != nil {
+		return TimeWindow{}, fmt.Errorf("invalid points in window %q: %w", value, err)
+	}
+	return TimeWindow{Start: minutes[0], End: minutes[1], Points: points}, nil
+}
+
+// RuleInfo describes a scoring rule to clients.
+type RuleInfo struct {
+	Name        string `json:"name"`
+	Description string `json:"description"`
+	// Points is the value of a single match, omitted when it depends on the receipt.
+	Points  int64 `json:"points,omitempty"`
+	Enabled bool  `json:"enabled"`
+}
+
+type RulesResponse struct {
+	Rules            []RuleInfo `json:"rules"`
+	RulesVersion     string     `json:"rulesVersion"`
+	BreakdownVersion int        `json:"breakdownVersion"`
+}
+
+// describe lists every scoring rule and whether it is active under this config.
+func (rules RulesConfig) describe() []RuleInfo {
+	infos := make([]RuleInfo, 0, len(scoringRules))
+	for _, r := range scoringRules {
+		description, points := r.description, r.points
+		if r.configured != nil {
+			if d, p, ok := r.configured(rules); ok {
+				description, points = d, p
+			}
+		}
+		infos = append(infos, RuleInfo{
+			Name:        r.name,
+			Description: description,
+			Points:      points,
+			Enabled:     r.isEnabled(rules),
+		})
+	}
+	return infos
+}
+
+// getRules returns the scoring rules of the server.
+func (s *server) getRules(c *gin.Context) {
+	c.JSON(http.StatusOK, RulesResponse{Rules: s.cfg.Rules.describe(), RulesVersion: s.rulesVersion, BreakdownVersion: breakdownVersion})
+}
+
+// version returns a short hash identifying the rules config, so clients can
+// tell when identical receipts may start scoring differently.
+func (rules RulesConfig) version() string {
+	// Maps are marshalled with sorted keys, so equal configs hash the same.
+	data, err := json.Marshal(rules)
+	if err != nil {
+		panic(err)
+	}
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:6])
+}
+
+// retailerBonus returns the bonus points configured for the retailer, matched case-insensitively.
+func (rules RulesConfig) retailerBonus(retailer string) int64 {
+	return rules.RetailerBonuses[strings.ToLower(strings.TrimSpace(retailer))]
+}
+
+// validate checks a rules config that didn't come from the command line flags,
+// lowercasing the retailer names and keywords like the flags do.
+func (rules *RulesConfig) validate() error {
+	rules.RetailerBonuses = lowercaseKeys(rules.RetailerBonuses)
+	rules.RetailerKeywords = lowercaseKeys(rules.RetailerKeywords)
+	for keyword := range rules.RetailerKeywords {
+		if keyword == "" {
+			return fmt.Errorf("retailer keywords must not be empty")
+		}
+	}
+
+	if rules.ExpressionRule != "" {
+		if _, err := compileExpression(rules.ExpressionRule); err != nil {
+			return err
+		}
+	}
+
+	if rules.MaxPoints < 0 {
+		return fmt.Errorf("the points cap must not be negative")
+	}
+	if rules.RoundPoints < 0 {
+		return fmt.Errorf("the points rounding must not be negative")
+	}
+	switch rules.FractionalPoints {
+	case fractionalPointsOff, fractionalPointsUp, fractionalPointsNearest, fractionalPointsDown:
+	default:
+		return fmt.Errorf("unknown fractional points rounding %q, expected up, nearest or down", rules.FractionalPoints)
+	}
+	if rules.MaxItemPairPoints < 0 {
+		return fmt.Errorf("the item pairs cap must not be negative")
+	}
+	if rules.RecencyDays < 0 {
+		return fmt.Errorf("the recency days must not be negative")
+	}
+	if rules.MinDescriptionLength < 0 {
+		return fmt.Errorf("the minimum description length must not be negative")
+	}
+
+	if rules.MinTotal != "" {
+		if _, err := parseMoney(rules.MinTotal); err != nil {
+			return fmt.Errorf("invalid minimum total %q", rules.MinTotal)
+		}
+	}
+
+	for _, w := range rules.TimeWindows {
+		if w.Start < 0 || w.End > 24*60 || w.Start >= w.End {
+			return fmt.Errorf("time window %s must start before it ends, within a day", w)
+		}
+	}
+	return nil
+}
+
+func lowercaseKeys(points map[string]int64) map[string]int64 {
+	lowered := make(map[string]int64, len(points))
+	for name, n := range points {
+		lowered[strings.ToLower(strings.TrimSpace(name))] = n
+	}
+	return lowered
+}
+
+// parseNamedPoints parses a "name=points" flag value, such as a retailer bonus
+// "Retailer Name=10", into a map keyed by the lowercased name.
+func parseNamedPoints(points map[string]int64, value string) error {
+	name, pointsStr, ok := strings.Cut(value, "=")
+	name = strings.TrimSpace(name)
+	if !ok || name == "" {
+		return fmt.Errorf("expected name=points, got %q", value)
+	}
+
+	n, err := strconv.ParseInt(strings.TrimSpace(pointsStr), 10, 64)
+	if err != nil {
+		return fmt.Errorf("invalid points for %q: %w", name, err)
+	}
+
+	points[strings.ToLower(name)] = n
+	return nil
+}
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+)
+
+func TestGetRules(t *testing.T) {
+	tests := []struct {
+		name string
+		args []string
+		// want are the expected afternoonTime description and points.
+		description string
+		points      int64
+	}{
+		{"default", nil, "10 points if the time of purchase is after 2:00pm and before 4:00pm.", 10},
+		{"one window", []string{"-time-window", "09:00-11:00=15"}, "Configured points if the time of purchase is within 09:00-11:00; overlapping windows add up.", 15},
+		{"equal windows", []string{"-time-window", "09:00-11:00=5", "-time-window", "18:00-19:30=5"}, "Configured points if the time of purchase is within 09:00-11:00, 18:00-19:30; overlapping windows add up.", 5},
+		{"different windows", []string{"-time-window", "09:00-11:00=5", "-time-window", "14:00-16:00=10"}, "Configured points if the time of purchase is within 09:00-11:00, 14:00-16:00; overlapping windows add up.", 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := newServer(testConfig(t, tt.args...), newMemoryStore()).router()
+			w := serve(r, http.MethodGet, "/rules", "")
+			var response RulesResponse
+			if err := json.Unmarshal(w.Body.Bytes(), &response); err != nil {
+				t.Fatal(err)
+			}
+
+			described := map[string]RuleInfo{}
+			for _, info := range response.Rules {
+				described[info.Name] = info
+			}
+			for _, rule := range scoringRules {
+				if _, ok := described[rule.name]; !ok {
+					t.Errorf("rule %s is missing", rule.name)
+				}
+			}
+			if afternoon := described["afternoonTime"]; afternoon.Description != tt.description || afternoon.Points != tt.points || !afternoon.Enabled {
+				t.Errorf("afternoonTime = %+v, want %q worth %d", afternoon, tt.description, tt.points)
+			}
+		})
+	}
+}
+
+func TestRulesVersion(t *testing.T) {
+	receipt := example(t, "target-receipt.json")
+	version := func(args ...string) string {
+		t.Helper()
+		r := newServer(testConfig(t, args...), newMemoryStore()).router()
+		id := process(t, r, receipt)
+		var points PointsResponse
+		json.Unmarshal(serve(r, http.MethodGet, "/receipts/"+id+"/points", "").Body.Bytes(), &points)
+		if points.RulesVersion == "" {
+			t.Fatalf("no rules version with %v", args)
+		}
+		return points.RulesVersion
+	}
+
+	base := version()
+	if again := version(); again != base {
+		t.Errorf("the same rules are versions %s and %s", base, again)
+	}
+	tests := [][]string{
+		{"-retailer-bonus", "target=5"},
+		{"-retailer-bonus", "target=6"},
+		{"-time-window", "14:00-16:00=10"},
+		{"-max-points", "100"},
+		{"-weekend-bonus", "5"},
+	}
+	seen := map[string][]string{base: nil}
+	for _, args := range tests {
+		v := version(args...)
+		if other, ok := seen[v]; ok {
+			t.Errorf("%v has the same version %s as %v", args, v, other)
+		}
+		seen[v] = args
+	}
+}
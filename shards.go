@@ -0,0 +1,162 @@
+package main
+
+import (
+	"context"
+	"hash/fnv"
+)
+
+// shardedStore spreads the receipts over several memoryStores by a hash of
+// their ID, so writes to different shards don't contend for one lock.
+// Operations spanning all receipts lock every shard, in order, to see and
+// change a consistent state.
+type shardedStore struct {
+	shards []*memoryStore
+}
+
+func newShardedStore(n int) *shardedStore {
+	s := &shardedStore{shards: make([]*memoryStore, n)}
+	for i := range s.shards {
+		s.shards[i] = newMemoryStore()
+	}
+	return s
+}
+
+// shard returns the shard the receipt with the given ID belongs to.
+func (s *shardedStore) shard(id string) *memoryStore {
+	h := fnv.New32a()
+	h.Write([]byte(id))
+	return s.shards[h.Sum32()%uint32(len(s.shards))]
+}
+
+func (s *shardedStore) Add(ctx context.Context, id string, receipt Receipt, breakdown []award) error {
+	return s.shard(id).Add(ctx, id, receipt, breakdown)
+}
+
+func (s *shardedStore) Get(ctx context.Context, id string) (StoredReceipt, error) {
+	return s.shard(id).Get(ctx, id)
+}
+
+// GetMany locks every shard once for the whole lookup, rather than a shard
+// for every ID.
+func (s *shardedStore) GetMany(ctx context.Context, ids []string) (map[string]StoredReceipt, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	s.rlockAll()
+	defer s.runlockAll()
+
+	found := make(map[string]StoredReceipt, len(ids))
+	for _, id := range ids {
+		if stored, exists := s.shard(id).receipts[id]; exists {
+			found[id] = stored
+		}
+	}
+	return found, nil
+}
+
+func (s *shardedStore) Update(ctx context.Context, id string, receipt Receipt, breakdown []award, version int) (StoredReceipt, error) {
+	return s.shard(id).Update(ctx, id, receipt, breakdown, version)
+}
+
+func (s *shardedStore) SetPoints(ctx context.Context, id string, points int64) error {
+	return s.shard(id).SetPoints(ctx, id, points)
+}
+
+func (s *shardedStore) List(ctx context.Context) ([]StoredReceipt, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	s.rlockAll()
+	defer s.runlockAll()
+
+	var list []StoredReceipt
+	for _, shard := range s.shards {
+		list = shard.appendAll(list)
+	}
+	sortStored(list)
+	return list, nil
+}
+
+func (s *shardedStore) Group(ctx context.Context, groupID string) ([]StoredReceipt, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	s.rlockAll()
+	defer s.runlockAll()
+
+	group := []StoredReceipt{}
+	for _, shard := range s.shards {
+		group = shard.appendGroup(group, groupID)
+	}
+	sortStored(group)
+	return group, nil
+}
+
+func (s *shardedStore) Tagged(ctx context.Context, tag string) ([]StoredReceipt, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	s.rlockAll()
+	defer s.runlockAll()
+
+	key := tagKey(tag)
+	list := []StoredReceipt{}
+	for _, shard := range s.shards {
+		list = shard.appendTagged(list, key)
+	}
+	sortStored(list)
+	return list, nil
+}
+
+func (s *shardedStore) Purchases(ctx context.Context, retailer string, dates []string) ([]StoredReceipt, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	s.rlockAll()
+	defer s.runlockAll()
+
+	list := []StoredReceipt{}
+	for _, shard := range s.shards {
+		list = shard.appendPurchases(list, retailer, dates)
+	}
+	sortStored(list)
+	return list, nil
+}
+
+func (s *shardedStore) DeleteWhere(ctx context.Context, match func(StoredReceipt) bool) (int, error) {
+	if err := ctx.Err(); err != nil {
+		return 0, err
+	}
+
+	for _, shard := range s.shards {
+		shard.mu.Lock()
+	}
+	defer func() {
+		for _, shard := range s.shards {
+			shard.mu.Unlock()
+		}
+	}()
+
+	deleted := 0
+	for _, shard := range s.shards {
+		deleted += shard.deleteMatching(match)
+	}
+	return deleted, nil
+}
+
+func (s *shardedStore) rlockAll() {
+	for _, shard := range s.shards {
+		shard.mu.RLock()
+	}
+}
+
+func (s *shardedStore) runlockAll() {
+	for _, shard := range s.shards {
+		shard.mu.RUnlock()
+	}
+}
@@ -0,0 +1,204 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"slices"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+type ScoreResponse struct {
+	Points       int64  `json:"points"`
+	RulesVersion string `json:"rulesVersion"`
+	// Breakdown and BreakdownVersion are only included with ?verbose=2.
+	Breakdown        []award `json:"breakdown,omitempty"`
+	BreakdownVersion int     `json:"breakdownVersion,omitempty"`
+}
+
+// scoreInline scores a receipt exactly as processing it would, without
+// storing it. With ?verbose=2 the response includes the breakdown. In dev mode
+// the X-Experimental-Rules header can switch on more rules.
+func (s *server) scoreInline(c *gin.Context) {
+	receipt, err := bindReceipt(c, s.cfg)
+	if err == nil {
+		err = s.checkReceipt(receipt)
+	}
+	if err != nil {
+		s.receiptRejected(c, err)
+		return
+	}
+
+	rules, experimental, ok := s.requestRules(c)
+	if !ok {
+		return
+	}
+
+	points, awards, err := scoreReceipt(receipt, rules, s.retailerHistory(c.Request.Context(), rules, StoredReceipt{Receipt: receipt}))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, errorBody(c, codeReceiptInvalid))
+		return
+	}
+
+	response := ScoreResponse{Points: points, RulesVersion: s.rulesVersion}
+	if experimental {
+		response.RulesVersion = rules.version()
+	}
+	if verbose, _ := strconv.Atoi(c.Query("verbose")); verbose >= 2 {
+		response.Breakdown, response.BreakdownVersion = awards, breakdownVersion
+	}
+	c.JSON(http.StatusOK, response)
+}
+
+// SimulateRequest is a receipt to score under a hypothetical rules config.
+// The receipt is decoded like a processed one, see decodeEnvelope.
+type SimulateRequest struct {
+	Receipt     json.RawMessage `json:"receipt"`
+	RulesConfig RulesConfig     `json:"rulesConfig"`
+}
+
+// simulateScore scores a receipt under the rules config sent with it. Neither
+// the receipt nor the config is stored, and the server's rules are unaffected.
+func (s *server) simulateScore(c *gin.Context) {
+	var req SimulateRequest
+	if err := decodeEnvelope(c.Request.Body, s.cfg, &req); err != nil {
+		s.receiptRejected(c, err)
+		return
+	}
+	if err := req.RulesConfig.validate(); err != nil {
+		body := errorBody(c, codeRulesInvalid)
+		body["reason"] = err.Error()
+		c.JSON(http.StatusBadRequest, body)
+		return
+	}
+	receipt, err := s.decodeWrapped("receipt", req.Receipt)
+	if err != nil {
+		s.receiptRejected(c, err)
+		return
+	}
+
+	points, _, err := scoreReceipt(receipt, req.RulesConfig, s.retailerHistory(c.Request.Context(), req.RulesConfig, StoredReceipt{Receipt: receipt}))
+	if err != nil {
+		body := errorBody(c, codeRulesInvalid)
+		body["reason"] = err.Error()
+		c.JSON(http.StatusBadRequest, body)
+		return
+	}
+
+	c.JSON(http.StatusOK, PointsResponse{Points: points, RulesVersion: req.RulesConfig.version()})
+}
+
+// decodeWrapped decodes and checks the receipt sent in the given field of a
+// request like processReceipt does with a receipt sent on its own.
+func (s *server) decodeWrapped(field string, data json.RawMessage) (Receipt, error) {
+	if len(data) == 0 {
+		return Receipt{}, &fieldError{Field: field, Reason: "a receipt is required"}
+	}
+	receipt, err := decodeReceipt(bytes.NewReader(data), s.cfg)
+	if err == nil {
+		err = s.checkReceipt(receipt)
+	}
+	return receipt, err
+}
+
+// CompareRequest holds the two receipts to compare, decoded like processed
+// ones, see decodeEnvelope.
+type CompareRequest struct {
+	A json.RawMessage `json:"a"`
+	B json.RawMessage `json:"b"`
+}
+
+type ScoredReceipt struct {
+	Points    int64   `json:"points"`
+	Breakdown []award `json:"breakdown"`
+}
+
+// RuleDifference is a rule that gave the two receipts different points.
+type RuleDifference struct {
+	Rule    string `json:"rule"`
+	PointsA int64  `json:"pointsA"`
+	PointsB int64  `json:"pointsB"`
+}
+
+type CompareResponse struct {
+	A ScoredReceipt `json:"a"`
+	B ScoredReceipt `json:"b"`
+	// Delta is B's points minus A's.
+	Delta            int64            `json:"delta"`
+	DifferingRules   []RuleDifference `json:"differingRules"`
+	BreakdownVersion int              `json:"breakdownVersion"`
+}
+
+// compareScores scores two receipts under the server's rules and reports how
+// their points differ, rule by rule. Nothing is stored.
+func (s *server) compareScores(c *gin.Context) {
+	var req CompareRequest
+	if err := decodeEnvelope(c.Request.Body, s.cfg, &req); err != nil {
+		s.receiptRejected(c, err)
+		return
+	}
+
+	var scored [2]ScoredReceipt
+	parts := []struct {
+		field string
+		data  json.RawMessage
+	}{{"a", req.A}, {"b", req.B}}
+	for i, part := range parts {
+		receipt, err := s.decodeWrapped(part.field, part.data)
+		if err != nil {
+			s.receiptRejected(c, err)
+			return
+		}
+
+		points, awards, err := scoreReceipt(receipt, s.cfg.Rules, s.retailerHistory(c.Request.Context(), s.cfg.Rules, StoredReceipt{Receipt: receipt}))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, errorBody(c, codeReceiptInvalid))
+			return
+		}
+		if awards == nil {
+			awards = []award{}
+		}
+		scored[i] = ScoredReceipt{Points: points, Breakdown: awards}
+	}
+
+	c.JSON(http.StatusOK, CompareResponse{
+		A:                scored[0],
+		B:                scored[1],
+		Delta:            scored[1].Points - scored[0].Points,
+		DifferingRules:   differingRules(scored[0].Breakdown, scored[1].Breakdown),
+		BreakdownVersion: breakdownVersion,
+	})
+}
+
+// differingRules lists the rules whose points differ between two breakdowns,
+// in the order they were applied. Every rule in either breakdown is compared,
+// including adjustments like the points cap and rounding.
+func differingRules(a, b []award) []RuleDifference {
+	totals := func(awards []award) map[string]int64 {
+		byRule := make(map[string]int64)
+		for _, aw := range awards {
+			byRule[aw.Rule] += aw.Points
+		}
+		return byRule
+	}
+	totalsA, totalsB := totals(a), totals(b)
+
+	var names []string
+	seen := make(map[string]bool)
+	for _, aw := range slices.Concat(a, b) {
+		if !seen[aw.Rule] {
+			seen[aw.Rule] = true
+			names = append(names, aw.Rule)
+		}
+	}
+
+	differences := []RuleDifference{}
+	for _, name := range names {
+		if totalsA[name] != totalsB[name] {
+			differences = append(differences, RuleDifference{Rule: name, PointsA: totalsA[name], PointsB: totalsB[name]})
+		}
+	}
+	return differences
+}
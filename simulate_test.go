@@ -0,0 +1,170 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestWrappedReceiptsDecodedLikeProcessed(t *testing.T) {
+	receipt := example(t, "simple-receipt.json")
+	unknown := strings.Replace(receipt, `"retailer"`, `"retialer": "x", "retailer"`, 1)
+	numeric := strings.Replace(receipt, `"total": "1.25"`, `"total": 1.25`, 1)
+	deep := `{"receipt": {"a": [[[[[[[[[[[1]]]]]]]]]]]}}`
+
+	tests := []struct {
+		name   string
+		args   []string
+		path   string
+		body   string
+		status int
+	}{
+		{"simulate", nil, "/score/simulate", `{"receipt": ` + receipt + `}`, http.StatusOK},
+		{"simulate without receipt", nil, "/score/simulate", `{}`, http.StatusBadRequest},
+		{"simulate strict", []string{"-strict"}, "/score/simulate", `{"receipt": ` + unknown + `}`, http.StatusBadRequest},
+		{"simulate lenient money", []string{"-lenient-money"}, "/score/simulate", `{"receipt": ` + numeric + `}`, http.StatusOK},
+		{"simulate too deep", nil, "/score/simulate", deep, http.StatusBadRequest},
+		{"compare", nil, "/score/compare", `{"a": ` + receipt + `, "b": ` + receipt + `}`, http.StatusOK},
+		{"compare strict", []string{"-strict"}, "/score/compare", `{"a": ` + receipt + `, "b": ` + unknown + `}`, http.StatusBadRequest},
+		{"compare lenient money", []string{"-lenient-money"}, "/score/compare", `{"a": ` + numeric + `, "b": ` + receipt + `}`, http.StatusOK},
+		{"compare denied retailer", []string{"-deny-retailer", "target"}, "/score/compare", `{"a": ` + receipt + `, "b": ` + receipt + `}`, http.StatusForbidden},
+		{"bench", []string{"-dev"}, "/debug/bench", `{"n": 1, "receipt": ` + receipt + `}`, http.StatusOK},
+		{"bench strict", []string{"-dev", "-strict"}, "/debug/bench", `{"n": 1, "receipt": ` + unknown + `}`, http.StatusBadRequest},
+		{"bench unknown envelope field", []string{"-dev", "-strict"}, "/debug/bench", `{"n": 1, "m": 2, "receipt": ` + receipt + `}`, http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := newServer(testConfig(t, tt.args...), newMemoryStore()).router()
+			if w := serve(r, http.MethodPost, tt.path, tt.body); w.Code != tt.status {
+				t.Errorf("status = %d, want %d: %s", w.Code, tt.status, w.Body)
+			}
+		})
+	}
+}
+
+func TestDifferingRules(t *testing.T) {
+	tests := []struct {
+		name string
+		a, b []award
+		want []RuleDifference
+	}{
+		{
+			name: "same",
+			a:    []award{{Rule: "retailerName", Points: 6}},
+			b:    []award{{Rule: "retailerName", Points: 6}},
+			want: []RuleDifference{},
+		},
+		{
+			name: "items summed per rule",
+			a:    []award{{Rule: "itemDescription", Points: 3}, {Rule: "itemDescription", Points: 2}},
+			b:    []award{{Rule: "itemDescription", Points: 5}, {Rule: "itemPairs", Points: 5}},
+			want: []RuleDifference{{Rule: "itemPairs", PointsA: 0, PointsB: 5}},
+		},
+		{
+			name: "adjustments and rules only in one",
+			a:    []award{{Rule: "retailerName", Points: 6}, {Rule: "minTotal", Points: -6}},
+			b:    []award{{Rule: "retailerName", Points: 6}, {Rule: "fractionRounding", Points: 1}, {Rule: "pointsRounding", Points: 3}, {Rule: "pointsCap", Points: -2}},
+			want: []RuleDifference{
+				{Rule: "minTotal", PointsA: -6},
+				{Rule: "fractionRounding", PointsB: 1},
+				{Rule: "pointsRounding", PointsB: 3},
+				{Rule: "pointsCap", PointsB: -2},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := differingRules(tt.a, tt.b); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("differingRules = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCompareScores(t *testing.T) {
+	tests := []struct {
+		name  string
+		a, b  string
+		delta int64
+		want  []RuleDifference
+	}{
+		{"same", receiptWith("2.50", "1.25", "1.25"), receiptWith("2.50", "1.25", "1.25"), 0, []RuleDifference{}},
+		{"one more item", receiptWith("2.50", "1.25"), receiptWith("2.50", "1.25", "1.25"), 5, []RuleDifference{{Rule: "itemPairs", PointsA: 0, PointsB: 5}}},
+		{"one item less", receiptWith("2.50", "1.25", "1.25"), receiptWith("2.50", "1.25"), -5, []RuleDifference{{Rule: "itemPairs", PointsA: 5, PointsB: 0}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store := newMemoryStore()
+			r := newServer(testConfig(t), store).router()
+			w := serve(r, http.MethodPost, "/score/compare", `{"a": `+tt.a+`, "b": `+tt.b+`}`)
+			var response CompareResponse
+			if err := json.Unmarshal(w.Body.Bytes(), &response); err != nil || w.Code != http.StatusOK {
+				t.Fatalf("status %d: %s", w.Code, w.Body)
+			}
+			if response.Delta != tt.delta || response.B.Points-response.A.Points != tt.delta {
+				t.Errorf("delta = %d with points %d and %d, want %d", response.Delta, response.A.Points, response.B.Points, tt.delta)
+			}
+			if !reflect.DeepEqual(response.DifferingRules, tt.want) {
+				t.Errorf("differing rules = %+v, want %+v", response.DifferingRules, tt.want)
+			}
+			if stored, _ := store.List(context.Background()); len(stored) != 0 {
+				t.Errorf("comparing stored %d receipts", len(stored))
+			}
+		})
+	}
+}
+
+func TestScoreInlineBreakdown(t *testing.T) {
+	tests := []struct {
+		name    string
+		args    []string
+		receipt string
+		query   string
+		points  int64
+		// listed is whether the breakdown is in the response.
+		listed bool
+	}{
+		{"target", nil, "target-receipt.json", "?verbose=2", 28, true},
+		{"M&M", nil, "M&M-receipt.json", "?verbose=2", 109, true},
+		{"without verbose", nil, "target-receipt.json", "", 28, false},
+		{"verbose 1", nil, "target-receipt.json", "?verbose=1", 28, false},
+		{"capped", []string{"-max-points", "20"}, "target-receipt.json", "?verbose=2", 20, true},
+		{"rounded", []string{"-round-points", "5"}, "target-receipt.json", "?verbose=3", 30, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store := newMemoryStore()
+			r := newServer(testConfig(t, tt.args...), store).router()
+			w := serve(r, http.MethodPost, "/score"+tt.query, example(t, tt.receipt))
+			var response ScoreResponse
+			if err := json.Unmarshal(w.Body.Bytes(), &response); err != nil || w.Code != http.StatusOK {
+				t.Fatalf("status %d: %s", w.Code, w.Body)
+			}
+			if response.Points != tt.points {
+				t.Errorf("points = %d, want %d", response.Points, tt.points)
+			}
+			if !tt.listed {
+				if response.Breakdown != nil || response.BreakdownVersion != 0 {
+					t.Errorf("breakdown included: %+v", response)
+				}
+				return
+			}
+			sum := int64(0)
+			for _, a := range response.Breakdown {
+				sum += a.Points
+			}
+			if sum != response.Points {
+				t.Errorf("breakdown sums to %d, not %d: %+v", sum, response.Points, response.Breakdown)
+			}
+			if response.BreakdownVersion != breakdownVersion {
+				t.Errorf("breakdown version %d, want %d", response.BreakdownVersion, breakdownVersion)
+			}
+			if stored, _ := store.List(context.Background()); len(stored) != 0 {
+				t.Errorf("scoring stored %d receipts", len(stored))
+			}
+		})
+	}
+}
@@ -0,0 +1,75 @@
+package main
+
+import (
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+type SlowRequest struct {
+	Method    string        `json:"method"`
+	Endpoint  string        `json:"endpoint"`
+	Latency   time.Duration `json:"latencyNs"`
+	Timestamp time.Time     `json:"timestamp"`
+}
+
+type SlowRequestsResponse struct {
+	Requests []SlowRequest `json:"requests"`
+}
+
+// slowRequests keeps the N slowest requests seen so far, slowest first.
+type slowRequests struct {
+	mu       sync.Mutex
+	limit    int
+	requests []SlowRequest
+}
+
+func newSlowRequests(limit int) *slowRequests {
+	return &slowRequests{limit: limit, requests: make([]SlowRequest, 0, limit)}
+}
+
+// record adds the request if it is among the N slowest.
+func (s *slowRequests) record(request SlowRequest) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if len(s.requests) == s.limit {
+		if s.limit == 0 || s.requests[len(s.requests)-1].Latency >= request.Latency {
+			return
+		}
+		s.requests = s.requests[:len(s.requests)-1]
+	}
+
+	i := sort.Search(len(s.requests), func(i int) bool { return s.requests[i].Latency < request.Latency })
+	s.requests = append(s.requests, SlowRequest{})
+	copy(s.requests[i+1:], s.requests[i:])
+	s.requests[i] = request
+}
+
+// snapshot returns a copy of the tracked requests, slowest first.
+func (s *slowRequests) snapshot() []SlowRequest {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return append([]SlowRequest(nil), s.requests...)
+}
+
+// middleware times every request and records it.
+func (s *slowRequests) middleware(c *gin.Context) {
+	start := time.Now()
+	c.Next()
+
+	endpoint := c.FullPath()
+	if endpoint == "" {
+		endpoint = c.Request.URL.Path
+	}
+	s.record(SlowRequest{Method: c.Request.Method, Endpoint: endpoint, Latency: time.Since(start), Timestamp: start})
+}
+
+// getSlowRequests returns the slowest requests served so far.
+func (s *server) getSlowRequests(c *gin.Context) {
+	c.JSON(http.StatusOK, SlowRequestsResponse{Requests: s.slow.snapshot()})
+}
@@ -0,0 +1,66 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestSlowRequests(t *testing.T) {
+	s := newServer(testConfig(t, "-dev", "-slow-requests", "2"), newMemoryStore())
+	r := s.router()
+	r.GET("/test/slow/:delay", func(c *gin.Context) {
+		delay, _ := time.ParseDuration(c.Param("delay"))
+		time.Sleep(delay)
+	})
+
+	for _, path := range []string{"/test/slow/20ms", "/rules", "/test/slow/60ms", "/test/slow/40ms", "/rules"} {
+		serve(r, http.MethodGet, path, "")
+	}
+
+	var response SlowRequestsResponse
+	if err := json.Unmarshal(serve(r, http.MethodGet, "/debug/slow", "").Body.Bytes(), &response); err != nil {
+		t.Fatal(err)
+	}
+	var latencies []time.Duration
+	for _, request := range response.Requests {
+		if request.Method != http.MethodGet || request.Endpoint != "/test/slow/:delay" {
+			t.Errorf("recorded %s %s", request.Method, request.Endpoint)
+		}
+		latencies = append(latencies, request.Latency)
+	}
+	// Only the two slowest are kept, slowest first.
+	if len(latencies) != 2 || latencies[0] < 60*time.Millisecond || latencies[1] < 40*time.Millisecond || latencies[0] < latencies[1] {
+		t.Errorf("latencies = %v, want the 60ms and 40ms requests", latencies)
+	}
+}
+
+func TestSlowRequestsRecord(t *testing.T) {
+	tests := []struct {
+		limit     int
+		latencies []time.Duration
+		want      []time.Duration
+	}{
+		{3, []time.Duration{1, 2, 3}, []time.Duration{3, 2, 1}},
+		{3, []time.Duration{5, 1, 4, 2, 3}, []time.Duration{5, 4, 3}},
+		{2, []time.Duration{2, 2, 1, 2}, []time.Duration{2, 2}},
+		{0, []time.Duration{1, 2}, nil},
+	}
+	for _, tt := range tests {
+		s := newSlowRequests(tt.limit)
+		for _, latency := range tt.latencies {
+			s.record(SlowRequest{Latency: latency})
+		}
+		var got []time.Duration
+		for _, request := range s.snapshot() {
+			got = append(got, request.Latency)
+		}
+		if !slices.Equal(got, tt.want) {
+			t.Errorf("limit %d, recording %v: %v, want %v", tt.limit, tt.latencies, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,510 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+var (
+	errNotFound = errors.New("receipt not found")
+	errIDTaken  = errors.New("receipt ID already in use")
+	// errVersionMismatch is returned by Update when the receipt has changed since
+	// the version the caller last saw.
+	errVersionMismatch = errors.New("receipt version mismatch")
+)
+
+// StoredReceipt is a receipt together with the points cached for it.
+type StoredReceipt struct {
+	ID        string
+	Receipt   Receipt
+	CreatedAt time.Time
+	// Version starts at 1 and is incremented by every update.
+	Version int
+	// Breakdown is how the receipt was scored when it was processed, kept as
+	// is for auditing even if the rules change later. It may be nil.
+	Breakdown []award
+
+	// Points is only meaningful once Scored is set.
+	Points int64
+	Scored bool
+
+	// Image is the decoded image attached to the receipt, if any. The store
+	// keeps it here rather than as Receipt.ImageBase64, see detachImage.
+	Image []byte
+}
+
+// Store keeps the processed receipts. Implementations must be safe for concurrent use.
+type Store interface {
+	// Add stores the receipt and its breakdown under id, or returns errIDTaken
+	// if id is already in use.
+	Add(ctx context.Context, id string, receipt Receipt, breakdown []award) error
+	// Get returns the receipt stored under id, or errNotFound.
+	Get(ctx context.Context, id string) (StoredReceipt, error)
+	// Update replaces the receipt and breakdown stored under id and clears its cached points.
+	// If version isn't 0 it must match the stored version, otherwise
+	// errVersionMismatch is returned. It returns the updated receipt.
+	Update(ctx context.Context, id string, receipt Receipt, breakdown []award, version int) (StoredReceipt, error)
+	// SetPoints caches the points scored for the receipt stored under id.
+	SetPoints(ctx context.Context, id string, points int64) error
+	// List returns every stored receipt, oldest first and by ID for equal creation times.
+	List(ctx context.Context) ([]StoredReceipt, error)
+	// Group returns the receipts stored with the given group ID, ordered like List.
+	Group(ctx context.Context, groupID string) ([]StoredReceipt, error)
+	// DeleteWhere atomically removes every receipt matching the filter and returns how many it removed.
+	DeleteWhere(ctx context.Context, match func(StoredReceipt) bool) (int, error)
+}
+
+// multiGetter is implemented by stores that can look up many receipts at once,
+// taking their lock only once.
+type multiGetter interface {
+	// GetMany returns the receipts stored under the IDs by ID. Unknown IDs
+	// are left out.
+	GetMany(ctx context.Context, ids []string) (map[string]StoredReceipt, error)
+}
+
+// getMany looks up the receipts stored under the IDs, all at once if the store
+// supports it and one at a time otherwise. Unknown IDs are left out.
+func getMany(ctx context.Context, store Store, ids []string) (map[string]StoredReceipt, error) {
+	if m, ok := store.(multiGetter); ok {
+		return m.GetMany(ctx, ids)
+	}
+
+	found := make(map[string]StoredReceipt, len(ids))
+	for _, id := range ids {
+		stored, err := store.Get(ctx, id)
+		if errors.Is(err, errNotFound) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		found[id] = stored
+	}
+	return found, nil
+}
+
+// tagger is implemented by stores that index receipts by tag.
+type tagger interface {
+	// Tagged returns the receipts with the tag, oldest first like List.
+	Tagged(ctx context.Context, tag string) ([]StoredReceipt, error)
+}
+
+// taggedReceipts returns the receipts with the tag, oldest first, from the
+// store's tag index if it has one and by going through every receipt otherwise.
+func taggedReceipts(ctx context.Context, store Store, tag string) ([]StoredReceipt, error) {
+	if t, ok := store.(tagger); ok {
+		return t.Tagged(ctx, tag)
+	}
+
+	receipts, err := store.List(ctx)
+	if err != nil {
+		return nil, err
+	}
+	key := tagKey(tag)
+	tagged := []StoredReceipt{}
+	for _, stored := range receipts {
+		if slices.ContainsFunc(stored.Receipt.Tags, func(t string) bool { return tagKey(t) == key }) {
+			tagged = append(tagged, stored)
+		}
+	}
+	return tagged, nil
+}
+
+// tagKey is what tags are compared by, so "Grocery" and " grocery" match.
+func tagKey(tag string) string {
+	return strings.ToLower(strings.TrimSpace(tag))
+}
+
+// purchaseIndexer is implemented by stores that index receipts by retailer and
+// purchase date.
+type purchaseIndexer interface {
+	// Purchases returns the receipts from the retailer purchased on any of
+	// the dates, oldest first like List. Retailers are compared by retailerKey.
+	Purchases(ctx context.Context, retailer string, dates []string) ([]StoredReceipt, error)
+}
+
+// purchases returns the receipts from the retailer purchased on any of the
+// dates, oldest first, from the store's purchase index if it has one and by
+// going through every receipt otherwise.
+func purchases(ctx context.Context, store Store, retailer string, dates []string) ([]StoredReceipt, error) {
+	if p, ok := store.(purchaseIndexer); ok {
+		return p.Purchases(ctx, retailer, dates)
+	}
+
+	receipts, err := store.List(ctx)
+	if err != nil {
+		return nil, err
+	}
+	key := retailerKey(retailer)
+	found := []StoredReceipt{}
+	for _, stored := range receipts {
+		if retailerKey(stored.Receipt.Retailer) == key && slices.Contains(dates, stored.Receipt.PurchaseDate) {
+			found = append(found, stored)
+		}
+	}
+	return found, nil
+}
+
+// retailerKey is what retailers are compared by, so "Target" and " target"
+// are the same retailer.
+func retailerKey(retailer string) string {
+	return strings.ToLower(strings.TrimSpace(retailer))
+}
+
+// purchaseKey is the key of a retailer's receipts from a date in the purchase index.
+func purchaseKey(retailer, date string) string {
+	return retailerKey(retailer) + "\x00" + date
+}
+
+// memoryStore is the default Store, keeping everything in a map.
+type memoryStore struct {
+	mu       sync.RWMutex
+	receipts map[string]StoredReceipt
+	// groups indexes the receipt IDs by group ID.
+	groups map[string]map[string]bool
+	// tags indexes the receipt IDs by tag key.
+	tags map[string]map[string]bool
+	// purchases indexes the receipt IDs by purchaseKey.
+	purchases map[string]map[string]bool
+}
+
+func newMemoryStore() *memoryStore {
+	return &memoryStore{
+		receipts:  make(map[string]StoredReceipt),
+		groups:    make(map[string]map[string]bool),
+		tags:      make(map[string]map[string]bool),
+		purchases: make(map[string]map[string]bool),
+	}
+}
+
+func (s *memoryStore) Add(ctx context.Context, id string, receipt Receipt, breakdown []award) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return s.put(StoredReceipt{ID: id, Receipt: receipt, CreatedAt: time.Now(), Version: 1, Breakdown: breakdown})
+}
+
+// put inserts a complete stored receipt, keeping the indexes up to date.
+func (s *memoryStore) put(stored StoredReceipt) error {
+	stored, err := detachImage(stored)
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, taken := s.receipts[stored.ID]; taken {
+		return errIDTaken
+	}
+	s.receipts[stored.ID] = stored
+	s.index(stored)
+	return nil
+}
+
+func (s *memoryStore) Update(ctx context.Context, id string, receipt Receipt, breakdown []award, version int) (StoredReceipt, error) {
+	_, updated, err := s.update(ctx, id, receipt, breakdown, version)
+	return updated, err
+}
+
+// update is Update, also returning the receipt as it was before.
+func (s *memoryStore) update(ctx context.Context, id string, receipt Receipt, breakdown []award, version int) (StoredReceipt, StoredReceipt, error) {
+	if err := ctx.Err(); err != nil {
+		return StoredReceipt{}, StoredReceipt{}, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	previous, exists := s.receipts[id]
+	if !exists {
+		return StoredReceipt{}, StoredReceipt{}, errNotFound
+	}
+	if version != 0 && version != previous.Version {
+		return StoredReceipt{}, StoredReceipt{}, errVersionMismatch
+	}
+
+	updated, err := detachImage(StoredReceipt{ID: id, Receipt: receipt, CreatedAt: previous.CreatedAt, Version: previous.Version + 1, Breakdown: breakdown})
+	if err != nil {
+		return StoredReceipt{}, StoredReceipt{}, err
+	}
+	s.replace(previous, updated)
+	return previous, updated, nil
+}
+
+// replace swaps old for stored, which must have the same ID. s.mu must be held.
+func (s *memoryStore) replace(old, stored StoredReceipt) {
+	s.unindex(old)
+	s.receipts[stored.ID] = stored
+	s.index(stored)
+}
+
+// index adds the receipt to the group, tag and purchase indexes. s.mu must be held.
+func (s *memoryStore) index(stored StoredReceipt) {
+	if groupID := stored.Receipt.GroupID; groupID != "" {
+		addToIndex(s.groups, groupID, stored.ID)
+	}
+	addToIndex(s.purchases, purchaseKey(stored.Receipt.Retailer, stored.Receipt.PurchaseDate), stored.ID)
+	for _, tag := range stored.Receipt.Tags {
+		addToIndex(s.tags, tagKey(tag), stored.ID)
+	}
+}
+
+// unindex removes the receipt from the group, tag and purchase indexes. s.mu must be held.
+func (s *memoryStore) unindex(stored StoredReceipt) {
+	if groupID := stored.Receipt.GroupID; groupID != "" {
+		removeFromIndex(s.groups, groupID, stored.ID)
+	}
+	removeFromIndex(s.purchases, purchaseKey(stored.Receipt.Retailer, stored.Receipt.PurchaseDate), stored.ID)
+	for _, tag := range stored.Receipt.Tags {
+		removeFromIndex(s.tags, tagKey(tag), stored.ID)
+	}
+}
+
+func addToIndex(index map[string]map[string]bool, key, id string) {
+	if index[key] == nil {
+		index[key] = make(map[string]bool)
+	}
+	index[key][id] = true
+}
+
+func removeFromIndex(index map[string]map[string]bool, key, id string) {
+	delete(index[key], id)
+	if len(index[key]) == 0 {
+		delete(index, key)
+	}
+}
+
+func (s *memoryStore) Get(ctx context.Context, id string) (StoredReceipt, error) {
+	if err := ctx.Err(); err != nil {
+		return StoredReceipt{}, err
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	stored, exists := s.receipts[id]
+	if !exists {
+		return StoredReceipt{}, errNotFound
+	}
+	return stored, nil
+}
+
+func (s *memoryStore) GetMany(ctx context.Context, ids []string) (map[string]StoredReceipt, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	found := make(map[string]StoredReceipt, len(ids))
+	for _, id := range ids {
+		if stored, exists := s.receipts[id]; exists {
+			found[id] = stored
+		}
+	}
+	return found, nil
+}
+
+func (s *memoryStore) SetPoints(ctx context.Context, id string, points int64) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	stored, exists := s.receipts[id]
+	if !exists {
+		return errNotFound
+	}
+	stored.Points, stored.Scored = points, true
+	s.receipts[id] = stored
+	return nil
+}
+
+func (s *memoryStore) List(ctx context.Context) ([]StoredReceipt, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	list := s.appendAll(make([]StoredReceipt, 0, len(s.receipts)))
+	sortStored(list)
+	return list, nil
+}
+
+// appendAll appends every receipt to list, unsorted. s.mu must be held.
+func (s *memoryStore) appendAll(list []StoredReceipt) []StoredReceipt {
+	for _, stored := range s.receipts {
+		list = append(list, stored)
+	}
+	return list
+}
+
+func (s *memoryStore) Group(ctx context.Context, groupID string) ([]StoredReceipt, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	list := s.appendGroup(make([]StoredReceipt, 0, len(s.groups[groupID])), groupID)
+	sortStored(list)
+	return list, nil
+}
+
+// appendGroup appends the receipts of the group to list, unsorted. s.mu must
+// be held.
+func (s *memoryStore) appendGroup(list []StoredReceipt, groupID string) []StoredReceipt {
+	for id := range s.groups[groupID] {
+		list = append(list, s.receipts[id])
+	}
+	return list
+}
+
+func (s *memoryStore) Tagged(ctx context.Context, tag string) ([]StoredReceipt, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	key := tagKey(tag)
+	list := s.appendTagged(make([]StoredReceipt, 0, len(s.tags[key])), key)
+	sortStored(list)
+	return list, nil
+}
+
+// appendTagged appends the receipts with the tag key to list, unsorted. s.mu
+// must be held.
+func (s *memoryStore) appendTagged(list []StoredReceipt, key string) []StoredReceipt {
+	for id := range s.tags[key] {
+		list = append(list, s.receipts[id])
+	}
+	return list
+}
+
+func (s *memoryStore) Purchases(ctx context.Context, retailer string, dates []string) ([]StoredReceipt, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	list := s.appendPurchases([]StoredReceipt{}, retailer, dates)
+	sortStored(list)
+	return list, nil
+}
+
+// appendPurchases appends the receipts from the retailer purchased on any of
+// the dates to list, unsorted. s.mu must be held.
+func (s *memoryStore) appendPurchases(list []StoredReceipt, retailer string, dates []string) []StoredReceipt {
+	for _, date := range dates {
+		for id := range s.purchases[purchaseKey(retailer, date)] {
+			list = append(list, s.receipts[id])
+		}
+	}
+	return list
+}
+
+func (s *memoryStore) DeleteWhere(ctx context.Context, match func(StoredReceipt) bool) (int, error) {
+	if err := ctx.Err(); err != nil {
+		return 0, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.deleteMatching(match), nil
+}
+
+// Import adds complete stored receipts, keeping their creation times,
+// versions and cached points. If any of the IDs is taken nothing is added and
+// errIDTaken is returned.
+func (s *memoryStore) Import(ctx context.Context, receipts []StoredReceipt) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, stored := range receipts {
+		if _, taken := s.receipts[stored.ID]; taken {
+			return errIDTaken
+		}
+	}
+	for _, stored := range receipts {
+		s.receipts[stored.ID] = stored
+		s.index(stored)
+	}
+	return nil
+}
+
+// deleteMatching removes the receipts matching the filter. s.mu must be held.
+func (s *memoryStore) deleteMatching(match func(StoredReceipt) bool) int {
+	deleted := 0
+	for id, stored := range s.receipts {
+		if match(stored) {
+			delete(s.receipts, id)
+			s.unindex(stored)
+			deleted++
+		}
+	}
+	return deleted
+}
+
+// sortStored orders receipts by creation time, then ID, so listings are deterministic.
+func sortStored(list []StoredReceipt) {
+	sort.Slice(list, func(i, j int) bool { return storedBefore(list[i], list[j]) })
+}
+
+// storedBefore reports whether a was stored before b, ordering receipts
+// created at the same time by ID.
+func storedBefore(a, b StoredReceipt) bool {
+	if !a.CreatedAt.Equal(b.CreatedAt) {
+		return a.CreatedAt.Before(b.CreatedAt)
+	}
+	return a.ID < b.ID
+}
+
+// newID generates receipt IDs. It is a variable so the generator can be swapped out.
+var newID = func() string { return uuid.New().String() }
+
+// maxIDAttempts bounds how often insertReceipt regenerates an ID that is already taken.
+const maxIDAttempts = 5
+
+// insertReceipt stores the receipt and its breakdown under a newly generated
+// ID. An ID that is already in use is never overwritten; a fresh one is
+// generated instead.
+func insertReceipt(ctx context.Context, store Store, receipt Receipt, breakdown []award) (string, error) {
+	for attempt := 0; attempt < maxIDAttempts; attempt++ {
+		id := newID()
+		err := store.Add(ctx, id, receipt, breakdown)
+		if errors.Is(err, errIDTaken) {
+			log.Printf("generated receipt ID %s is already in use, regenerating\n", id)
+			continue
+		}
+		if err != nil {
+			return "", err
+		}
+		return id, nil
+	}
+	return "", fmt.Errorf("no unused receipt ID after %d attempts", maxIDAttempts)
+}
@@ -0,0 +1,315 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// testStores returns an empty instance of each Store implementation.
+func testStores(t *testing.T) map[string]Store {
+	t.Helper()
+	file, err := openFileStore(t.TempDir()+"/receipts.json", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return map[string]Store{
+		"memory":  newMemoryStore(),
+		"sharded": newShardedStore(4),
+		"file":    file,
+	}
+}
+
+// seedStored puts complete stored receipts, with their creation times, into
+// the store.
+func seedStored(t *testing.T, store Store, receipts []StoredReceipt) {
+	t.Helper()
+	if sharded, ok := store.(*shardedStore); ok {
+		for _, stored := range receipts {
+			if err := sharded.shard(stored.ID).put(stored); err != nil {
+				t.Fatal(err)
+			}
+		}
+		return
+	}
+	if err := store.(importer).Import(context.Background(), receipts); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// storedIDs returns the IDs of the receipts in order.
+func storedIDs(receipts []StoredReceipt) []string {
+	ids := []string{}
+	for _, stored := range receipts {
+		ids = append(ids, stored.ID)
+	}
+	return ids
+}
+
+func TestPurchasesIndex(t *testing.T) {
+	for name, store := range testStores(t) {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+			add := func(id, retailer, date string) {
+				if err := store.Add(ctx, id, Receipt{Retailer: retailer, PurchaseDate: date}, nil); err != nil {
+					t.Fatal(err)
+				}
+			}
+			add("a", "Target", "2022-01-01")
+			add("b", " target", "2022-01-02")
+			add("c", "Walgreens", "2022-01-01")
+			add("d", "Target", "2022-01-05")
+			if _, err := store.Update(ctx, "d", Receipt{Retailer: "Target", PurchaseDate: "2022-01-03"}, nil, 0); err != nil {
+				t.Fatal(err)
+			}
+			if _, err := store.DeleteWhere(ctx, func(stored StoredReceipt) bool { return stored.ID == "b" }); err != nil {
+				t.Fatal(err)
+			}
+
+			tests := []struct {
+				retailer string
+				dates    []string
+				want     []string
+			}{
+				{"TARGET", []string{"2022-01-01"}, []string{"a"}},
+				{"Target", []string{"2022-01-02"}, []string{}},
+				{"Target", []string{"2022-01-01", "2022-01-03", "2022-01-05"}, []string{"a", "d"}},
+				{"Walgreens", []string{"2022-01-01", "2022-01-02"}, []string{"c"}},
+				{"Costco", []string{"2022-01-01"}, []string{}},
+			}
+			for _, tt := range tests {
+				got, err := purchases(ctx, store, tt.retailer, tt.dates)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if ids := storedIDs(got); !slices.Equal(ids, tt.want) {
+					t.Errorf("purchases(%q, %v) = %v, want %v", tt.retailer, tt.dates, ids, tt.want)
+				}
+			}
+		})
+	}
+}
+
+func TestGroupOrder(t *testing.T) {
+	for name, store := range testStores(t) {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+			var want []string
+			for i := 20; i > 0; i-- {
+				id := fmt.Sprintf("r%02d", i)
+				if err := store.Add(ctx, id, Receipt{GroupID: "trip"}, nil); err != nil {
+					t.Fatal(err)
+				}
+				want = append(want, id)
+			}
+			if err := store.Add(ctx, "other", Receipt{GroupID: "another trip"}, nil); err != nil {
+				t.Fatal(err)
+			}
+			// Updates keep the creation time, and with it the position.
+			if _, err := store.Update(ctx, "r20", Receipt{GroupID: "trip"}, nil, 0); err != nil {
+				t.Fatal(err)
+			}
+
+			for range 5 {
+				group, err := store.Group(ctx, "trip")
+				if err != nil {
+					t.Fatal(err)
+				}
+				if ids := storedIDs(group); !slices.Equal(ids, want) {
+					t.Fatalf("Group = %v, want %v", ids, want)
+				}
+			}
+		})
+	}
+}
+
+func TestInsertReceiptRegeneratesTakenIDs(t *testing.T) {
+	tests := []struct {
+		name string
+		ids  []string
+		want string
+	}{
+		{"unused", []string{"b"}, "b"},
+		{"one collision", []string{"a", "b"}, "b"},
+		{"repeated collisions", []string{"a", "a", "a", "a", "b"}, "b"},
+		{"always taken", []string{"a", "a", "a", "a", "a", "b"}, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			generated := 0
+			defer func(generate func() string) { newID = generate }(newID)
+			newID = func() string {
+				generated++
+				return tt.ids[generated-1]
+			}
+
+			for name, store := range testStores(t) {
+				t.Run(name, func(t *testing.T) {
+					generated = 0
+					ctx := context.Background()
+					if err := store.Add(ctx, "a", Receipt{Retailer: "first"}, nil); err != nil {
+						t.Fatal(err)
+					}
+					id, err := insertReceipt(ctx, store, Receipt{Retailer: "second"}, nil)
+					if tt.want == "" {
+						if err == nil {
+							t.Fatalf("inserted as %s, want an error", id)
+						}
+					} else if id != tt.want || err != nil {
+						t.Fatalf("insertReceipt = %q, %v, want %q", id, err, tt.want)
+					}
+
+					// The receipt already stored under a is never overwritten.
+					if first, err := store.Get(ctx, "a"); err != nil || first.Receipt.Retailer != "first" {
+						t.Errorf("receipt a = %+v, %v", first.Receipt, err)
+					}
+				})
+			}
+		})
+	}
+}
+
+func TestListOrder(t *testing.T) {
+	created := time.Date(2022, 1, 1, 12, 0, 0, 0, time.UTC)
+	receipts := []StoredReceipt{
+		{ID: "c", CreatedAt: created.Add(time.Second), Version: 1},
+		{ID: "b", CreatedAt: created, Version: 1},
+		{ID: "d", CreatedAt: created.Add(-time.Hour), Version: 1},
+		{ID: "a", CreatedAt: created, Version: 1},
+		{ID: "e", CreatedAt: created.Add(time.Second), Version: 1},
+	}
+	// Oldest first, and by ID for the same creation time.
+	want := []string{"d", "a", "b", "c", "e"}
+
+	for name, store := range testStores(t) {
+		t.Run(name, func(t *testing.T) {
+			seedStored(t, store, receipts)
+			for range 5 {
+				list, err := store.List(context.Background())
+				if err != nil {
+					t.Fatal(err)
+				}
+				if ids := storedIDs(list); !slices.Equal(ids, want) {
+					t.Fatalf("List = %v, want %v", ids, want)
+				}
+			}
+		})
+	}
+
+	// The listing and export endpoints keep that order.
+	store := newMemoryStore()
+	seedStored(t, store, receipts)
+	r := newServer(testConfig(t), store).router()
+	var listing struct{ Receipts []ReceiptSummary }
+	json.Unmarshal(serve(r, http.MethodGet, "/receipts", "").Body.Bytes(), &listing)
+	var listed []string
+	for _, summary := range listing.Receipts {
+		listed = append(listed, summary.ID)
+	}
+	if !slices.Equal(listed, want) {
+		t.Errorf("GET /receipts lists %v, want %v", listed, want)
+	}
+	var exported []string
+	for _, line := range strings.Split(strings.TrimSpace(serve(r, http.MethodGet, "/receipts/export", "").Body.String()), "\n") {
+		var receipt ExportedReceipt
+		json.Unmarshal([]byte(line), &receipt)
+		exported = append(exported, receipt.ID)
+	}
+	if !slices.Equal(exported, want) {
+		t.Errorf("GET /receipts/export lists %v, want %v", exported, want)
+	}
+}
+
+func BenchmarkConcurrentAdd(b *testing.B) {
+	receipt := Receipt{Retailer: "Target", PurchaseDate: "2022-01-01", PurchaseTime: "13:01", Total: "1.25", Items: []Item{{ShortDescription: "Pepsi", Price: "1.25"}}}
+	stores := []struct {
+		name  string
+		store func() Store
+	}{
+		{"single lock", func() Store { return newMemoryStore() }},
+		{"4 shards", func() Store { return newShardedStore(4) }},
+		{"16 shards", func() Store { return newShardedStore(16) }},
+	}
+	for _, s := range stores {
+		b.Run(s.name, func(b *testing.B) {
+			store := s.store()
+			ctx := context.Background()
+			var next atomic.Int64
+			b.RunParallel(func(pb *testing.PB) {
+				for pb.Next() {
+					id := strconv.FormatInt(next.Add(1), 10)
+					if err := store.Add(ctx, id, receipt, nil); err != nil {
+						b.Error(err)
+						return
+					}
+				}
+			})
+		})
+	}
+}
+
+func TestFileStoreCachedPoints(t *testing.T) {
+	tests := []struct {
+		name string
+		// args are the options the store is reopened under.
+		args []string
+		// loaded reports whether the saved points are still used.
+		loaded bool
+		points int64
+	}{
+		{"same rules", nil, true, 999},
+		{"changed rules", []string{"-round-points", "5"}, false, 30},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := t.TempDir() + "/receipts.json"
+			cfg := testConfig(t)
+			store, err := openFileStore(path, cfg.Rules.version())
+			if err != nil {
+				t.Fatal(err)
+			}
+			r := newServer(cfg, store).router()
+			id := process(t, r, example(t, "target-receipt.json"))
+
+			// Points that were recomputed instead of loaded would be 28 again.
+			data, err := os.ReadFile(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !strings.Contains(string(data), `"points":28`) {
+				t.Fatalf("no points saved: %s", data)
+			}
+			if err := os.WriteFile(path, []byte(strings.Replace(string(data), `"points":28`, `"points":999`, 1)), 0o600); err != nil {
+				t.Fatal(err)
+			}
+
+			cfg = testConfig(t, tt.args...)
+			reopened, err := openFileStore(path, cfg.Rules.version())
+			if err != nil {
+				t.Fatal(err)
+			}
+			stored, err := reopened.Get(context.Background(), id)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if stored.Scored != tt.loaded {
+				t.Errorf("scored = %v after loading, want %v", stored.Scored, tt.loaded)
+			}
+			r = newServer(cfg, reopened).router()
+			if got := pointsOf(t, r, id).Points; got != tt.points {
+				t.Errorf("points = %d, want %d", got, tt.points)
+			}
+			if stored, _ := reopened.Get(context.Background(), id); !stored.Scored || stored.Points != tt.points {
+				t.Errorf("cached points = %d (scored %v), want %d", stored.Points, stored.Scored, tt.points)
+			}
+		})
+	}
+}
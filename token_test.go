@@ -0,0 +1,67 @@
+package main
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestVerifyToken(t *testing.T) {
+	r := newServer(testConfig(t, "-token-key", "secret"), newMemoryStore()).router()
+	id := process(t, r, example(t, "target-receipt.json"))
+	token := pointsOf(t, r, id).Token
+	if token == "" {
+		t.Fatal("no token with the points")
+	}
+
+	payload, signature, _ := strings.Cut(token, ".")
+	// The first character of the signature holds whole bits, unlike the last.
+	changed := "A"
+	if signature[0] == 'A' {
+		changed = "B"
+	}
+	inflated := base64.RawURLEncoding.EncodeToString([]byte(`{"id":"`+id+`","points":1000}`)) + "." + signature
+	tests := []struct {
+		name  string
+		token string
+		valid bool
+	}{
+		{"valid", token, true},
+		{"inflated points", inflated, false},
+		{"signature changed", payload + "." + changed + signature[1:], false},
+		{"no signature", payload, false},
+		{"other key", signPoints([]byte("other"), pointsClaim{ID: id, Points: 28}), false},
+		{"user token", signUser([]byte("secret"), "alice"), false},
+		{"empty", "", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			body, _ := json.Marshal(VerifyRequest{Token: tt.token})
+			w := serve(r, http.MethodPost, "/verify", string(body))
+			if !tt.valid {
+				if w.Code != http.StatusBadRequest || !strings.Contains(w.Body.String(), codeTokenInvalid) {
+					t.Errorf("status = %d, want 400: %s", w.Code, w.Body)
+				}
+				return
+			}
+			var response VerifyResponse
+			if err := json.Unmarshal(w.Body.Bytes(), &response); err != nil || w.Code != http.StatusOK {
+				t.Fatalf("status = %d: %s", w.Code, w.Body)
+			}
+			if response.ID != id || response.Points != 28 {
+				t.Errorf("verified %+v, want %s with 28 points", response, id)
+			}
+		})
+	}
+
+	// Without a key no tokens are handed out or checked.
+	r = newServer(testConfig(t), newMemoryStore()).router()
+	if points := pointsOf(t, r, process(t, r, example(t, "target-receipt.json"))); points.Token != "" {
+		t.Errorf("token %q without a key", points.Token)
+	}
+	if w := serve(r, http.MethodPost, "/verify", `{"token": "`+token+`"}`); w.Code != http.StatusNotFound {
+		t.Errorf("status = %d without a key", w.Code)
+	}
+}
@@ -0,0 +1,79 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"slices"
+	"sync"
+	"testing"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// spanExporter records the spans of the tracer provider installed for the
+// tests. tracer only picks up the first provider installed, so it is installed
+// once, even when the tests run more than once.
+var spanExporter = sync.OnceValue(func() *tracetest.InMemoryExporter {
+	exporter := tracetest.NewInMemoryExporter()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter)))
+	return exporter
+})
+
+func TestTracing(t *testing.T) {
+	exporter := spanExporter()
+
+	r := newServer(testConfig(t, "-otlp-endpoint", "http://localhost:4318"), newMemoryStore()).router()
+	w := serve(r, http.MethodPost, "/receipts/process", example(t, "simple-receipt.json"))
+	var processed ReceiptResponse
+	json.Unmarshal(w.Body.Bytes(), &processed)
+
+	tests := []struct {
+		method, path string
+		name         string
+		// scored is whether the request scores the receipt, withPoints
+		// whether its span records the points.
+		scored, withPoints bool
+	}{
+		{http.MethodPost, "/receipts/process", "POST /receipts/process", true, false},
+		{http.MethodGet, "/receipts/" + processed.ID + "/points", "GET /receipts/:id/points", false, true},
+		{http.MethodGet, "/receipts/missing/points", "GET /receipts/:id/points", false, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.method+" "+tt.path, func(t *testing.T) {
+			exporter.Reset()
+			serve(r, tt.method, tt.path, example(t, "simple-receipt.json"))
+
+			var requests, scoring []tracetest.SpanStub
+			for _, span := range exporter.GetSpans() {
+				switch {
+				case span.SpanKind == trace.SpanKindServer:
+					requests = append(requests, span)
+				case span.Name == "calculatePoints":
+					scoring = append(scoring, span)
+				}
+			}
+			if len(requests) != 1 {
+				t.Fatalf("%d request spans, want 1", len(requests))
+			}
+			if requests[0].Name != tt.name {
+				t.Errorf("span name = %q, want %q", requests[0].Name, tt.name)
+			}
+			withPoints := slices.ContainsFunc(requests[0].Attributes, func(kv attribute.KeyValue) bool { return kv.Key == "receipt.points" })
+			if withPoints != tt.withPoints {
+				t.Errorf("span has points %v, want %v: %v", withPoints, tt.withPoints, requests[0].Attributes)
+			}
+			if got := len(scoring) > 0; got != tt.scored {
+				t.Errorf("%d scoring spans", len(scoring))
+			}
+			for _, span := range scoring {
+				if span.Parent.SpanID() != requests[0].SpanContext.SpanID() {
+					t.Errorf("scoring span isn't a child of the request span")
+				}
+			}
+		})
+	}
+}
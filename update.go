@@ -0,0 +1,160 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// maxReceiptIDLength is the longest ID a client may store a receipt under.
+const maxReceiptIDLength = 100
+
+// receiptIDPattern matches the IDs clients may store receipts under: URL safe
+// characters only, which the server's generated UUIDs match too.
+var receiptIDPattern = regexp.MustCompile(`^[A-Za-z0-9._~-]+$`)
+
+// validReceiptID reports whether a client may store a receipt under id.
+func validReceiptID(id string) bool {
+	return len(id) <= maxReceiptIDLength && receiptIDPattern.MatchString(id)
+}
+
+// etag formats a receipt version as a strong entity tag.
+func etag(version int) string {
+	return strconv.Quote(strconv.Itoa(version))
+}
+
+// parseIfMatch returns the versions an If-Match header accepts, none if the
+// header is missing or "*", or false if it can't match any version. The header
+// may list several entity tags, and weak ones like W/"3" match like strong
+// ones, as a version never changes without the receipt changing.
+func parseIfMatch(header string) ([]int, bool) {
+	header = strings.TrimSpace(header)
+	if header == "" || header == "*" {
+		return nil, true
+	}
+	var versions []int
+	for _, tag := range strings.Split(header, ",") {
+		tag = strings.TrimPrefix(strings.TrimSpace(tag), "W/")
+		unquoted, err := strconv.Unquote(tag)
+		if err != nil || !strings.HasPrefix(tag, `"`) {
+			return nil, false
+		}
+		if version, err := strconv.Atoi(unquoted); err == nil && version >= 1 {
+			versions = append(versions, version)
+		}
+	}
+	return versions, len(versions) > 0
+}
+
+// updateReceipt replaces a stored receipt, or stores a new one under the ID
+// given by the client. With an If-Match header the update only succeeds if
+// the receipt still has one of its ETags, see etag, and nothing is created, so
+// a missing receipt fails the precondition too. Only the admin and, with
+// -user-key, the user owning the receipt may update it; users may create
+// receipts for themselves.
+func (s *server) updateReceipt(c *gin.Context) {
+	id := c.Param("id")
+	ifMatch := c.GetHeader("If-Match")
+	versions, ok := parseIfMatch(ifMatch)
+	if !ok {
+		c.JSON(http.StatusPreconditionFailed, errorBody(c, codeVersionMismatch))
+		return
+	}
+
+	ctx := c.Request.Context()
+	current, err := s.store.Get(ctx, id)
+	exists := err == nil
+	if err != nil && !errors.Is(err, errNotFound) {
+		storeFailed(c, err)
+		return
+	}
+	admin := isAdmin(c, s.cfg.AdminToken)
+	if user := requestUser(c); !admin && (user == "" || exists && current.Receipt.UserID != user) {
+		if user == "" {
+			c.JSON(http.StatusUnauthorized, errorBody(c, codeAdminTokenRequired))
+		} else {
+			c.JSON(http.StatusForbidden, errorBody(c, codeUserMismatch))
+		}
+		return
+	}
+
+	receipt, err := bindReceipt(c, s.cfg)
+	if err == nil {
+		err = s.checkReceipt(receipt)
+	}
+	if err == nil && !admin {
+		// The admin may file receipts under any user.
+		err = s.claimReceipt(&receipt, requestUser(c))
+	}
+	if err != nil {
+		s.receiptRejected(c, err)
+		return
+	}
+
+	version := 0
+	if len(versions) > 0 && exists {
+		if !slices.Contains(versions, current.Version) {
+			c.JSON(http.StatusPreconditionFailed, errorBody(c, codeVersionMismatch))
+			return
+		}
+		version = current.Version
+	} else if exists && !admin {
+		// The owner was checked on this version, so an update in between
+		// fails rather than someone else's receipt being replaced unseen.
+		version = current.Version
+	}
+
+	points, breakdown, scored := s.breakdown(ctx, StoredReceipt{ID: id, Receipt: receipt})
+	updated, err := s.store.Update(ctx, id, receipt, breakdown, version)
+	if errors.Is(err, errNotFound) && ifMatch == "" {
+		if !validReceiptID(id) {
+			c.JSON(http.StatusBadRequest, errorBody(c, codeInvalidReceiptID, maxReceiptIDLength))
+			return
+		}
+		err = s.store.Add(ctx, id, receipt, breakdown)
+		if err == nil {
+			stored := StoredReceipt{ID: id, Receipt: receipt, Version: 1, Breakdown: breakdown}
+			if scored {
+				stored = s.scored(ctx, stored, points, breakdown)
+			}
+			s.processed(ctx, stored, c.ClientIP())
+			c.Header("ETag", etag(1))
+			c.JSON(http.StatusCreated, ReceiptResponse{ID: id})
+			return
+		}
+		if errors.Is(err, errIDTaken) && admin {
+			// Another request created it first, so this one replaces it.
+			updated, err = s.store.Update(ctx, id, receipt, breakdown, 0)
+		} else if errors.Is(err, errIDTaken) {
+			// Another request created it first, and it may belong to someone
+			// else, so this one fails like any other concurrent change.
+			err = errVersionMismatch
+		}
+	}
+	switch {
+	case errors.Is(err, errNotFound) && ifMatch != "":
+		// There is no version for If-Match to match, see RFC 9110 13.1.1.
+		c.JSON(http.StatusPreconditionFailed, errorBody(c, codeVersionMismatch))
+		return
+	case errors.Is(err, errNotFound):
+		c.JSON(http.StatusNotFound, errorBody(c, codeReceiptNotFound))
+		return
+	case errors.Is(err, errVersionMismatch):
+		c.JSON(http.StatusPreconditionFailed, errorBody(c, codeVersionMismatch))
+		return
+	case err != nil:
+		storeFailed(c, err)
+		return
+	}
+
+	if scored {
+		s.scored(ctx, updated, points, breakdown)
+	}
+	c.Header("ETag", etag(updated.Version))
+	c.JSON(http.StatusOK, ReceiptResponse{ID: updated.ID})
+}
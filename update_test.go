@@ -0,0 +1,129 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestParseIfMatch(t *testing.T) {
+	tests := []struct {
+		header   string
+		versions []int
+		ok       bool
+	}{
+		{"", nil, true},
+		{"*", nil, true},
+		{`"3"`, []int{3}, true},
+		{`W/"3"`, []int{3}, true},
+		{`"2", W/"3" ,"4"`, []int{2, 3, 4}, true},
+		{`"x", "5"`, []int{5}, true},
+		{`"0"`, nil, false},
+		{`3`, nil, false},
+		{`"3", 4`, nil, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.header, func(t *testing.T) {
+			versions, ok := parseIfMatch(tt.header)
+			if ok != tt.ok || !slices.Equal(versions, tt.versions) {
+				t.Errorf("parseIfMatch = %v, %v, want %v, %v", versions, ok, tt.versions, tt.ok)
+			}
+		})
+	}
+}
+
+func TestUpdateReceiptAuthorization(t *testing.T) {
+	const key = "secret"
+	alice, bob := signUser([]byte(key), "alice"), signUser([]byte(key), "bob")
+	receipt := example(t, "simple-receipt.json")
+	alicesReceipt := strings.Replace(receipt, "{", `{"userId": "alice",`, 1)
+
+	tests := []struct {
+		name    string
+		id      string
+		headers []string
+		status  int
+	}{
+		{"anonymous", "admins", nil, http.StatusUnauthorized},
+		{"wrong admin token", "admins", []string{"Authorization", "Bearer nope"}, http.StatusUnauthorized},
+		{"admin", "admins", []string{"Authorization", "Bearer admin"}, http.StatusOK},
+		{"admin on a user's receipt", "alices", []string{"Authorization", "Bearer admin"}, http.StatusOK},
+		{"owner", "alices", []string{"X-User-Token", alice}, http.StatusOK},
+		{"another user", "alices", []string{"X-User-Token", bob}, http.StatusForbidden},
+		{"another user on the admin's receipt", "admins", []string{"X-User-Token", bob}, http.StatusForbidden},
+		{"user creating a receipt", "bobs", []string{"X-User-Token", bob}, http.StatusCreated},
+		{"matching weak ETag", "admins", []string{"Authorization", "Bearer admin", "If-Match", `"7", W/"1"`}, http.StatusOK},
+		{"stale ETags", "admins", []string{"Authorization", "Bearer admin", "If-Match", `"2", "3"`}, http.StatusPreconditionFailed},
+		{"any ETag", "admins", []string{"Authorization", "Bearer admin", "If-Match", "*"}, http.StatusOK},
+		{"any ETag of a missing receipt", "missing", []string{"Authorization", "Bearer admin", "If-Match", "*"}, http.StatusPreconditionFailed},
+		{"an ETag of a missing receipt", "missing", []string{"Authorization", "Bearer admin", "If-Match", `"1"`}, http.StatusPreconditionFailed},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store := newMemoryStore()
+			ctx := context.Background()
+			for id, body := range map[string]string{"admins": receipt, "alices": alicesReceipt} {
+				stored, err := decodeReceipt(strings.NewReader(body), Config{})
+				if err != nil {
+					t.Fatal(err)
+				}
+				if err := store.Add(ctx, id, stored, nil); err != nil {
+					t.Fatal(err)
+				}
+			}
+			r := newServer(testConfig(t, "-admin-token", "admin", "-user-key", key), store).router()
+
+			body := receipt
+			if tt.id == "alices" {
+				body = alicesReceipt
+			}
+			if w := serve(r, http.MethodPut, "/receipts/"+tt.id, body, tt.headers...); w.Code != tt.status {
+				t.Errorf("status = %d, want %d: %s", w.Code, tt.status, w.Body)
+			}
+		})
+	}
+
+	r := newServer(testConfig(t), newMemoryStore()).router()
+	if w := serve(r, http.MethodPut, "/receipts/x", receipt); w.Code != http.StatusNotFound {
+		t.Errorf("without -admin-token or -user-key: status = %d, want 404", w.Code)
+	}
+}
+
+func TestUpsertReceipt(t *testing.T) {
+	admin := []string{"Authorization", "Bearer admin"}
+	tests := []struct {
+		name     string
+		id       string
+		statuses []int
+	}{
+		{"create then replace", "import-42", []int{http.StatusCreated, http.StatusOK}},
+		{"generated ID format", "7fb1377b-b223-49d9-a31a-5a02701dd310", []int{http.StatusCreated, http.StatusOK}},
+		{"unsafe characters", "bad!id", []int{http.StatusBadRequest, http.StatusBadRequest}},
+		{"too long", strings.Repeat("x", maxReceiptIDLength+1), []int{http.StatusBadRequest, http.StatusBadRequest}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := newServer(testConfig(t, "-admin-token", "admin"), newMemoryStore()).router()
+			for i, name := range []string{"simple-receipt.json", "target-receipt.json"} {
+				w := serve(r, http.MethodPut, "/receipts/"+tt.id, example(t, name), admin...)
+				if w.Code != tt.statuses[i] {
+					t.Fatalf("PUT %s: status = %d, want %d: %s", name, w.Code, tt.statuses[i], w.Body)
+				}
+			}
+			if tt.statuses[0] != http.StatusCreated {
+				return
+			}
+			if got := pointsOf(t, r, tt.id).Points; got != 28 {
+				t.Errorf("points after replacing = %d, want the target receipt's 28", got)
+			}
+		})
+	}
+
+	r := newServer(testConfig(t, "-admin-token", "admin"), newMemoryStore()).router()
+	id := process(t, r, example(t, "simple-receipt.json"))
+	if w := serve(r, http.MethodPut, "/receipts/"+id, example(t, "target-receipt.json"), admin...); w.Code != http.StatusOK {
+		t.Errorf("replacing a generated ID: status = %d, want 200", w.Code)
+	}
+}
@@ -0,0 +1,157 @@
+package main
+
+import (
+	"crypto/hmac"
+	"encoding/base64"
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// errUserMismatch is returned for receipts sent with the userId of someone
+// other than the authenticated user.
+var errUserMismatch = errors.New("receipt belongs to another user")
+
+// userContextKey is the gin context key identifyUser stores the user ID under.
+const userContextKey = "userId"
+
+type UserReceipt struct {
+	ID     string `json:"id"`
+	Points int64  `json:"points"`
+}
+
+type UserReceiptsResponse struct {
+	UserID   string        `json:"userId"`
+	Receipts []UserReceipt `json:"receipts"`
+	Points   int64         `json:"points"`
+}
+
+type UserTokenRequest struct {
+	UserID string `json:"userId" binding:"required,max=100,nocontrol"`
+}
+
+type UserTokenResponse struct {
+	Token string `json:"token"`
+}
+
+// signUser returns a user token of the form payload.signature like
+// signPoints, where the payload is the user ID.
+func signUser(key []byte, userID string) string {
+	encoded := base64.RawURLEncoding.EncodeToString([]byte(userID))
+	return encoded + "." + base64.RawURLEncoding.EncodeToString(tokenSignature(key, tokenKindUser, encoded))
+}
+
+// verifyUser checks the signature of a token made by signUser and returns its
+// user ID, or errTokenInvalid.
+func verifyUser(key []byte, token string) (string, error) {
+	encoded, signature, ok := strings.Cut(token, ".")
+	if !ok {
+		return "", errTokenInvalid
+	}
+	provided, err := base64.RawURLEncoding.DecodeString(signature)
+	if err != nil || !hmac.Equal(provided, tokenSignature(key, tokenKindUser, encoded)) {
+		return "", errTokenInvalid
+	}
+	userID, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err != nil || len(userID) == 0 {
+		return "", errTokenInvalid
+	}
+	return string(userID), nil
+}
+
+// identifyUser authenticates requests carrying an X-User-Token header made by
+// signUser, see requestUser. Requests with an invalid token get a 401, those
+// without one are anonymous.
+func identifyUser(key string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		token := c.GetHeader("X-User-Token")
+		if token == "" {
+			c.Next()
+			return
+		}
+		userID, err := verifyUser([]byte(key), token)
+		if err != nil {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, errorBody(c, codeUserTokenRequired))
+			return
+		}
+		c.Set(userContextKey, userID)
+		c.Next()
+	}
+}
+
+// requestUser returns the ID of the user the request was authenticated as by
+// identifyUser, or "" for anonymous requests.
+func requestUser(c *gin.Context) string {
+	return c.GetString(userContextKey)
+}
+
+// claimReceipt files the receipt under the user its request was authenticated
+// as. With -user-key a receipt may only name the user sending it, so nobody
+// can file receipts under someone else's ID; receipts from anonymous requests
+// can't name a user at all. Without it user IDs can't be checked, and are
+// stored as sent.
+func (s *server) claimReceipt(receipt *Receipt, user string) error {
+	if s.cfg.UserKey == "" {
+		return nil
+	}
+	if receipt.UserID != "" && receipt.UserID != user {
+		return errUserMismatch
+	}
+	receipt.UserID = user
+	return nil
+}
+
+// visibleTo returns the receipts a listing may show to the user: those stored
+// under their ID, or without one for anonymous requests. Receipts of other
+// users are never listed.
+func visibleTo(receipts []StoredReceipt, user string) []StoredReceipt {
+	visible := receipts[:0:0]
+	for _, stored := range receipts {
+		if stored.Receipt.UserID == user {
+			visible = append(visible, stored)
+		}
+	}
+	return visible
+}
+
+// getUserReceipts lists the receipts of the authenticated user, oldest first,
+// and their summed points. The user in the path must be the one the
+// X-User-Token header authenticates.
+func (s *server) getUserReceipts(c *gin.Context) {
+	userID := requestUser(c)
+	if userID == "" {
+		c.JSON(http.StatusUnauthorized, errorBody(c, codeUserTokenRequired))
+		return
+	}
+	if c.Param("userId") != userID {
+		c.JSON(http.StatusForbidden, errorBody(c, codeUserMismatch))
+		return
+	}
+
+	receipts, err := s.store.List(c.Request.Context())
+	if err != nil {
+		storeFailed(c, err)
+		return
+	}
+
+	response := UserReceiptsResponse{UserID: userID, Receipts: []UserReceipt{}}
+	for _, stored := range visibleTo(receipts, userID) {
+		points := s.points(c.Request.Context(), stored)
+		response.Receipts = append(response.Receipts, UserReceipt{ID: stored.ID, Points: points})
+		response.Points += points
+	}
+
+	c.JSON(http.StatusOK, response)
+}
+
+// issueUserToken hands out the user token for a user ID.
+func (s *server) issueUserToken(c *gin.Context) {
+	var req UserTokenRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, errorBody(c, codeUserIDInvalid))
+		return
+	}
+	c.JSON(http.StatusOK, UserTokenResponse{Token: signUser([]byte(s.cfg.UserKey), req.UserID)})
+}
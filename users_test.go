@@ -0,0 +1,140 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestUsersCantSeeEachOthersReceipts(t *testing.T) {
+	const key = "secret"
+	r := newServer(testConfig(t, "-user-key", key), newMemoryStore()).router()
+	alice, bob := signUser([]byte(key), "alice"), signUser([]byte(key), "bob")
+
+	process := func(token, userID string) *http.Response {
+		receipt := example(t, "simple-receipt.json")
+		if userID != "" {
+			receipt = strings.Replace(receipt, "{", `{"userId": "`+userID+`",`, 1)
+		}
+		headers := []string{}
+		if token != "" {
+			headers = append(headers, "X-User-Token", token)
+		}
+		return serve(r, http.MethodPost, "/receipts/process", receipt, headers...).Result()
+	}
+	processes := []struct {
+		name   string
+		token  string
+		userID string
+		status int
+	}{
+		{"alice", alice, "", http.StatusOK},
+		{"alice naming herself", alice, "alice", http.StatusOK},
+		{"bob", bob, "", http.StatusOK},
+		{"alice posing as bob", alice, "bob", http.StatusForbidden},
+		{"anonymous posing as bob", "", "bob", http.StatusForbidden},
+		{"forged token", "YWxpY2U.Zm9yZ2Vk", "", http.StatusUnauthorized},
+		{"anonymous", "", "", http.StatusOK},
+	}
+	for _, tt := range processes {
+		if status := process(tt.token, tt.userID).StatusCode; status != tt.status {
+			t.Errorf("processing %s: status = %d, want %d", tt.name, status, tt.status)
+		}
+	}
+
+	listings := []struct {
+		name   string
+		path   string
+		token  string
+		status int
+		count  int
+	}{
+		{"alice's receipts", "/users/alice/receipts", alice, http.StatusOK, 2},
+		{"bob's receipts", "/users/bob/receipts", bob, http.StatusOK, 1},
+		{"bob's receipts as alice", "/users/bob/receipts", alice, http.StatusForbidden, 0},
+		{"bob's receipts anonymously", "/users/bob/receipts", "", http.StatusUnauthorized, 0},
+		{"list as alice", "/receipts", alice, http.StatusOK, 2},
+		{"list as bob", "/receipts", bob, http.StatusOK, 1},
+		{"list anonymously", "/receipts", "", http.StatusOK, 1},
+		{"export as bob", "/receipts/export", bob, http.StatusOK, 1},
+		{"export anonymously", "/receipts/export", "", http.StatusOK, 1},
+	}
+	for _, tt := range listings {
+		t.Run(tt.name, func(t *testing.T) {
+			headers := []string{}
+			if tt.token != "" {
+				headers = append(headers, "X-User-Token", tt.token)
+			}
+			w := serve(r, http.MethodGet, tt.path, "", headers...)
+			if w.Code != tt.status {
+				t.Fatalf("status = %d, want %d: %s", w.Code, tt.status, w.Body)
+			}
+			if w.Code != http.StatusOK {
+				return
+			}
+
+			count := 0
+			switch {
+			case strings.HasSuffix(tt.path, "/export"):
+				count = strings.Count(w.Body.String(), "\n")
+			case strings.HasPrefix(tt.path, "/users/"):
+				var response UserReceiptsResponse
+				json.Unmarshal(w.Body.Bytes(), &response)
+				count = len(response.Receipts)
+			default:
+				var response struct{ Receipts []ReceiptSummary }
+				json.Unmarshal(w.Body.Bytes(), &response)
+				count = len(response.Receipts)
+			}
+			if count != tt.count {
+				t.Errorf("listed %d receipts, want %d: %s", count, tt.count, w.Body)
+			}
+		})
+	}
+}
+
+func TestIssueUserToken(t *testing.T) {
+	r := newServer(testConfig(t, "-user-key", "secret", "-admin-token", "admin"), newMemoryStore()).router()
+
+	w := serve(r, http.MethodPost, "/admin/user-tokens", `{"userId": "alice"}`, "Authorization", "Bearer admin")
+	var response UserTokenResponse
+	if err := json.Unmarshal(w.Body.Bytes(), &response); err != nil || w.Code != http.StatusOK {
+		t.Fatalf("issuing: %d %s", w.Code, w.Body)
+	}
+	if user, err := verifyUser([]byte("secret"), response.Token); err != nil || user != "alice" {
+		t.Errorf("token is for %q, %v", user, err)
+	}
+	if w := serve(r, http.MethodPost, "/admin/user-tokens", `{"userId": "alice"}`); w.Code != http.StatusUnauthorized {
+		t.Errorf("without admin token: status = %d, want 401", w.Code)
+	}
+}
+
+func TestTokenKindsDontMix(t *testing.T) {
+	const key = "shared-secret"
+	r := newServer(testConfig(t, "-user-key", key, "-token-key", key), newMemoryStore()).router()
+	id := process(t, r, example(t, "simple-receipt.json"))
+	pointsToken := pointsOf(t, r, id).Token
+	userToken := signUser([]byte(key), "alice")
+
+	tests := []struct {
+		name   string
+		method string
+		path   string
+		body   string
+		header []string
+		status int
+	}{
+		{"user token as a user token", http.MethodGet, "/users/alice/receipts", "", []string{"X-User-Token", userToken}, http.StatusOK},
+		{"points token as a user token", http.MethodGet, "/users/alice/receipts", "", []string{"X-User-Token", pointsToken}, http.StatusUnauthorized},
+		{"points token verified", http.MethodPost, "/verify", `{"token": "` + pointsToken + `"}`, nil, http.StatusOK},
+		{"user token verified", http.MethodPost, "/verify", `{"token": "` + userToken + `"}`, nil, http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if w := serve(r, tt.method, tt.path, tt.body, tt.header...); w.Code != tt.status {
+				t.Errorf("status = %d, want %d: %s", w.Code, tt.status, w.Body)
+			}
+		})
+	}
+}
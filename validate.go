@@ -0,0 +1,244 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/gin-gonic/gin/binding"
+	"github.com/go-playground/validator/v10"
+	"github.com/shopspring/decimal"
+)
+
+// errRetailerDenied rejects receipts from a retailer on the -deny-retailer list.
+var errRetailerDenied = errors.New("retailer is denied")
+
+// registerValidators adds the receipt specific checks to gin's validator.
+func registerValidators() {
+	if v, ok := binding.Validator.Engine().(*validator.Validate); ok {
+		// Report fields by their JSON names, as clients know them.
+		v.RegisterTagNameFunc(func(field reflect.StructField) string {
+			name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+			return name
+		})
+		v.RegisterStructValidation(validateReceipt, Receipt{})
+		v.RegisterValidation("nocontrol", validateNoControl)
+		v.RegisterValidation("notblank", validateNotBlank)
+	}
+}
+
+// validateNoControl is the "nocontrol" tag, rejecting control characters
+// such as null bytes or newlines that could corrupt logs. Tabs are allowed.
+func validateNoControl(fl validator.FieldLevel) bool {
+	return !strings.ContainsFunc(fl.Field().String(), func(r rune) bool {
+		return unicode.IsControl(r) && r != '\t'
+	})
+}
+
+// validateNotBlank is the "notblank" tag, rejecting strings of only spaces.
+func validateNotBlank(fl validator.FieldLevel) bool {
+	return strings.TrimSpace(fl.Field().String()) != ""
+}
+
+// validateReceipt runs the checks that involve more than one receipt field.
+func validateReceipt(sl validator.StructLevel) {
+	receipt := sl.Current().Interface().(Receipt)
+
+	validateReceiptMoney(sl, receipt)
+	validateItemCount(sl, receipt)
+
+	// The date and time are checked separately, so errors name the wrong one.
+	if receipt.PurchaseDate != "" {
+		if _, err := time.Parse("2006-01-02", receipt.PurchaseDate); err != nil {
+			sl.ReportError(receipt.PurchaseDate, "purchaseDate", "PurchaseDate", "date", "")
+		}
+	}
+	if receipt.PurchaseTime != "" {
+		if _, err := time.Parse("15:04", receipt.PurchaseTime); err != nil {
+			sl.ReportError(receipt.PurchaseTime, "purchaseTime", "PurchaseTime", "time", "")
+		}
+	}
+
+	if receipt.ImageBase64 != "" {
+		if _, _, err := decodeImage(receipt.ImageBase64); err != nil {
+			sl.ReportError(receipt.ImageBase64, "imageBase64", "ImageBase64", "image", err.Error())
+		}
+	}
+}
+
+// validateReceiptMoney checks that the currency is supported and that the
+// total and item prices are written with the currency's decimal places.
+func validateReceiptMoney(sl validator.StructLevel, receipt Receipt) {
+	places, ok := minorUnits(receipt.Currency)
+	if !ok {
+		sl.ReportError(receipt.Currency, "currency", "Currency", "currency", "")
+		return
+	}
+
+	if !isMoney(receipt.Total, places) {
+		sl.ReportError(receipt.Total, "total", "Total", "money", "")
+	}
+	if receipt.Discount != "" && !isMoney(receipt.Discount, places) {
+		sl.ReportError(receipt.Discount, "discount", "Discount", "money", "")
+	}
+	for i, item := range receipt.Items {
+		if !isMoney(item.Price, places) {
+			sl.ReportError(item.Price, fmt.Sprintf("items[%d].price", i), fmt.Sprintf("Items[%d].Price", i), "money", "")
+		}
+	}
+}
+
+// validateItemCount checks the optional itemCount against the items, which
+// catches receipts that were truncated on import.
+func validateItemCount(sl validator.StructLevel, receipt Receipt) {
+	if receipt.ItemCount != nil && *receipt.ItemCount != len(receipt.Items) {
+		sl.ReportError(receipt.ItemCount, "itemCount", "ItemCount", "itemcount", "")
+	}
+}
+
+// fieldError reports a receipt field that fails one of the configurable checks.
+type fieldError struct {
+	Field  string
+	Reason string
+}
+
+func (e *fieldError) Error() string {
+	return e.Field + ": " + e.Reason
+}
+
+// validationReasons describe the failed validation tags to clients.
+var validationReasons = map[string]string{
+	"required":  "is required",
+	"min":       "must not be empty",
+	"notblank":  "must not be blank",
+	"max":       "is too long",
+	"nocontrol": "must not contain control characters",
+	"money":     "must be an amount like 6.49 in the receipt's currency",
+	"currency":  "is not a supported currency",
+	"image":     "must be a base64 encoded image of up to 1 MiB",
+	"itemcount": "does not match the number of items",
+	"date":      "must be a date like 2022-01-01",
+	"time":      "must be a 24-hour time like 13:01",
+}
+
+// validationFieldError names the first field of a failed struct validation,
+// e.g. "items[0].price", with the reason it failed.
+func validationFieldError(errs validator.ValidationErrors) *fieldError {
+	first := errs[0]
+	_, field, _ := strings.Cut(first.Namespace(), ".")
+	reason, ok := validationReasons[first.Tag()]
+	if !ok {
+		reason = "fails " + first.Tag()
+	}
+	return &fieldError{Field: field, Reason: reason}
+}
+
+// Item order policies for Config.ItemOrder.
+const (
+	itemOrderAny          = ""
+	itemOrderSorted       = "sorted"
+	itemOrderUnique       = "unique"
+	itemOrderSortedUnique = "sorted-unique"
+)
+
+// validateItemOrder checks the item descriptions against the policy. Descriptions
+// are compared trimmed and case-insensitively.
+func validateItemOrder(items []Item, policy string) error {
+	if policy == itemOrderAny {
+		return nil
+	}
+
+	sorted := policy == itemOrderSorted || policy == itemOrderSortedUnique
+	unique := policy == itemOrderUnique || policy == itemOrderSortedUnique
+
+	seen := make(map[string]bool, len(items))
+	previous := ""
+	for i, item := range items {
+		desc := strings.ToLower(strings.TrimSpace(item.ShortDescription))
+		if unique && seen[desc] {
+			return &fieldError{Field: fmt.Sprintf("items[%d]", i), Reason: "duplicate item description"}
+		}
+		if sorted && i > 0 && desc < previous {
+			return &fieldError{Field: fmt.Sprintf("items[%d]", i), Reason: "items are not sorted by description"}
+		}
+		seen[desc] = true
+		previous = desc
+	}
+	return nil
+}
+
+// semanticError marks a well-formed receipt that breaks one of the rules the
+// server is configured with, such as the item sum check or a denied retailer.
+type semanticError struct {
+	err error
+}
+
+func (e *semanticError) Error() string { return e.err.Error() }
+
+func (e *semanticError) Unwrap() error { return e.err }
+
+// validateItemSum rejects receipts whose total differs from the sum of the
+// item prices, less the discount, by more than tolerance minor units of the
+// currency, e.g. cents.
+func validateItemSum(receipt Receipt, tolerance int64) error {
+	total, err := parseMoney(receipt.Total)
+	if err != nil {
+		return nil
+	}
+	discount := decimal.Zero
+	if receipt.Discount != "" {
+		if discount, err = parseMoney(receipt.Discount); err != nil {
+			return nil
+		}
+	}
+	sum := discount.Neg()
+	for _, item := range receipt.Items {
+		price, err := parseMoney(item.Price)
+		if err != nil {
+			return nil
+		}
+		sum = sum.Add(price)
+	}
+
+	places, _ := minorUnits(receipt.Currency)
+	if total.Sub(sum).Abs().GreaterThan(decimal.New(tolerance, -places)) {
+		reason := "total differs from the sum of the item prices, " + sum.StringFixed(places)
+		if !discount.IsZero() {
+			reason = "total differs from the sum of the item prices less the discount, " + sum.StringFixed(places)
+		}
+		return &fieldError{Field: "total", Reason: reason}
+	}
+	return nil
+}
+
+// validateLimits rejects item prices above maxItemPrice and totals above
+// maxTotal. A zero limit is not checked.
+func validateLimits(receipt Receipt, maxItemPrice, maxTotal decimal.Decimal) error {
+	if !maxTotal.IsZero() {
+		if total, err := parseMoney(receipt.Total); err == nil && total.GreaterThan(maxTotal) {
+			return &fieldError{Field: "total", Reason: "total exceeds the maximum of " + maxTotal.String()}
+		}
+	}
+	if !maxItemPrice.IsZero() {
+		for i, item := range receipt.Items {
+			if price, err := parseMoney(item.Price); err == nil && price.GreaterThan(maxItemPrice) {
+				return &fieldError{Field: fmt.Sprintf("items[%d].price", i), Reason: "price exceeds the maximum of " + maxItemPrice.String()}
+			}
+		}
+	}
+	return nil
+}
+
+// validateMinTotal rejects totals below minTotal. A zero minimum is not checked.
+func validateMinTotal(receipt Receipt, minTotal decimal.Decimal) error {
+	if minTotal.IsZero() {
+		return nil
+	}
+	if total, err := parseMoney(receipt.Total); err == nil && total.LessThan(minTotal) {
+		return &fieldError{Field: "total", Reason: "total is below the minimum of " + minTotal.String()}
+	}
+	return nil
+}
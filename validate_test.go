@@ -0,0 +1,491 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestItemCount(t *testing.T) {
+	// The simple receipt has one item.
+	receipt := example(t, "simple-receipt.json")
+	tests := []struct {
+		name      string
+		itemCount string
+		status    int
+	}{
+		{"absent", "", http.StatusOK},
+		{"matching", `"itemCount": 1,`, http.StatusOK},
+		{"more", `"itemCount": 2,`, http.StatusBadRequest},
+		{"none", `"itemCount": 0,`, http.StatusBadRequest},
+		{"negative", `"itemCount": -1,`, http.StatusBadRequest},
+		{"not a number", `"itemCount": "1",`, http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := newServer(testConfig(t), newMemoryStore()).router()
+			w := serve(r, http.MethodPost, "/receipts/process", strings.Replace(receipt, "{", "{"+tt.itemCount, 1))
+			if w.Code != tt.status {
+				t.Errorf("status = %d, want %d: %s", w.Code, tt.status, w.Body)
+			}
+			if tt.status == http.StatusBadRequest && tt.name != "not a number" && !strings.Contains(w.Body.String(), "itemCount") {
+				t.Errorf("the error doesn't name itemCount: %s", w.Body)
+			}
+		})
+	}
+}
+
+func TestControlCharacters(t *testing.T) {
+	tests := []struct {
+		name     string
+		retailer string
+		item     string
+		field    string
+	}{
+		{"plain", "Target", "Pepsi", ""},
+		{"tab", `Target\tStore`, `Pepsi\t12-oz`, ""},
+		{"unicode", "Café Müller", "Crème brûlée", ""},
+		{"null in the retailer", `Tar\u0000get`, "Pepsi", "retailer"},
+		{"newline in the retailer", `Target\nINJECTED log line`, "Pepsi", "retailer"},
+		{"carriage return in an item", "Target", `Pepsi\r`, "items[0].shortDescription"},
+		{"null in an item", "Target", `Pep\u0000si`, "items[0].shortDescription"},
+		{"escape in an item", "Target", `\u001b[31mPepsi`, "items[0].shortDescription"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			receipt := `{"retailer": "` + tt.retailer + `", "purchaseDate": "2022-01-02", "purchaseTime": "13:13", "total": "1.25",
+				"items": [{"shortDescription": "` + tt.item + `", "price": "1.25"}]}`
+			r := newServer(testConfig(t), newMemoryStore()).router()
+			w := serve(r, http.MethodPost, "/receipts/process", receipt)
+			if tt.field == "" {
+				if w.Code != http.StatusOK {
+					t.Errorf("status = %d, want 200: %s", w.Code, w.Body)
+				}
+				return
+			}
+			if w.Code != http.StatusBadRequest || !strings.Contains(w.Body.String(), tt.field) || !strings.Contains(w.Body.String(), "control characters") {
+				t.Errorf("status = %d, want 400 for %s: %s", w.Code, tt.field, w.Body)
+			}
+		})
+	}
+}
+
+func TestValidateItemOrder(t *testing.T) {
+	items := func(descriptions ...string) []Item {
+		list := []Item{}
+		for _, d := range descriptions {
+			list = append(list, Item{ShortDescription: d, Price: "1.00"})
+		}
+		return list
+	}
+	tests := []struct {
+		name   string
+		policy string
+		items  []Item
+		// field is the item reported, "" if the items are accepted.
+		field string
+	}{
+		{"any order", itemOrderAny, items("b", "a", "a"), ""},
+		{"sorted", itemOrderSorted, items("apple", "Banana", " cherry"), ""},
+		{"sorted with duplicates", itemOrderSorted, items("a", "a", "b"), ""},
+		{"unsorted", itemOrderSorted, items("a", "c", "b"), "items[2]"},
+		{"single item", itemOrderSortedUnique, items("z"), ""},
+		{"unique", itemOrderUnique, items("b", "a"), ""},
+		{"duplicate", itemOrderUnique, items("a", "b", " A "), "items[2]"},
+		{"sorted and unique", itemOrderSortedUnique, items("a", "b", "c"), ""},
+		{"sorted but duplicate", itemOrderSortedUnique, items("a", "b", "b"), "items[2]"},
+		{"unique but unsorted", itemOrderSortedUnique, items("b", "a"), "items[1]"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validateItemOrder(tt.items, tt.policy)
+			var fe *fieldError
+			switch {
+			case tt.field == "" && err != nil:
+				t.Errorf("rejected: %v", err)
+			case tt.field != "" && (!errors.As(err, &fe) || fe.Field != tt.field):
+				t.Errorf("error = %v, want one for %s", err, tt.field)
+			}
+		})
+	}
+}
+
+func TestItemOrderFlag(t *testing.T) {
+	// The items of the target receipt aren't sorted.
+	receipt := example(t, "target-receipt.json")
+	for policy, status := range map[string]int{"": http.StatusOK, "unique": http.StatusOK, "sorted": http.StatusBadRequest} {
+		t.Run(policy, func(t *testing.T) {
+			r := newServer(testConfig(t, "-item-order", policy), newMemoryStore()).router()
+			if w := serve(r, http.MethodPost, "/receipts/process", receipt); w.Code != status {
+				t.Errorf("status = %d, want %d: %s", w.Code, status, w.Body)
+			}
+		})
+	}
+}
+
+// receiptWith returns a JSON receipt with the total and items priced at prices.
+func receiptWith(total string, prices ...string) string {
+	items := []string{}
+	for _, price := range prices {
+		items = append(items, `{"shortDescription": "ab", "price": "`+price+`"}`)
+	}
+	return `{"retailer": "Target", "purchaseDate": "2022-01-02", "purchaseTime": "13:13", "total": "` + total + `", "items": [` + strings.Join(items, ", ") + `]}`
+}
+
+func TestMaxItemPriceAndTotal(t *testing.T) {
+	tests := []struct {
+		name    string
+		args    []string
+		receipt string
+		status  int
+		field   string
+	}{
+		{"no limits", nil, receiptWith("999999999.99", "999999999.99"), http.StatusOK, ""},
+		{"price at the maximum", []string{"-max-item-price", "100.00"}, receiptWith("150.00", "100.00", "50.00"), http.StatusOK, ""},
+		{"price a cent above", []string{"-max-item-price", "100.00"}, receiptWith("150.01", "50.00", "100.01"), http.StatusBadRequest, "items[1].price"},
+		{"total at the maximum", []string{"-max-total", "200"}, receiptWith("200.00", "100.00", "100.00"), http.StatusOK, ""},
+		{"total a cent above", []string{"-max-total", "200"}, receiptWith("200.01", "100.00", "100.01"), http.StatusBadRequest, "total"},
+		{"total above and 422", []string{"-max-total", "200", "-unprocessable-status"}, receiptWith("200.01", "200.01"), http.StatusUnprocessableEntity, "total"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := newServer(testConfig(t, tt.args...), newMemoryStore()).router()
+			w := serve(r, http.MethodPost, "/receipts/process", tt.receipt)
+			if w.Code != tt.status {
+				t.Fatalf("status = %d, want %d: %s", w.Code, tt.status, w.Body)
+			}
+			if tt.field != "" && !strings.Contains(w.Body.String(), tt.field) {
+				t.Errorf("the error doesn't name %s: %s", tt.field, w.Body)
+			}
+		})
+	}
+
+	for _, value := range []string{"-1", "abc"} {
+		if _, err := parseConfig([]string{"-max-item-price", value}); err == nil {
+			t.Errorf("-max-item-price %s was accepted", value)
+		}
+	}
+}
+
+func TestDenyRetailer(t *testing.T) {
+	tests := []struct {
+		name     string
+		args     []string
+		retailer string
+		status   int
+	}{
+		{"allowed", nil, "Shady Deals", http.StatusOK},
+		{"denied", []string{"-deny-retailer", "Shady Deals"}, "Shady Deals", http.StatusForbidden},
+		{"denied in another case", []string{"-deny-retailer", "shady deals"}, " SHADY DEALS ", http.StatusForbidden},
+		{"other retailer", []string{"-deny-retailer", "Shady Deals"}, "Target", http.StatusOK},
+		{"similar name", []string{"-deny-retailer", "Shady Deals"}, "Shady Deals Outlet", http.StatusOK},
+		{"denied once normalized", []string{"-deny-retailer", "shady deals", "-normalize-retailer", "whitespace"}, "Shady   Deals", http.StatusForbidden},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store := newMemoryStore()
+			r := newServer(testConfig(t, tt.args...), store).router()
+			receipt := strings.Replace(receiptWith("1.00", "1.00"), "Target", tt.retailer, 1)
+			w := serve(r, http.MethodPost, "/receipts/process", receipt)
+			if w.Code != tt.status {
+				t.Errorf("status = %d, want %d: %s", w.Code, tt.status, w.Body)
+			}
+			if stored, _ := store.List(context.Background()); (len(stored) == 1) != (tt.status == http.StatusOK) {
+				t.Errorf("%d receipts stored", len(stored))
+			}
+		})
+	}
+}
+
+func TestMinTotal(t *testing.T) {
+	tests := []struct {
+		name   string
+		args   []string
+		total  string
+		status int
+		// zero is whether the accepted receipt gets zero points.
+		zero bool
+	}{
+		{"no minimum", nil, "0.01", http.StatusOK, false},
+		{"at the minimum", []string{"-min-total", "5.00"}, "5.00", http.StatusOK, false},
+		{"a cent below", []string{"-min-total", "5.00"}, "4.99", http.StatusBadRequest, false},
+		{"above the minimum", []string{"-min-total", "5"}, "5.01", http.StatusOK, false},
+		{"at the minimum with zero points", []string{"-min-total", "5.00", "-below-min-total", "zero"}, "5.00", http.StatusOK, false},
+		{"a cent below with zero points", []string{"-min-total", "5.00", "-below-min-total", "zero"}, "4.99", http.StatusOK, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := newServer(testConfig(t, tt.args...), newMemoryStore()).router()
+			w := serve(r, http.MethodPost, "/receipts/process", receiptWith(tt.total, tt.total))
+			if w.Code != tt.status {
+				t.Fatalf("status = %d, want %d: %s", w.Code, tt.status, w.Body)
+			}
+			if w.Code != http.StatusOK {
+				return
+			}
+			var receipt ReceiptResponse
+			if err := json.Unmarshal(w.Body.Bytes(), &receipt); err != nil {
+				t.Fatal(err)
+			}
+			if points := pointsOf(t, r, receipt.ID); (points.Points == 0) != tt.zero {
+				t.Errorf("points = %d", points.Points)
+			}
+		})
+	}
+
+	if _, err := parseConfig([]string{"-below-min-total", "ignore"}); err == nil {
+		t.Error("-below-min-total ignore was accepted")
+	}
+}
+
+func TestPurchaseDateAndTime(t *testing.T) {
+	tests := []struct {
+		name       string
+		date, time string
+		// field is the field the error names, "" if the receipt is valid.
+		field string
+	}{
+		{"valid", "2022-01-01", "13:01", ""},
+		{"midnight", "2022-01-01", "00:00", ""},
+		{"leap day", "2024-02-29", "23:59", ""},
+		{"invalid time", "2022-01-01", "25:00", "purchaseTime"},
+		{"minutes out of range", "2022-01-01", "13:60", "purchaseTime"},
+		{"12-hour time", "2022-01-01", "01:01 PM", "purchaseTime"},
+		{"time with seconds", "2022-01-01", "13:01:00", "purchaseTime"},
+		{"invalid date", "2022-02-30", "13:01", "purchaseDate"},
+		{"not a leap year", "2023-02-29", "13:01", "purchaseDate"},
+		{"date in another format", "01/02/2022", "13:01", "purchaseDate"},
+	}
+	r := newServer(testConfig(t), newMemoryStore()).router()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			receipt := `{"retailer": "Target", "purchaseDate": "` + tt.date + `", "purchaseTime": "` + tt.time + `", "total": "1.25", "items": [{"shortDescription": "Pepsi", "price": "1.25"}]}`
+			w := serve(r, http.MethodPost, "/receipts/process", receipt)
+			if tt.field == "" {
+				if w.Code != http.StatusOK {
+					t.Errorf("status = %d: %s", w.Code, w.Body)
+				}
+				return
+			}
+			var body struct {
+				Field string `json:"field"`
+			}
+			if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil || w.Code != http.StatusBadRequest {
+				t.Fatalf("status = %d: %s", w.Code, w.Body)
+			}
+			if body.Field != tt.field {
+				t.Errorf("error names %q, want %q: %s", body.Field, tt.field, w.Body)
+			}
+		})
+	}
+}
+
+func TestItemSumTolerance(t *testing.T) {
+	tests := []struct {
+		name    string
+		args    []string
+		receipt string
+		status  int
+	}{
+		{"not checked", nil, receiptWith("2.51", "1.25", "1.25"), http.StatusOK},
+		{"exact", []string{"-check-item-sum"}, receiptWith("2.50", "1.25", "1.25"), http.StatusOK},
+		{"a cent off", []string{"-check-item-sum"}, receiptWith("2.51", "1.25", "1.25"), http.StatusBadRequest},
+		{"a cent over within tolerance", []string{"-check-item-sum", "-item-sum-tolerance", "1"}, receiptWith("2.51", "1.25", "1.25"), http.StatusOK},
+		{"a cent under within tolerance", []string{"-check-item-sum", "-item-sum-tolerance", "1"}, receiptWith("2.49", "1.25", "1.25"), http.StatusOK},
+		{"two cents outside tolerance", []string{"-check-item-sum", "-item-sum-tolerance", "1"}, receiptWith("2.52", "1.25", "1.25"), http.StatusBadRequest},
+		{"gross mismatch", []string{"-check-item-sum", "-item-sum-tolerance", "5"}, receiptWith("3.50", "1.25", "1.25"), http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := newServer(testConfig(t, tt.args...), newMemoryStore()).router()
+			w := serve(r, http.MethodPost, "/receipts/process", tt.receipt)
+			if w.Code != tt.status {
+				t.Fatalf("status = %d, want %d: %s", w.Code, tt.status, w.Body)
+			}
+			if w.Code != http.StatusOK && !strings.Contains(w.Body.String(), `"field":"total"`) {
+				t.Errorf("the error doesn't name the total: %s", w.Body)
+			}
+		})
+	}
+
+	// The tolerance is in the currency's minor unit, whole yen for JPY.
+	receipt := `{"retailer": "Target", "purchaseDate": "2022-01-02", "purchaseTime": "13:13", "currency": "JPY", "total": "1201", "items": [{"shortDescription": "ab", "price": "1200"}]}`
+	if err := validateItemSum(mustDecode(t, receipt), 1); err != nil {
+		t.Errorf("one yen off: %v", err)
+	}
+	if err := validateItemSum(mustDecode(t, receipt), 0); err == nil {
+		t.Error("one yen off was accepted without a tolerance")
+	}
+
+	for _, value := range []string{"-1", "0.5"} {
+		if _, err := parseConfig([]string{"-item-sum-tolerance", value}); err == nil {
+			t.Errorf("-item-sum-tolerance %s was accepted", value)
+		}
+	}
+}
+
+// mustDecode decodes a receipt like processing it does by default.
+func mustDecode(t *testing.T, receipt string) Receipt {
+	t.Helper()
+	decoded, err := decodeReceipt(strings.NewReader(receipt), testConfig(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return decoded
+}
+
+func TestEmptyItems(t *testing.T) {
+	empty := `{"retailer": "Target", "purchaseDate": "2022-01-02", "purchaseTime": "13:13", "total": "1.00", "items": []}`
+	missing := `{"retailer": "Target", "purchaseDate": "2022-01-02", "purchaseTime": "13:13", "total": "1.00"}`
+	tests := []struct {
+		name    string
+		args    []string
+		receipt string
+		status  int
+	}{
+		{"empty by default", nil, empty, http.StatusBadRequest},
+		{"missing by default", nil, missing, http.StatusBadRequest},
+		{"empty allowed", []string{"-allow-empty-items"}, empty, http.StatusOK},
+		{"missing while empty allowed", []string{"-allow-empty-items"}, missing, http.StatusBadRequest},
+		{"one item either way", []string{"-allow-empty-items"}, receiptWith("1.00", "1.00"), http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := newServer(testConfig(t, tt.args...), newMemoryStore()).router()
+			w := serve(r, http.MethodPost, "/receipts/process", tt.receipt)
+			if w.Code != tt.status {
+				t.Fatalf("status = %d, want %d: %s", w.Code, tt.status, w.Body)
+			}
+			if w.Code != http.StatusOK {
+				if !strings.Contains(w.Body.String(), "items") {
+					t.Errorf("the error doesn't name the items: %s", w.Body)
+				}
+				return
+			}
+			var receipt ReceiptResponse
+			if err := json.Unmarshal(w.Body.Bytes(), &receipt); err != nil {
+				t.Fatal(err)
+			}
+			if points := pointsOf(t, r, receipt.ID); points.Points != 81 {
+				t.Errorf("points = %d, want 81", points.Points)
+			}
+		})
+	}
+
+	// No items award no points for pairs.
+	receipt := Receipt{Retailer: "Target", PurchaseDate: "2022-01-02", PurchaseTime: "13:13", Total: "1.00", Items: []Item{}}
+	if got := awarded(t, receipt, RulesConfig{})["itemPairs"]; got != 0 {
+		t.Errorf("itemPairs = %d for no items", got)
+	}
+}
+
+func TestUnprocessableStatus(t *testing.T) {
+	rules := []string{"-check-item-sum", "-max-total", "100", "-min-total", "1.00", "-deny-retailer", "Shady Deals"}
+	tests := []struct {
+		name    string
+		extra   []string
+		receipt string
+		// status is with -unprocessable-status, legacy without it.
+		status, legacy int
+	}{
+		{"valid", nil, receiptWith("2.00", "1.00", "1.00"), http.StatusOK, http.StatusOK},
+		{"not JSON", nil, "retailer,total", http.StatusBadRequest, http.StatusBadRequest},
+		{"missing field", nil, `{"retailer": "Target"}`, http.StatusBadRequest, http.StatusBadRequest},
+		{"malformed total", nil, receiptWith("2.0", "1.00", "1.00"), http.StatusBadRequest, http.StatusBadRequest},
+		{"invalid date", nil, strings.Replace(receiptWith("2.00", "1.00", "1.00"), "2022-01-02", "2022-13-02", 1), http.StatusBadRequest, http.StatusBadRequest},
+		{"sum mismatch", nil, receiptWith("2.50", "1.00", "1.00"), http.StatusUnprocessableEntity, http.StatusBadRequest},
+		{"above the maximum", nil, receiptWith("200.00", "100.00", "100.00"), http.StatusUnprocessableEntity, http.StatusBadRequest},
+		{"below the minimum", nil, receiptWith("0.50", "0.50"), http.StatusUnprocessableEntity, http.StatusBadRequest},
+		{"duplicate items", []string{"-item-order", "unique"}, receiptWith("2.00", "1.00", "1.00"), http.StatusUnprocessableEntity, http.StatusBadRequest},
+		{"denied retailer", nil, strings.Replace(receiptWith("2.00", "1.00", "1.00"), "Target", "Shady Deals", 1), http.StatusForbidden, http.StatusForbidden},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for _, unprocessable := range []bool{false, true} {
+				args, want := slices.Concat(rules, tt.extra), tt.legacy
+				if unprocessable {
+					args, want = append(args, "-unprocessable-status"), tt.status
+				}
+				r := newServer(testConfig(t, args...), newMemoryStore()).router()
+				if w := serve(r, http.MethodPost, "/receipts/process", tt.receipt); w.Code != want {
+					t.Errorf("unprocessable status %v: status = %d, want %d: %s", unprocessable, w.Code, want, w.Body)
+				}
+			}
+		})
+	}
+}
+
+func TestDiscount(t *testing.T) {
+	withDiscount := func(discount, total string, prices ...string) string {
+		return strings.Replace(receiptWith(total, prices...), "{", `{"discount": "`+discount+`", `, 1)
+	}
+	tests := []struct {
+		name    string
+		args    []string
+		receipt string
+		status  int
+		reason  string
+	}{
+		{"items above the total", []string{"-check-item-sum"}, receiptWith("2.00", "1.25", "1.25"), http.StatusBadRequest, "sum of the item prices, 2.50"},
+		{"discount reconciling the sum", []string{"-check-item-sum"}, withDiscount("0.50", "2.00", "1.25", "1.25"), http.StatusOK, ""},
+		{"discount too small", []string{"-check-item-sum"}, withDiscount("0.25", "2.00", "1.25", "1.25"), http.StatusBadRequest, "less the discount, 2.25"},
+		{"discount too small within tolerance", []string{"-check-item-sum", "-item-sum-tolerance", "25"}, withDiscount("0.25", "2.00", "1.25", "1.25"), http.StatusOK, ""},
+		{"zero discount", []string{"-check-item-sum"}, withDiscount("0.00", "2.50", "1.25", "1.25"), http.StatusOK, ""},
+		{"discount not checked", nil, withDiscount("9.00", "2.00", "1.25", "1.25"), http.StatusOK, ""},
+		{"invalid discount", nil, withDiscount("half", "2.00", "1.25", "1.25"), http.StatusBadRequest, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := newServer(testConfig(t, tt.args...), newMemoryStore()).router()
+			w := serve(r, http.MethodPost, "/receipts/process", tt.receipt)
+			if w.Code != tt.status {
+				t.Fatalf("status = %d, want %d: %s", w.Code, tt.status, w.Body)
+			}
+			if !strings.Contains(w.Body.String(), tt.reason) {
+				t.Errorf("error = %s, want it to mention %q", w.Body, tt.reason)
+			}
+		})
+	}
+}
+
+func TestValidateEndpoint(t *testing.T) {
+	tests := []struct {
+		name    string
+		args    []string
+		receipt string
+		status  int
+		field   string
+	}{
+		{"target receipt", nil, example(t, "target-receipt.json"), http.StatusOK, ""},
+		{"M&M receipt", nil, example(t, "M&M-receipt.json"), http.StatusOK, ""},
+		{"missing retailer", nil, strings.Replace(receiptWith("1.00", "1.00"), `"retailer": "Target", `, "", 1), http.StatusBadRequest, "retailer"},
+		{"invalid total", nil, receiptWith("1.0", "1.00"), http.StatusBadRequest, "total"},
+		{"invalid price", nil, receiptWith("1.00", "1.00", "one"), http.StatusBadRequest, "items[1].price"},
+		{"sum not checked", nil, receiptWith("3.00", "1.00"), http.StatusOK, ""},
+		{"sum checked", []string{"-check-item-sum"}, receiptWith("3.00", "1.00"), http.StatusBadRequest, "total"},
+		{"malformed JSON", nil, `{"retailer": `, http.StatusBadRequest, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store := newMemoryStore()
+			r := newServer(testConfig(t, tt.args...), store).router()
+			w := serve(r, http.MethodPost, "/receipts/validate", tt.receipt)
+			if w.Code != tt.status {
+				t.Fatalf("status = %d, want %d: %s", w.Code, tt.status, w.Body)
+			}
+			if tt.status == http.StatusOK && strings.TrimSpace(w.Body.String()) != `{"valid":true}` {
+				t.Errorf("body = %s, want {\"valid\":true}", w.Body)
+			}
+			if tt.field != "" && !strings.Contains(w.Body.String(), `"field":"`+tt.field+`"`) {
+				t.Errorf("the error doesn't name %s: %s", tt.field, w.Body)
+			}
+			if receipts, _ := store.List(context.Background()); len(receipts) != 0 {
+				t.Errorf("%d receipts were stored", len(receipts))
+			}
+		})
+	}
+}
@@ -0,0 +1,41 @@
+package main
+
+import (
+	"context"
+	"sync"
+)
+
+// warmCache scores every stored receipt whose points aren't cached yet, so
+// the first points requests after a restart don't have to. The receipts are
+// scored by the given number of workers in parallel. It returns how many
+// receipts were scored.
+func (s *server) warmCache(ctx context.Context, workers int) (int, error) {
+	receipts, err := s.store.List(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	pending := make(chan StoredReceipt)
+	var wg sync.WaitGroup
+	for range max(workers, 1) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for stored := range pending {
+				s.points(ctx, stored)
+			}
+		}()
+	}
+
+	scored := 0
+	for _, stored := range receipts {
+		if stored.Scored {
+			continue
+		}
+		pending <- stored
+		scored++
+	}
+	close(pending)
+	wg.Wait()
+	return scored, nil
+}
@@ -0,0 +1,74 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+)
+
+func TestWarmCache(t *testing.T) {
+	want := map[string]int64{"target": 28, "mm": 109, "simple": 31}
+	names := map[string]string{"target": "target-receipt.json", "mm": "M&M-receipt.json", "simple": "simple-receipt.json"}
+
+	tests := []struct {
+		name    string
+		workers int
+		// cached are receipts already scored when the file is loaded.
+		cached []string
+	}{
+		{"one worker", 1, nil},
+		{"more workers than receipts", 8, nil},
+		{"partly cached", 2, []string{"mm"}},
+		{"no workers", 0, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			path := t.TempDir() + "/receipts.json"
+			cfg := testConfig(t)
+			version := cfg.Rules.version()
+
+			var seeded []StoredReceipt
+			for id, name := range names {
+				var receipt Receipt
+				if err := json.Unmarshal([]byte(example(t, name)), &receipt); err != nil {
+					t.Fatal(err)
+				}
+				seeded = append(seeded, StoredReceipt{ID: id, Receipt: receipt, Version: 1, CreatedAt: time.Now()})
+			}
+			file, err := openFileStore(path, version)
+			if err != nil {
+				t.Fatal(err)
+			}
+			seedStored(t, file, seeded)
+			for _, id := range tt.cached {
+				if err := file.SetPoints(ctx, id, want[id]); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			loaded, err := openFileStore(path, version)
+			if err != nil {
+				t.Fatal(err)
+			}
+			scored, err := newServer(cfg, loaded).warmCache(ctx, tt.workers)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if scored != len(names)-len(tt.cached) {
+				t.Errorf("scored %d receipts, want %d", scored, len(names)-len(tt.cached))
+			}
+			list, _ := loaded.List(ctx)
+			for _, stored := range list {
+				if !stored.Scored || stored.Points != want[stored.ID] {
+					t.Errorf("%s: scored %v with %d points, want %d", stored.ID, stored.Scored, stored.Points, want[stored.ID])
+				}
+			}
+
+			if scored, _ := newServer(cfg, loaded).warmCache(ctx, tt.workers); scored != 0 {
+				t.Errorf("warming a warm cache scored %d receipts", scored)
+			}
+		})
+	}
+}
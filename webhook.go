@@ -0,0 +1,137 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// webhookQueueSize is how many events may wait for delivery. Events beyond it
+// go straight to the dead letter file, so a slow receiver never holds up a
+// request.
+const webhookQueueSize = 1000
+
+// WebhookEvent is the JSON body posted to the webhook for every processed receipt.
+type WebhookEvent struct {
+	ID        string    `json:"id"`
+	Retailer  string    `json:"retailer"`
+	Points    int64     `json:"points"`
+	Timestamp time.Time `json:"timestamp"`
+}
+
+// WebhookDeadLetter is one line of the dead letter file. Payload is the body
+// that couldn't be delivered, ready to be posted again.
+type WebhookDeadLetter struct {
+	Payload  json.RawMessage `json:"payload"`
+	Error    string          `json:"error"`
+	FailedAt time.Time       `json:"failedAt"`
+}
+
+// webhookSender posts events to a webhook in the background, retrying failed
+// attempts with exponential backoff.
+type webhookSender struct {
+	url     string
+	client  *http.Client
+	retries int
+	backoff time.Duration
+	events  chan []byte
+
+	// deadLetter receives the events that couldn't be delivered, if set.
+	mu         sync.Mutex
+	deadLetter io.Writer
+}
+
+// newWebhookSender starts delivering events to url. Each attempt may take up
+// to timeout, and a failed one is retried up to retries times, waiting backoff
+// before the first retry and twice as long before every later one.
+func newWebhookSender(url string, timeout time.Duration, retries int, backoff time.Duration, deadLetter io.Writer) *webhookSender {
+	w := &webhookSender{
+		url:        url,
+		client:     &http.Client{Timeout: timeout},
+		retries:    retries,
+		backoff:    backoff,
+		events:     make(chan []byte, webhookQueueSize),
+		deadLetter: deadLetter,
+	}
+	go w.run()
+	return w
+}
+
+// send queues the event for delivery without waiting for it.
+func (w *webhookSender) send(event WebhookEvent) {
+	payload, err := json.Marshal(event)
+	if err != nil {
+		log.Printf("encoding webhook event for receipt %s: %v\n", event.ID, err)
+		return
+	}
+
+	select {
+	case w.events <- payload:
+	default:
+		w.fail(payload, fmt.Errorf("more than %d events waiting for delivery", webhookQueueSize))
+	}
+}
+
+func (w *webhookSender) run() {
+	for payload := range w.events {
+		w.deliver(payload)
+	}
+}
+
+// deliver posts the payload until an attempt succeeds or the retries are used up.
+func (w *webhookSender) deliver(payload []byte) {
+	delay := w.backoff
+	var err error
+	for attempt := 0; attempt <= w.retries; attempt++ {
+		if attempt > 0 {
+			time.Sleep(delay)
+			delay *= 2
+		}
+		if err = w.post(payload); err == nil {
+			return
+		}
+	}
+	w.fail(payload, err)
+}
+
+// post makes a single delivery attempt. Any status but 2xx is a failure.
+func (w *webhookSender) post(payload []byte) error {
+	resp, err := w.client.Post(w.url, "application/json", bytes.NewReader(payload))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, resp.Body)
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("webhook responded with %s", resp.Status)
+	}
+	return nil
+}
+
+// fail writes an undeliverable payload to the dead letter file.
+func (w *webhookSender) fail(payload []byte, err error) {
+	log.Printf("webhook delivery failed: %v\n", err)
+	if w.deadLetter == nil {
+		return
+	}
+
+	line, merr := json.Marshal(WebhookDeadLetter{Payload: payload, Error: err.Error(), FailedAt: time.Now().UTC()})
+	if merr != nil {
+		log.Printf("encoding webhook dead letter: %v\n", merr)
+		return
+	}
+	line = append(line, '\n')
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if _, err := w.deadLetter.Write(line); err != nil {
+		log.Printf("writing webhook dead letter: %v\n", err)
+	}
+}
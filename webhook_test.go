@@ -0,0 +1,78 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// deadLetterSink hands every line written to it to the test.
+type deadLetterSink chan []byte
+
+func (s deadLetterSink) Write(p []byte) (int, error) {
+	s <- append([]byte(nil), p...)
+	return len(p), nil
+}
+
+func TestWebhookDeadLetter(t *testing.T) {
+	tests := []struct {
+		name    string
+		handler http.HandlerFunc
+		retries int
+		err     string
+	}{
+		{"server error", func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusInternalServerError)
+		}, 2, "500 Internal Server Error"},
+		{"no retries", func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusBadGateway)
+		}, 0, "502 Bad Gateway"},
+		{"timeout", func(w http.ResponseWriter, r *http.Request) {
+			time.Sleep(200 * time.Millisecond)
+		}, 1, "Timeout"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var attempts atomic.Int32
+			receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				attempts.Add(1)
+				tt.handler(w, r)
+			}))
+			defer receiver.Close()
+
+			sink := make(deadLetterSink, 1)
+			w := newWebhookSender(receiver.URL, 50*time.Millisecond, tt.retries, time.Millisecond, sink)
+			event := WebhookEvent{ID: "abc", Retailer: "Target", Points: 28}
+			start := time.Now()
+			w.send(event)
+			if elapsed := time.Since(start); elapsed > 20*time.Millisecond {
+				t.Errorf("send blocked for %v", elapsed)
+			}
+
+			var line []byte
+			select {
+			case line = <-sink:
+			case <-time.After(5 * time.Second):
+				t.Fatal("no dead letter written")
+			}
+			var letter WebhookDeadLetter
+			if err := json.Unmarshal(line, &letter); err != nil {
+				t.Fatalf("dead letter %s: %v", line, err)
+			}
+			var payload WebhookEvent
+			if err := json.Unmarshal(letter.Payload, &payload); err != nil || payload.ID != event.ID || payload.Points != event.Points {
+				t.Errorf("payload = %s, %v, want the event", letter.Payload, err)
+			}
+			if !strings.Contains(letter.Error, tt.err) {
+				t.Errorf("error = %q, want it to mention %q", letter.Error, tt.err)
+			}
+			if got := int(attempts.Load()); got != tt.retries+1 {
+				t.Errorf("attempts = %d, want %d", got, tt.retries+1)
+			}
+		})
+	}
+}